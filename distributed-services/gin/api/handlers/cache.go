@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/singleflight"
+)
+
+// The recipes are cached in Redis per query:
+//
+//	recipes:id:<id>                  a recipe, deleted when the recipe changes
+//	recipes:<generation>:list:<q>    a page of the list
+//	recipes:<generation>:search:<q>  a page of search results
+//
+// Any mutation can change any list, so instead of tracking every list key we bump the
+// generation: the keys of the old generation are never read again and expire with their TTL.
+// A load only caches its value if the generation didn't move while it ran, or a load that read
+// a recipe before it changed would cache the old recipe after its key was deleted.
+const (
+	recipeTTL = 10 * time.Minute
+	queryTTL  = 5 * time.Minute
+
+	generationKey = "recipes:generation"
+
+	// lockTTL bounds how long the instance that loads a key keeps the others waiting.
+	lockTTL  = 5 * time.Second
+	lockWait = time.Second
+)
+
+var cacheRequests = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "recipes_cache_requests_total",
+		Help: "Number of recipes cache lookups by query and result (hit or miss)",
+	},
+	[]string{"query", "result"},
+)
+
+// unlockScript deletes the lock only if it still holds our token: once the lock expired,
+// another instance may have taken it.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// setScript sets the key only if the generation is still the one the value was loaded in.
+var setScript = redis.NewScript(`
+if (redis.call("GET", KEYS[2]) or "0") ~= ARGV[2] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[3])
+return 1
+`)
+
+type recipesCache struct {
+	client *redis.Client
+	group  singleflight.Group
+}
+
+func newRecipesCache(client *redis.Client) *recipesCache {
+	return &recipesCache{client: client}
+}
+
+func recipeKey(id string) string {
+	return "recipes:id:" + id
+}
+
+// queryKey returns the key of a list or search query in the current generation.
+func (c *recipesCache) queryKey(query, value string) string {
+	return fmt.Sprintf("recipes:%d:%s:%s", c.generation(), query, value)
+}
+
+func (c *recipesCache) generation() int64 {
+	generation, err := c.client.Get(generationKey).Int64()
+	if err != nil && err != redis.Nil {
+		log.Printf("Redis: %v", err)
+	}
+	return generation
+}
+
+// fetch decodes the cached value of key into dst, or calls load and caches what it returns.
+//
+// Stampede protection: within an instance, concurrent misses on a key share one load; across
+// instances, the one that takes the key's lock loads it while the others wait for the value
+// to show up, and only load it themselves if it takes longer than lockWait. The TTL is
+// jittered so the keys cached together don't all expire together.
+//
+// Redis being down isn't an error, we load from MongoDB like on a miss.
+func (c *recipesCache) fetch(query, key string, ttl time.Duration, dst interface{}, load func() (interface{}, error)) error {
+	if data, err := c.client.Get(key).Bytes(); err == nil {
+		cacheRequests.WithLabelValues(query, "hit").Inc()
+		return json.Unmarshal(data, dst)
+	} else if err != redis.Nil {
+		log.Printf("Redis: %v", err)
+	}
+	cacheRequests.WithLabelValues(query, "miss").Inc()
+
+	data, err, _ := c.group.Do(key, func() (interface{}, error) {
+		lock := "lock:" + key
+		token := lockToken()
+		locked, err := c.client.SetNX(lock, token, lockTTL).Result()
+		if err == nil && !locked {
+			if data, ok := c.waitFor(key); ok {
+				return data, nil
+			}
+		}
+		if locked {
+			defer func() {
+				if err := unlockScript.Run(c.client, []string{lock}, token).Err(); err != nil {
+					log.Printf("Redis: %v", err)
+				}
+			}()
+		}
+
+		generation := c.generation()
+		v, err := load()
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		jitter := time.Duration(rand.Int63n(int64(ttl / 10)))
+		ms := int64((ttl + jitter) / time.Millisecond)
+		if err := setScript.Run(c.client, []string{key, generationKey}, data, generation, ms).Err(); err != nil {
+			log.Printf("Redis: %v", err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data.([]byte), dst)
+}
+
+// lockToken returns a random value that tells our lock from the one another instance takes
+// after ours expired.
+func lockToken() string {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return fmt.Sprint(time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// waitFor polls key while another instance loads it.
+func (c *recipesCache) waitFor(key string) ([]byte, bool) {
+	deadline := time.Now().Add(lockWait)
+	for time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		if data, err := c.client.Get(key).Bytes(); err == nil {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// invalidate is called after every mutation: it moves the lists to a new generation and
+// deletes the recipe's key. The generation moves first, so a load that started before can't
+// cache its value after the key is deleted.
+func (c *recipesCache) invalidate(id string) {
+	log.Println("Remove data from Redis")
+	if err := c.client.Incr(generationKey).Err(); err != nil {
+		log.Printf("Redis: %v", err)
+	}
+	if id != "" {
+		if err := c.client.Del(recipeKey(id)).Err(); err != nil {
+			log.Printf("Redis: %v", err)
+		}
+	}
+}
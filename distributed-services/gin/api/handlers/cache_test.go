@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCache connects to a local Redis server, and skips the test when there's none.
+func newTestCache(t *testing.T) *recipesCache {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping().Err(); err != nil {
+		t.Skipf("no redis server: %v", err)
+	}
+	return newRecipesCache(client)
+}
+
+func TestRecipeKey(t *testing.T) {
+	assert.Equal(t, "recipes:id:600dcc85a65917cbd1f201b0", recipeKey("600dcc85a65917cbd1f201b0"))
+}
+
+func TestQueryKeyWithoutRedis(t *testing.T) {
+	// nothing listens on the port, the keys fall back to the first generation
+	client := redis.NewClient(&redis.Options{Addr: "localhost:1", MaxRetries: -1})
+	defer client.Close()
+	cache := newRecipesCache(client)
+
+	assert.Equal(t, "recipes:0:list:sort=newest", cache.queryKey("list", "sort=newest"))
+}
+
+func TestInvalidateMovesGeneration(t *testing.T) {
+	cache := newTestCache(t)
+
+	before := cache.queryKey("list", "sort=newest")
+	require.NoError(t, cache.client.Set(recipeKey("600dcc85a65917cbd1f201b0"), "{}", 0).Err())
+
+	cache.invalidate("600dcc85a65917cbd1f201b0")
+
+	assert.NotEqual(t, before, cache.queryKey("list", "sort=newest"), "the lists are in a new generation")
+	assert.Equal(t, redis.Nil, cache.client.Get(recipeKey("600dcc85a65917cbd1f201b0")).Err(), "the recipe's key is deleted")
+}
+
+func TestFetchCachesLoad(t *testing.T) {
+	cache := newTestCache(t)
+	key := cache.queryKey("list", "test-fetch")
+	defer cache.client.Del(key)
+
+	loads := 0
+	load := func() (interface{}, error) {
+		loads++
+		return []string{"pizza"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		var got []string
+		require.NoError(t, cache.fetch("list", key, queryTTL, &got, load))
+		assert.Equal(t, []string{"pizza"}, got)
+	}
+	assert.Equal(t, 1, loads, "the second fetch is a hit")
+}
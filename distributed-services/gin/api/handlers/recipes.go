@@ -1,9 +1,8 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/ahmad-khatib0/go/distributed-services/gin/api/models"
@@ -16,58 +15,67 @@ import (
 )
 
 type RecipesHandler struct {
-	collection  *mongo.Collection
-	ctx         context.Context
-	redisClient *redis.Client
+	collection *mongo.Collection
+	ctx        context.Context
+	cache      *recipesCache
 }
 
 func NewRecipesHandler(ctx context.Context, collection *mongo.Collection, redisClient *redis.Client) *RecipesHandler {
 	return &RecipesHandler{
-		collection:  collection,
-		ctx:         ctx,
-		redisClient: redisClient,
+		collection: collection,
+		ctx:        ctx,
+		cache:      newRecipesCache(redisClient),
 	}
 }
 
 // swagger:operation GET /recipes recipes listRecipes
-// Returns list of recipes
+// Returns list of recipes, a page at a time
 // ---
 // produces:
 // - application/json
+// parameters:
+//   - name: tag
+//     in: query
+//     description: only the recipes with this tag (case-insensitive)
+//     required: false
+//     type: string
+//   - name: sort
+//     in: query
+//     description: newest (default), oldest or name
+//     required: false
+//     type: string
+//   - name: limit
+//     in: query
+//     description: recipes per page, 20 by default and 100 at most
+//     required: false
+//     type: integer
+//   - name: cursor
+//     in: query
+//     description: the X-Next-Cursor header of the previous page
+//     required: false
+//     type: string
+//
 // responses:
 //
 //	'200':
 //	    description: Successful operation
+//	'400':
+//	    description: Invalid query
 func (handler *RecipesHandler) ListRecipesHandler(c *gin.Context) {
-	val, err := handler.redisClient.Get("recipes").Result()
-	if err == redis.Nil {
-		log.Printf("Request to MongoDB")
-		cur, err := handler.collection.Find(handler.ctx, bson.M{})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		defer cur.Close(handler.ctx)
-
-		recipes := make([]models.Recipe, 0)
-		for cur.Next(handler.ctx) {
-			var recipe models.Recipe
-			cur.Decode(&recipe)
-			recipes = append(recipes, recipe)
-		}
-
-		data, _ := json.Marshal(recipes)
-		handler.redisClient.Set("recipes", string(data), 0)
-		c.JSON(http.StatusOK, recipes)
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	q, err := parseRecipesQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
-	} else {
-		log.Printf("Request to Redis")
-		recipes := make([]models.Recipe, 0)
-		json.Unmarshal([]byte(val), &recipes)
-		c.JSON(http.StatusOK, recipes)
 	}
+	// searching is the search endpoint's job
+	q.ingredient, q.text = "", ""
+
+	handler.servePage(c, "list", q)
+}
+
+// tagFilter matches a tag regardless of its case.
+func tagFilter(tag string) bson.M {
+	return bson.M{"$regex": "^" + regexp.QuoteMeta(tag) + "$", "$options": "i"}
 }
 
 // swagger:operation POST /recipes recipes newRecipe
@@ -96,8 +104,7 @@ func (handler *RecipesHandler) NewRecipeHandler(c *gin.Context) {
 		return
 	}
 
-	log.Println("Remove data from Redis")
-	handler.redisClient.Del("recipes")
+	handler.cache.invalidate("")
 
 	c.JSON(http.StatusOK, recipe)
 }
@@ -133,16 +140,17 @@ func (handler *RecipesHandler) UpdateRecipeHandler(c *gin.Context) {
 	objectId, _ := primitive.ObjectIDFromHex(id)
 	_, err := handler.collection.UpdateOne(handler.ctx, bson.M{
 		"_id": objectId,
-	}, bson.D{{"$set", bson.D{
-		{"name", recipe.Name},
-		{"instructions", recipe.Instructions},
-		{"ingredients", recipe.Ingredients},
-		{"tags", recipe.Tags},
+	}, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "name", Value: recipe.Name},
+		{Key: "instructions", Value: recipe.Instructions},
+		{Key: "ingredients", Value: recipe.Ingredients},
+		{Key: "tags", Value: recipe.Tags},
 	}}})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	handler.cache.invalidate(id)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Recipe has been updated"})
 }
@@ -175,6 +183,8 @@ func (handler *RecipesHandler) DeleteRecipeHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	handler.cache.invalidate(id)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Recipe has been deleted"})
 }
 
@@ -194,14 +204,26 @@ func (handler *RecipesHandler) DeleteRecipeHandler(c *gin.Context) {
 //
 //	'200':
 //	    description: Successful operation
+//	'404':
+//	    description: Invalid recipe ID
 func (handler *RecipesHandler) GetOneRecipeHandler(c *gin.Context) {
 	id := c.Param("id")
-	objectId, _ := primitive.ObjectIDFromHex(id)
-	cur := handler.collection.FindOne(handler.ctx, bson.M{
-		"_id": objectId,
-	})
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid recipe ID"})
+		return
+	}
+
 	var recipe models.Recipe
-	err := cur.Decode(&recipe)
+	err = handler.cache.fetch("id", recipeKey(id), recipeTTL, &recipe, func() (interface{}, error) {
+		var recipe models.Recipe
+		err := handler.collection.FindOne(handler.ctx, bson.M{"_id": objectId}).Decode(&recipe)
+		return recipe, err
+	})
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid recipe ID"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -209,36 +231,3 @@ func (handler *RecipesHandler) GetOneRecipeHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, recipe)
 }
-
-// swagger:operation GET /recipes/search recipes findRecipe
-// Search recipes based on tags
-// ---
-// produces:
-// - application/json
-// parameters:
-//   - name: tag
-//     in: query
-//     description: recipe tag
-//     required: true
-//     type: string
-// responses:
-//     '200':
-//         description: Successful operation
-/*func SearchRecipesHandler(c *gin.Context) {
-	tag := c.Query("tag")
-	listOfRecipes := make([]Recipe, 0)
-
-	for i := 0; i < len(recipes); i++ {
-		found := false
-		for _, t := range recipes[i].Tags {
-			if strings.EqualFold(t, tag) {
-				found = true
-			}
-		}
-		if found {
-			listOfRecipes = append(listOfRecipes, recipes[i])
-		}
-	}
-
-	c.JSON(http.StatusOK, listOfRecipes)
-}*/
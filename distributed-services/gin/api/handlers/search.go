@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ahmad-khatib0/go/distributed-services/gin/api/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// NextCursorHeader carries the cursor of the next page, the body stays a plain array of
+// recipes so the clients of the list endpoint keep working. It's missing on the last page.
+const NextCursorHeader = "X-Next-Cursor"
+
+// sortOrder is a sort option of the list and search endpoints. Pages are cut with a cursor
+// on the sort field, and the _id to break ties, so they don't shift when recipes are added.
+type sortOrder struct {
+	field string
+	desc  bool
+}
+
+var sortOrders = map[string]sortOrder{
+	"newest": {field: "publishedAt", desc: true},
+	"oldest": {field: "publishedAt"},
+	"name":   {field: "name"},
+}
+
+// recipesQuery is a parsed list or search request.
+type recipesQuery struct {
+	tag        string
+	ingredient string
+	text       string
+	sort       string
+	limit      int64
+	cursor     *pageCursor
+}
+
+// pageCursor is the position of the last recipe of a page, encoded as opaque base64 JSON.
+type pageCursor struct {
+	Name        string             `json:"n,omitempty"`
+	PublishedAt time.Time          `json:"p,omitempty"`
+	ID          primitive.ObjectID `json:"id"`
+}
+
+type recipesPage struct {
+	Recipes    []models.Recipe `json:"recipes"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func parseRecipesQuery(c *gin.Context) (*recipesQuery, error) {
+	q := &recipesQuery{
+		tag:        c.Query("tag"),
+		ingredient: c.Query("ingredient"),
+		text:       c.Query("q"),
+		sort:       c.DefaultQuery("sort", "newest"),
+		limit:      defaultLimit,
+	}
+	if _, ok := sortOrders[q.sort]; !ok {
+		return nil, errors.New("sort must be one of newest, oldest or name")
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return nil, errors.New("limit must be between 1 and 100")
+		}
+		q.limit = limit
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		b, err := base64.RawURLEncoding.DecodeString(v)
+		if err == nil {
+			q.cursor = &pageCursor{}
+			err = json.Unmarshal(b, q.cursor)
+		}
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+	return q, nil
+}
+
+// key identifies the query in the cache, two requests asking for the same page share it.
+func (q *recipesQuery) key() string {
+	// the filters don't care about case
+	v := url.Values{}
+	v.Set("tag", strings.ToLower(q.tag))
+	v.Set("ingredient", strings.ToLower(q.ingredient))
+	v.Set("q", strings.ToLower(q.text))
+	v.Set("sort", q.sort)
+	v.Set("limit", strconv.FormatInt(q.limit, 10))
+	if q.cursor != nil {
+		v.Set("cursor", q.cursor.encode())
+	}
+	return v.Encode()
+}
+
+func (q *recipesQuery) filter() bson.M {
+	var and []bson.M
+	if q.tag != "" {
+		and = append(and, bson.M{"tags": tagFilter(q.tag)})
+	}
+	if q.ingredient != "" {
+		and = append(and, bson.M{"ingredients": bson.M{"$regex": regexp.QuoteMeta(q.ingredient), "$options": "i"}})
+	}
+	if q.text != "" {
+		// served by the text index on name and instructions
+		and = append(and, bson.M{"$text": bson.M{"$search": q.text}})
+	}
+	if q.cursor != nil {
+		and = append(and, q.after())
+	}
+
+	if len(and) == 0 {
+		return bson.M{}
+	}
+	return bson.M{"$and": and}
+}
+
+// after matches the recipes that come after the cursor in the query's sort order.
+func (q *recipesQuery) after() bson.M {
+	order := sortOrders[q.sort]
+	op := "$gt"
+	if order.desc {
+		op = "$lt"
+	}
+
+	var value interface{} = q.cursor.Name
+	if order.field == "publishedAt" {
+		value = q.cursor.PublishedAt
+	}
+	return bson.M{"$or": []bson.M{
+		{order.field: bson.M{op: value}},
+		{order.field: value, "_id": bson.M{op: q.cursor.ID}},
+	}}
+}
+
+func (q *recipesQuery) options() *options.FindOptions {
+	order := sortOrders[q.sort]
+	dir := 1
+	if order.desc {
+		dir = -1
+	}
+	// we fetch one more recipe than the page holds to know whether there's a next page
+	return options.Find().
+		SetSort(bson.D{{Key: order.field, Value: dir}, {Key: "_id", Value: dir}}).
+		SetLimit(q.limit + 1)
+}
+
+func (c *pageCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// findPage runs the query against MongoDB.
+func (handler *RecipesHandler) findPage(q *recipesQuery) (*recipesPage, error) {
+	cur, err := handler.collection.Find(handler.ctx, q.filter(), q.options())
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(handler.ctx)
+
+	page := &recipesPage{Recipes: make([]models.Recipe, 0, q.limit)}
+	if err := cur.All(handler.ctx, &page.Recipes); err != nil {
+		return nil, err
+	}
+
+	if int64(len(page.Recipes)) > q.limit {
+		page.Recipes = page.Recipes[:q.limit]
+		last := page.Recipes[q.limit-1]
+		page.NextCursor = (&pageCursor{Name: last.Name, PublishedAt: last.PublishedAt, ID: last.ID}).encode()
+	}
+	return page, nil
+}
+
+// servePage answers a list or search request, from the cache when it can.
+func (handler *RecipesHandler) servePage(c *gin.Context, cacheQuery string, q *recipesQuery) {
+	var page recipesPage
+	key := handler.cache.queryKey(cacheQuery, q.key())
+	err := handler.cache.fetch(cacheQuery, key, queryTTL, &page, func() (interface{}, error) {
+		return handler.findPage(q)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if page.NextCursor != "" {
+		c.Header(NextCursorHeader, page.NextCursor)
+	}
+	c.JSON(http.StatusOK, page.Recipes)
+}
+
+// CreateIndexes creates the indexes the list and search queries need, MongoDB doesn't run
+// a $text query without a text index.
+func (handler *RecipesHandler) CreateIndexes() error {
+	_, err := handler.collection.Indexes().CreateMany(handler.ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: "text"}, {Key: "instructions", Value: "text"}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "publishedAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+	})
+	return err
+}
+
+// swagger:operation GET /recipes/search recipes findRecipe
+// Search recipes by tag, ingredient and text in their name or instructions
+// ---
+// produces:
+// - application/json
+// parameters:
+//   - name: tag
+//     in: query
+//     description: recipe tag (case-insensitive)
+//     required: false
+//     type: string
+//   - name: ingredient
+//     in: query
+//     description: text an ingredient contains (case-insensitive)
+//     required: false
+//     type: string
+//   - name: q
+//     in: query
+//     description: words to search in the name and instructions
+//     required: false
+//     type: string
+//   - name: sort
+//     in: query
+//     description: newest (default), oldest or name
+//     required: false
+//     type: string
+//   - name: limit
+//     in: query
+//     description: recipes per page, 20 by default and 100 at most
+//     required: false
+//     type: integer
+//   - name: cursor
+//     in: query
+//     description: the X-Next-Cursor header of the previous page
+//     required: false
+//     type: string
+//
+// responses:
+//
+//	'200':
+//	    description: Successful operation
+//	'400':
+//	    description: Invalid query
+func (handler *RecipesHandler) SearchRecipesHandler(c *gin.Context) {
+	q, err := parseRecipesQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.tag == "" && q.ingredient == "" && q.text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag, ingredient or q is required"})
+		return
+	}
+
+	handler.servePage(c, "search", q)
+}
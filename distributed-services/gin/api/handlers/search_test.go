@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func parseQuery(t *testing.T, rawQuery string) (*recipesQuery, error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/recipes/search?"+rawQuery, nil)
+	return parseRecipesQuery(c)
+}
+
+func TestPageCursor(t *testing.T) {
+	cursor := &pageCursor{
+		Name:        "Oregano Marinated Chicken",
+		PublishedAt: time.Date(2021, 1, 17, 19, 28, 52, 0, time.UTC),
+		ID:          primitive.NewObjectID(),
+	}
+
+	q, err := parseQuery(t, "sort=name&cursor="+cursor.encode())
+	require.NoError(t, err)
+	require.NotNil(t, q.cursor)
+	assert.Equal(t, cursor.Name, q.cursor.Name)
+	assert.True(t, cursor.PublishedAt.Equal(q.cursor.PublishedAt))
+	assert.Equal(t, cursor.ID, q.cursor.ID)
+
+	for _, bad := range []string{"not-a-cursor!", "bm90IGpzb24", "eyJpZCI6IjEyMyJ9"} {
+		_, err := parseQuery(t, "cursor="+bad)
+		assert.EqualError(t, err, "invalid cursor", bad)
+	}
+}
+
+func TestParseRecipesQuery(t *testing.T) {
+	q, err := parseQuery(t, "tag=main")
+	require.NoError(t, err)
+	assert.Equal(t, "newest", q.sort)
+	assert.Equal(t, int64(defaultLimit), q.limit)
+	assert.Nil(t, q.cursor)
+
+	for _, bad := range []string{"sort=random", "limit=0", "limit=101", "limit=ten"} {
+		_, err := parseQuery(t, bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestRecipesQueryKey(t *testing.T) {
+	a, err := parseQuery(t, "tag=Main&ingredient=Chicken&q=Oregano")
+	require.NoError(t, err)
+	b, err := parseQuery(t, "q=oregano&ingredient=chicken&tag=main")
+	require.NoError(t, err)
+	assert.Equal(t, a.key(), b.key(), "the same query in another case or order")
+
+	next, err := parseQuery(t, "tag=main&ingredient=chicken&q=oregano&cursor="+(&pageCursor{ID: primitive.NewObjectID()}).encode())
+	require.NoError(t, err)
+	assert.NotEqual(t, a.key(), next.key(), "another page")
+
+	sorted, err := parseQuery(t, "tag=main&ingredient=chicken&q=oregano&sort=name")
+	require.NoError(t, err)
+	assert.NotEqual(t, a.key(), sorted.key(), "another sort")
+}
+
+func TestRecipesQueryAfter(t *testing.T) {
+	id := primitive.NewObjectID()
+	publishedAt := time.Date(2021, 1, 17, 19, 28, 52, 0, time.UTC)
+	cursor := &pageCursor{Name: "Pizza", PublishedAt: publishedAt, ID: id}
+
+	newest := &recipesQuery{sort: "newest", cursor: cursor}
+	assert.Equal(t, bson.M{"$or": []bson.M{
+		{"publishedAt": bson.M{"$lt": publishedAt}},
+		{"publishedAt": publishedAt, "_id": bson.M{"$lt": id}},
+	}}, newest.after())
+
+	name := &recipesQuery{sort: "name", cursor: cursor}
+	assert.Equal(t, bson.M{"$or": []bson.M{
+		{"name": bson.M{"$gt": "Pizza"}},
+		{"name": "Pizza", "_id": bson.M{"$gt": id}},
+	}}, name.after())
+}
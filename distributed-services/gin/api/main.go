@@ -87,6 +87,9 @@ func init() {
 	log.Println(status)
 
 	recipesHandler = handlers.NewRecipesHandler(ctx, collectionRecipes, redisClient)
+	if err := recipesHandler.CreateIndexes(); err != nil {
+		log.Fatal(err)
+	}
 
 	collectionUsers := client.Database(os.Getenv("MONGO_DATABASE")).Collection("users")
 	authHandler = handlers.NewAuthHandler(ctx, collectionUsers)
@@ -120,6 +123,7 @@ func SetupServer() *gin.Engine {
 	router.Use(PrometheusMiddleware())
 
 	router.GET("/recipes", recipesHandler.ListRecipesHandler)
+	router.GET("/recipes/search", recipesHandler.SearchRecipesHandler)
 
 	router.POST("/signin", authHandler.SignInHandler)
 	router.POST("/refresh", authHandler.RefreshHandler)
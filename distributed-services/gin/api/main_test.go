@@ -11,6 +11,7 @@ import (
 
 	"github.com/ahmad-khatib0/go/distributed-services/gin/api/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestListRecipesHandler(t *testing.T) {
@@ -18,8 +19,8 @@ func TestListRecipesHandler(t *testing.T) {
 	defer ts.Close()
 
 	resp, err := http.Get(fmt.Sprintf("%s/recipes", ts.URL))
+	require.NoError(t, err)
 	defer resp.Body.Close()
-	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 	data, _ := ioutil.ReadAll(resp.Body)
 
@@ -32,15 +33,16 @@ func TestUpdateRecipeHandler(t *testing.T) {
 	ts := httptest.NewServer(SetupServer())
 	defer ts.Close()
 
-	recipe := Recipe{
-		ID:   "c0283p3d0cvuglq85log",
+	id := "c0283p3d0cvuglq85log"
+	recipe := models.Recipe{
 		Name: "Oregano Marinated Chicken",
 	}
 
 	raw, _ := json.Marshal(recipe)
-	resp, err := http.PUT(fmt.Sprintf("%s/recipes/%s", ts.URL, recipe.ID), bytes.NewBuffer(raw))
+	req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/recipes/%s", ts.URL, id), bytes.NewBuffer(raw))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
 	defer resp.Body.Close()
-	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 	data, _ := ioutil.ReadAll(resp.Body)
 
@@ -54,9 +56,10 @@ func TestDeleteRecipeHandler(t *testing.T) {
 	ts := httptest.NewServer(SetupServer())
 	defer ts.Close()
 
-	resp, err := http.DELETE(fmt.Sprintf("%s/recipes/c0283p3d0cvuglq85log", ts.URL))
+	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/recipes/c0283p3d0cvuglq85log", ts.URL), nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
 	defer resp.Body.Close()
-	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 	data, _ := ioutil.ReadAll(resp.Body)
 
@@ -71,18 +74,17 @@ func TestFindRecipeHandler(t *testing.T) {
 	defer ts.Close()
 
 	expectedRecipe := models.Recipe{
-		ID:   "c0283p3d0cvuglq85log",
 		Name: "Oregano Marinated Chicken",
 		Tags: []string{"main", "chicken"},
 	}
 
-	resp, err := http.GET(fmt.Sprintf("%s/recipes/c0283p3d0cvuglq85log", ts.URL))
+	resp, err := http.Get(fmt.Sprintf("%s/recipes/c0283p3d0cvuglq85log", ts.URL))
+	require.NoError(t, err)
 	defer resp.Body.Close()
-	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 	data, _ := ioutil.ReadAll(resp.Body)
 
-	var actualRecipe Recipe
+	var actualRecipe models.Recipe
 	json.Unmarshal(data, &actualRecipe)
 
 	assert.Equal(t, expectedRecipe.Name, actualRecipe.Name)
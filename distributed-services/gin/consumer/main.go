@@ -4,11 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/streadway/amqp"
 	"go.mongodb.org/mongo-driver/bson"
@@ -34,30 +37,305 @@ type Entry struct {
 	Title string `xml:"title"`
 }
 
-func GetFeedEntries(url string) ([]Entry, error) {
-	httpClient := &http.Client{}
+// Validators are the headers of the last response of a feed, sent back on the next fetch so
+// the server can answer 304 Not Modified when the feed didn't change.
+type Validators struct {
+	ETag         string `bson:"etag"`
+	LastModified string `bson:"lastModified"`
+}
+
+var errNotModified = errors.New("feed not modified")
+
+func GetFeedEntries(url string, validators Validators) ([]Entry, Validators, error) {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, err
+		return nil, validators, err
 	}
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.110 Safari/537.36")
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, validators, err
 	}
 	defer resp.Body.Close()
 
-	byteValue, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, validators, errNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, validators, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	byteValue, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, validators, err
+	}
 	var feed Feed
-	xml.Unmarshal(byteValue, &feed)
+	if err := xml.Unmarshal(byteValue, &feed); err != nil {
+		return nil, validators, err
+	}
+
+	return feed.Entries, Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// A message that fails is acked and published to the retry queue, whose messages expire after
+// the retry delay and are dead-lettered back to the feeds queue. After maxAttempts, or when the
+// message can't be parsed at all, it goes to the dead letter queue for someone to look at.
+const attemptsHeader = "x-attempts"
 
-	return feed.Entries, nil
+type consumer struct {
+	channel     *amqp.Channel
+	recipes     *mongo.Collection
+	feeds       *mongo.Collection
+	queue       string
+	retryQueue  string
+	deadQueue   string
+	retryDelay  time.Duration
+	maxAttempts int
+}
+
+func (c *consumer) declare() error {
+	if _, err := c.channel.QueueDeclare(c.queue, true, false, false, false, nil); err != nil {
+		return err
+	}
+	if _, err := c.channel.QueueDeclare(c.retryQueue, true, false, false, false, amqp.Table{
+		"x-message-ttl":             int32(c.retryDelay / time.Millisecond),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": c.queue,
+	}); err != nil {
+		return err
+	}
+	if _, err := c.channel.QueueDeclare(c.deadQueue, true, false, false, false, nil); err != nil {
+		return err
+	}
+	return c.channel.Qos(10, 0, false)
+}
+
+// ensureIndexes makes the entry URL unique among the recipes that have one, the recipes
+// created through the API don't. The duplicates parsed before the index existed are removed
+// first, or the index can't be built.
+func (c *consumer) ensureIndexes(ctx context.Context) error {
+	if err := c.dedupeRecipes(ctx); err != nil {
+		return err
+	}
+	_, err := c.recipes.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "url", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"url": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+// dedupeRecipes keeps the last updated recipe of each entry URL and deletes the others.
+func (c *consumer) dedupeRecipes(ctx context.Context) error {
+	cur, err := c.recipes.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"url": bson.M{"$exists": true}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "updatedAt", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$url", "ids": bson.M{"$push": "$_id"}}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var duplicates struct {
+			URL string        `bson:"_id"`
+			IDs []interface{} `bson:"ids"`
+		}
+		if err := cur.Decode(&duplicates); err != nil {
+			return err
+		}
+		res, err := c.recipes.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": duplicates.IDs[1:]}})
+		if err != nil {
+			return err
+		}
+		log.Printf("Deleted %d duplicates of %s", res.DeletedCount, duplicates.URL)
+	}
+	return cur.Err()
+}
+
+func (c *consumer) handle(d amqp.Delivery) {
+	log.Printf("Received a message: %s", d.Body)
+
+	var request Request
+	if err := json.Unmarshal(d.Body, &request); err != nil || request.URL == "" {
+		c.deadLetter(d, fmt.Sprintf("invalid message: %v", err))
+		return
+	}
+
+	log.Println("RSS URL:", request.URL)
+	if err := c.process(request.URL); err != nil {
+		log.Printf("Error while processing %s: %v", request.URL, err)
+		c.retry(d, err)
+		return
+	}
+	d.Ack(false)
+}
+
+// process fetches the feed and upserts its entries by URL, so parsing a feed again updates
+// the recipes instead of duplicating them.
+func (c *consumer) process(url string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// registered feeds remember their validators, one-off URLs don't
+	var feed Validators
+	err := c.feeds.FindOne(ctx, bson.M{"url": url}).Decode(&feed)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	entries, validators, err := GetFeedEntries(url, feed)
+	if err == errNotModified {
+		log.Println("Not modified:", url)
+		return c.touchFeed(ctx, url, validators)
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var writes []mongo.WriteModel
+	for _, entry := range entries {
+		if entry.Link.Href == "" {
+			continue
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"url": entry.Link.Href}).
+			SetUpdate(bson.M{
+				"$set": bson.M{
+					"title":     entry.Title,
+					"thumbnail": entry.Thumbnail.URL,
+					"feed":      url,
+					"updatedAt": now,
+				},
+				"$setOnInsert": bson.M{"createdAt": now},
+			}).
+			SetUpsert(true))
+	}
+	log.Printf("Upserting %d entries", len(writes))
+	if len(writes) > 0 {
+		if _, err := c.recipes.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return err
+		}
+	}
+	return c.touchFeed(ctx, url, validators)
+}
+
+func (c *consumer) touchFeed(ctx context.Context, url string, validators Validators) error {
+	_, err := c.feeds.UpdateOne(ctx, bson.M{"url": url}, bson.M{"$set": bson.M{
+		"etag":          validators.ETag,
+		"lastModified":  validators.LastModified,
+		"lastFetchedAt": time.Now(),
+		"lastError":     "",
+	}})
+	return err
+}
+
+func (c *consumer) retry(d amqp.Delivery, cause error) {
+	queue, headers := c.route(d)
+	if queue == c.deadQueue {
+		c.deadLetter(d, cause.Error())
+		return
+	}
+
+	c.republish(d, queue, headers)
+	log.Printf("Retrying in %s (attempt %d of %d)", c.retryDelay, attempts(d)+2, c.maxAttempts)
+}
+
+// route returns the queue a failed message goes to next: the retry queue, with the attempt
+// counted in its headers, or the dead letter queue once it has used all its attempts.
+func (c *consumer) route(d amqp.Delivery) (queue string, headers amqp.Table) {
+	attempts := attempts(d) + 1
+	if attempts >= c.maxAttempts {
+		return c.deadQueue, nil
+	}
+
+	headers = amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[attemptsHeader] = int32(attempts)
+	return c.retryQueue, headers
+}
+
+func (c *consumer) deadLetter(d amqp.Delivery, reason string) {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers["x-error"] = reason
+	c.republish(d, c.deadQueue, headers)
+	log.Printf("Dead-lettered: %s", reason)
+
+	// the feed registry shows why a feed stopped updating
+	var request Request
+	if json.Unmarshal(d.Body, &request) == nil && request.URL != "" {
+		c.feeds.UpdateOne(context.Background(), bson.M{"url": request.URL}, bson.M{"$set": bson.M{"lastError": reason}})
+	}
+}
+
+// republish publishes the message to queue and acks it. If we crash in between, the message
+// is processed twice, which the upserts make harmless.
+func (c *consumer) republish(d amqp.Delivery, queue string, headers amqp.Table) {
+	err := c.channel.Publish("", queue, false, false, amqp.Publishing{
+		ContentType:  d.ContentType,
+		Body:         d.Body,
+		Headers:      headers,
+		DeliveryMode: amqp.Persistent,
+	})
+	if err != nil {
+		log.Printf("Error while publishing to %s: %v", queue, err)
+		// put it back, better to process it again than to lose it
+		d.Nack(false, true)
+		return
+	}
+	d.Ack(false)
+}
+
+func attempts(d amqp.Delivery) int {
+	switch v := d.Headers[attemptsHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	return 0
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return d
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	if i, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return i
+	}
+	return fallback
 }
 
 func main() {
 	ctx := context.Background()
-	mongoClient, _ := mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("MONGO_URI")))
+	mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("MONGO_URI")))
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer mongoClient.Disconnect(ctx)
 
 	amqpConnection, err := amqp.Dial(os.Getenv("RABBITMQ_URI"))
@@ -66,46 +344,47 @@ func main() {
 	}
 	defer amqpConnection.Close()
 
-	channelAmqp, _ := amqpConnection.Channel()
+	channelAmqp, err := amqpConnection.Channel()
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer channelAmqp.Close()
 
-	forever := make(chan bool)
+	queue := os.Getenv("RABBITMQ_QUEUE")
+	database := mongoClient.Database(os.Getenv("MONGO_DATABASE"))
+	c := &consumer{
+		channel:     channelAmqp,
+		recipes:     database.Collection("recipes"),
+		feeds:       database.Collection("feeds"),
+		queue:       queue,
+		retryQueue:  queue + ".retry",
+		deadQueue:   queue + ".dead",
+		retryDelay:  envDuration("RETRY_DELAY", 30*time.Second),
+		maxAttempts: envInt("MAX_ATTEMPTS", 5),
+	}
+	if err := c.declare(); err != nil {
+		log.Fatal(err)
+	}
+	// the upserts keep working without the index, only concurrent ones may duplicate an entry
+	if err := c.ensureIndexes(ctx); err != nil {
+		log.Printf("Error while creating the recipe indexes: %v", err)
+	}
 
 	msgs, err := channelAmqp.Consume(
-		os.Getenv("RABBITMQ_QUEUE"),
+		queue,
 		"",
-		true,
+		false,
 		false,
 		false,
 		false,
 		nil,
 	)
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received a message: %s", d.Body)
-
-			var request Request
-			json.Unmarshal(d.Body, &request)
-
-			log.Println("RSS URL:", request.URL)
-
-			entries, _ := GetFeedEntries(request.URL)
-
-			fmt.Println(entries)
-
-			collection := mongoClient.Database(os.Getenv("MONGO_DATABASE")).Collection("recipes")
-			fmt.Println(len(entries))
-			for _, entry := range entries {
-				collection.InsertOne(ctx, bson.M{
-					"title":     entry.Title,
-					"thumbnail": entry.Thumbnail.URL,
-					"url":       entry.Link.Href,
-				})
-			}
-		}
-	}()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	log.Printf(" [*] Waiting for messages. To exit press CTRL+C")
-	<-forever
+	for d := range msgs {
+		c.handle(d)
+	}
 }
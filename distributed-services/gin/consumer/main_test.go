@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+func TestRoute(t *testing.T) {
+	c := &consumer{retryQueue: "feeds.retry", deadQueue: "feeds.dead", maxAttempts: 3}
+
+	tests := []struct {
+		name     string
+		headers  amqp.Table
+		queue    string
+		attempts int32
+	}{
+		{"first failure", nil, "feeds.retry", 1},
+		{"second failure", amqp.Table{attemptsHeader: int32(1)}, "feeds.retry", 2},
+		{"out of attempts", amqp.Table{attemptsHeader: int32(2)}, "feeds.dead", 0},
+		{"int64 header", amqp.Table{attemptsHeader: int64(1)}, "feeds.retry", 2},
+		{"past the max", amqp.Table{attemptsHeader: int64(7)}, "feeds.dead", 0},
+		{"unknown header type", amqp.Table{attemptsHeader: "2"}, "feeds.retry", 1},
+	}
+	for _, tt := range tests {
+		queue, headers := c.route(amqp.Delivery{Headers: tt.headers})
+		if queue != tt.queue {
+			t.Errorf("%s: got queue %q; want %q", tt.name, queue, tt.queue)
+			continue
+		}
+		if queue == c.deadQueue {
+			continue
+		}
+		if got := headers[attemptsHeader]; got != tt.attempts {
+			t.Errorf("%s: got %s %v; want %d", tt.name, attemptsHeader, got, tt.attempts)
+		}
+	}
+}
+
+func TestRouteKeepsHeaders(t *testing.T) {
+	c := &consumer{retryQueue: "feeds.retry", deadQueue: "feeds.dead", maxAttempts: 3}
+	d := amqp.Delivery{Headers: amqp.Table{"x-request-id": "abc", attemptsHeader: int32(1)}}
+
+	_, headers := c.route(d)
+	if headers["x-request-id"] != "abc" {
+		t.Errorf("got headers %v; want x-request-id kept", headers)
+	}
+	// the delivery's own headers aren't touched
+	if d.Headers[attemptsHeader] != int32(1) {
+		t.Errorf("got delivery %s %v; want it unchanged", attemptsHeader, d.Headers[attemptsHeader])
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/streadway/amqp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The feed registry holds the feeds we keep parsing. Every pollTick the poller publishes the
+// feeds that are due to the RabbitMQ queue, the consumer fetches them with the ETag and
+// Last-Modified it stored on the feed, so a feed that didn't change costs a 304.
+const (
+	defaultPollInterval = 15 * time.Minute
+	minPollInterval     = time.Minute
+	pollTick            = 30 * time.Second
+)
+
+var feeds *mongo.Collection
+
+type RegisteredFeed struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	URL           string             `json:"url" bson:"url"`
+	Interval      string             `json:"interval" bson:"interval"`
+	ETag          string             `json:"etag,omitempty" bson:"etag,omitempty"`
+	LastModified  string             `json:"lastModified,omitempty" bson:"lastModified,omitempty"`
+	NextPollAt    time.Time          `json:"nextPollAt" bson:"nextPollAt"`
+	LastPolledAt  time.Time          `json:"lastPolledAt,omitempty" bson:"lastPolledAt,omitempty"`
+	LastFetchedAt time.Time          `json:"lastFetchedAt,omitempty" bson:"lastFetchedAt,omitempty"`
+	LastError     string             `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+type RegisterFeedRequest struct {
+	URL      string `json:"url" binding:"required"`
+	Interval string `json:"interval"`
+}
+
+func createFeedIndexes() error {
+	_, err := feeds.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "url", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "nextPollAt", Value: 1}}},
+	})
+	return err
+}
+
+// RegisterFeedHandler registers a feed, or changes the interval of a registered one. The
+// feed is polled right away.
+func RegisterFeedHandler(c *gin.Context) {
+	var request RegisterFeedRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	u, err := url.ParseRequestURI(request.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an http or https URL"})
+		return
+	}
+
+	interval := defaultPollInterval
+	if request.Interval != "" {
+		interval, err = time.ParseDuration(request.Interval)
+		if err != nil || interval < minPollInterval {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be a duration of at least 1m"})
+			return
+		}
+	}
+
+	now := time.Now()
+	var feed RegisteredFeed
+	err = feeds.FindOneAndUpdate(ctx, bson.M{"url": request.URL}, bson.M{
+		"$set":         bson.M{"interval": interval.String(), "nextPollAt": now},
+		"$setOnInsert": bson.M{"createdAt": now},
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&feed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, feed)
+}
+
+func ListFeedsHandler(c *gin.Context) {
+	cur, err := feeds.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer cur.Close(ctx)
+
+	list := make([]RegisteredFeed, 0)
+	if err := cur.All(ctx, &list); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func DeleteFeedHandler(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feed not found"})
+		return
+	}
+	res, err := feeds.DeleteOne(ctx, bson.M{"_id": objectId})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if res.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feed not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Feed has been deleted"})
+}
+
+// pollFeeds publishes the due feeds every pollTick until the process exits. The connection
+// is dialed once and kept, only a channel closed by an error is opened again.
+func pollFeeds(uri, queue string) {
+	var conn *amqp.Connection
+	var channel *amqp.Channel
+	for range time.Tick(pollTick) {
+		if conn == nil || conn.IsClosed() {
+			var err error
+			if conn, err = amqp.Dial(uri); err != nil {
+				log.Printf("RabbitMQ: %v", err)
+				conn = nil
+				continue
+			}
+			channel = nil
+		}
+		if channel == nil {
+			var err error
+			if channel, err = conn.Channel(); err != nil {
+				log.Printf("RabbitMQ: %v", err)
+				conn.Close()
+				conn, channel = nil, nil
+				continue
+			}
+		}
+		if err := publishDueFeeds(channel, queue); err != nil {
+			log.Printf("Error while polling feeds: %v", err)
+			// the channel is closed after most errors, open a new one next time
+			channel.Close()
+			channel = nil
+		}
+	}
+}
+
+// publishDueFeeds claims the due feeds one at a time by moving their nextPollAt, so running
+// several parsers doesn't publish a feed more than once.
+func publishDueFeeds(channel *amqp.Channel, queue string) error {
+	for {
+		now := time.Now()
+		var feed RegisteredFeed
+		err := feeds.FindOneAndUpdate(ctx,
+			bson.M{"nextPollAt": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"lastPolledAt": now, "nextPollAt": now.Add(defaultPollInterval)}},
+		).Decode(&feed)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		interval, err := time.ParseDuration(feed.Interval)
+		if err != nil {
+			interval = defaultPollInterval
+		}
+		if _, err := feeds.UpdateOne(ctx, bson.M{"_id": feed.ID}, bson.M{"$set": bson.M{"nextPollAt": now.Add(interval)}}); err != nil {
+			return err
+		}
+
+		data, _ := json.Marshal(Request{URL: feed.URL})
+		err = channel.Publish("", queue, false, false, amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         data,
+			DeliveryMode: amqp.Persistent,
+		})
+		if err != nil {
+			// due again on the next tick
+			feeds.UpdateOne(ctx, bson.M{"_id": feed.ID}, bson.M{"$set": bson.M{"nextPollAt": now}})
+			return err
+		}
+		log.Println("Published feed", feed.URL)
+	}
+}
@@ -4,6 +4,7 @@ go 1.22
 
 require (
 	github.com/gin-gonic/gin v1.9.1
+	github.com/streadway/amqp v1.1.0
 	go.mongodb.org/mongo-driver v1.14.0
 )
 
@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"encoding/xml"
+	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
 
@@ -47,10 +49,18 @@ func GetFeedEntries(url string) ([]Entry, error) {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
 
-	byteValue, _ := ioutil.ReadAll(resp.Body)
+	byteValue, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
 	var feed Feed
-	xml.Unmarshal(byteValue, &feed)
+	if err := xml.Unmarshal(byteValue, &feed); err != nil {
+		return nil, err
+	}
 
 	return feed.Entries, nil
 }
@@ -68,13 +78,18 @@ func ParserHandler(c *gin.Context) {
 		return
 	}
 
+	// upserted by URL like the consumer does, parsing a feed twice doesn't duplicate its entries
 	collection := client.Database(os.Getenv("MONGO_DATABASE")).Collection("recipes")
 	for _, entry := range entries[2:] {
-		collection.InsertOne(ctx, bson.M{
+		_, err := collection.UpdateOne(ctx, bson.M{"url": entry.Link.Href}, bson.M{"$set": bson.M{
 			"title":     entry.Title,
 			"thumbnail": entry.Thumbnail.URL,
 			"url":       entry.Link.Href,
-		})
+		}}, options.Update().SetUpsert(true))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, entries)
@@ -83,10 +98,21 @@ func ParserHandler(c *gin.Context) {
 func init() {
 	ctx = context.Background()
 	client, _ = mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("MONGO_URI")))
+	feeds = client.Database(os.Getenv("MONGO_DATABASE")).Collection("feeds")
 }
 
 func main() {
 	router := gin.Default()
 	router.POST("/parse", ParserHandler)
+	router.POST("/feeds", RegisterFeedHandler)
+	router.GET("/feeds", ListFeedsHandler)
+	router.DELETE("/feeds/:id", DeleteFeedHandler)
+
+	if err := createFeedIndexes(); err != nil {
+		log.Fatal(err)
+	}
+	if uri := os.Getenv("RABBITMQ_URI"); uri != "" {
+		go pollFeeds(uri, os.Getenv("RABBITMQ_QUEUE"))
+	}
 	router.Run(":5000")
 }
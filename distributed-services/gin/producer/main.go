@@ -31,8 +31,9 @@ func ParserHandler(c *gin.Context) {
 		false,
 		false,
 		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        []byte(data),
+			ContentType:  "application/json",
+			Body:         []byte(data),
+			DeliveryMode: amqp.Persistent,
 		})
 	if err != nil {
 		fmt.Println(err)
@@ -0,0 +1,14 @@
+package log_v1
+
+// NextOffsetKey is the trailer a server reports its log’s next offset in: it has applied every record
+// before it. The client’s picker uses it to know which followers can serve read-your-writes consumes.
+const NextOffsetKey = "proglog-next-offset"
+
+// StalenessKey is the trailer a server reports how long ago it heard from the leader in, as a
+// duration like "12ms". The client’s picker uses it to know which followers can serve
+// bounded-staleness consumes.
+const StalenessKey = "proglog-staleness"
+
+// ForwardedForKey is the metadata a gateway that forwards a request to the leader sends its caller’s
+// subject in. The leader authorizes the caller instead of the gateway, if the gateway may forward.
+const ForwardedForKey = "proglog-forwarded-for"
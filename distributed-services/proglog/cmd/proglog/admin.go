@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	api "github.com/ahmad-khatib0/go/distributed-services/proglog/api/v1"
+	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/config"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// admin holds the connection settings shared by every `proglog admin` subcommand.
+type admin struct {
+	addr      string
+	tlsConfig config.TLSConfig
+	timeout   time.Duration
+}
+
+func newAdminCmd() *cobra.Command {
+	a := &admin{}
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Inspect and change the Raft cluster.",
+	}
+
+	cmd.PersistentFlags().StringVar(&a.addr, "addr", "127.0.0.1:8400", "RPC address of any server in the cluster.")
+	cmd.PersistentFlags().StringVar(&a.tlsConfig.CertFile, "tls-cert-file", "", "Path to client tls cert.")
+	cmd.PersistentFlags().StringVar(&a.tlsConfig.KeyFile, "tls-key-file", "", "Path to client tls key.")
+	cmd.PersistentFlags().StringVar(&a.tlsConfig.CAFile, "tls-ca-file", "", "Path to certificate authority.")
+	cmd.PersistentFlags().DurationVar(&a.timeout, "timeout", 10*time.Second, "Timeout of each RPC.")
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "state",
+			Short: "Show every server's Raft term, commit index, last applied index and lag behind the leader.",
+			Args:  cobra.NoArgs,
+			RunE:  a.state,
+		},
+		&cobra.Command{
+			Use:   "transfer-leadership [id rpc-addr]",
+			Short: "Make the leader step down, in favor of the given server if any.",
+			Args: func(cmd *cobra.Command, args []string) error {
+				if len(args) != 0 && len(args) != 2 {
+					return fmt.Errorf("accepts either no args or an id and rpc-addr, received %d", len(args))
+				}
+				return nil
+			},
+			RunE: a.transferLeadership,
+		},
+		&cobra.Command{
+			Use:   "add-voter id rpc-addr",
+			Short: "Add a voting server to the cluster.",
+			Args:  cobra.ExactArgs(2),
+			RunE:  a.addVoter,
+		},
+		&cobra.Command{
+			Use:   "add-nonvoter id rpc-addr",
+			Short: "Add a non-voting server to the cluster.",
+			Args:  cobra.ExactArgs(2),
+			RunE:  a.addNonVoter,
+		},
+		&cobra.Command{
+			Use:   "remove-server id",
+			Short: "Remove a server from the cluster.",
+			Args:  cobra.ExactArgs(1),
+			RunE:  a.removeServer,
+		},
+		&cobra.Command{
+			Use:   "snapshot",
+			Short: "Take a Raft snapshot on the leader now.",
+			Args:  cobra.NoArgs,
+			RunE:  a.snapshot,
+		},
+	)
+
+	return cmd
+}
+
+func (a *admin) dial(addr string) (*grpc.ClientConn, api.AdminClient, error) {
+	creds := insecure.NewCredentials()
+	if a.tlsConfig.CAFile != "" {
+		tlsConfig, err := config.SetupTLSConfig(a.tlsConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, api.NewAdminClient(conn), nil
+}
+
+func (a *admin) getRaftState(addr string) (*api.RaftState, error) {
+	conn, client, err := a.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+
+	res, err := client.GetRaftState(ctx, &api.GetRaftStateRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return res.State, nil
+}
+
+// leader(fn) runs fn against the leader. Any server knows who the leader is,
+// so we ask the server we were given and then dial the leader.
+func (a *admin) leader(fn func(ctx context.Context, client api.AdminClient) error) error {
+	state, err := a.getRaftState(a.addr)
+	if err != nil {
+		return err
+	}
+	if state.LeaderAddr == "" {
+		return fmt.Errorf("cluster has no leader")
+	}
+
+	conn, client, err := a.dial(state.LeaderAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+	return fn(ctx, client)
+}
+
+// state() asks each server for its own view since only a server knows how far it has applied the log,
+// and the lag of each server is how far its applied index is behind the leader’s commit index.
+func (a *admin) state(cmd *cobra.Command, args []string) error {
+	state, err := a.getRaftState(a.addr)
+	if err != nil {
+		return err
+	}
+
+	states := make([]*api.RaftState, len(state.Servers))
+	errs := make([]error, len(state.Servers))
+	var commitIndex uint64
+	for i, server := range state.Servers {
+		states[i], errs[i] = a.getRaftState(server.RpcAddr)
+		if errs[i] == nil && server.IsLeader {
+			commitIndex = states[i].CommitIndex
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tADDRESS\tSUFFRAGE\tSTATE\tTERM\tCOMMIT\tAPPLIED\tLAG")
+	for i, server := range state.Servers {
+		if errs[i] != nil {
+			fmt.Fprintf(w, "%s\t%s\t%s\tunreachable: %v\t\t\t\t\n", server.Id, server.RpcAddr, server.Suffrage, errs[i])
+			continue
+		}
+
+		s := states[i]
+		var lag uint64
+		if commitIndex > s.AppliedIndex {
+			lag = commitIndex - s.AppliedIndex
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\n",
+			server.Id, server.RpcAddr, server.Suffrage, s.State, s.Term, s.CommitIndex, s.AppliedIndex, lag,
+		)
+	}
+	return w.Flush()
+}
+
+func (a *admin) transferLeadership(cmd *cobra.Command, args []string) error {
+	req := &api.TransferLeadershipRequest{}
+	if len(args) == 2 {
+		req.Id, req.RpcAddr = args[0], args[1]
+	}
+
+	return a.leader(func(ctx context.Context, client api.AdminClient) error {
+		_, err := client.TransferLeadership(ctx, req)
+		return err
+	})
+}
+
+func (a *admin) addVoter(cmd *cobra.Command, args []string) error {
+	return a.leader(func(ctx context.Context, client api.AdminClient) error {
+		_, err := client.AddVoter(ctx, &api.AddVoterRequest{Id: args[0], RpcAddr: args[1]})
+		return err
+	})
+}
+
+func (a *admin) addNonVoter(cmd *cobra.Command, args []string) error {
+	return a.leader(func(ctx context.Context, client api.AdminClient) error {
+		_, err := client.AddNonVoter(ctx, &api.AddNonVoterRequest{Id: args[0], RpcAddr: args[1]})
+		return err
+	})
+}
+
+func (a *admin) removeServer(cmd *cobra.Command, args []string) error {
+	return a.leader(func(ctx context.Context, client api.AdminClient) error {
+		_, err := client.RemoveServer(ctx, &api.RemoveServerRequest{Id: args[0]})
+		return err
+	})
+}
+
+func (a *admin) snapshot(cmd *cobra.Command, args []string) error {
+	return a.leader(func(ctx context.Context, client api.AdminClient) error {
+		res, err := client.Snapshot(ctx, &api.SnapshotRequest{})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("snapshot taken at index %d\n", res.Index)
+		return nil
+	})
+}
@@ -24,6 +24,7 @@ func main() {
 	if err := setupFlags(cmd); err != nil {
 		log.Fatal(err)
 	}
+	cmd.AddCommand(newAdminCmd(), newVerifyCmd())
 
 	if err := cmd.Execute(); err != nil {
 		log.Fatal(err)
@@ -54,10 +55,15 @@ func setupFlags(cmd *cobra.Command) error {
 	cmd.Flags().String("node-name", hostname, "Unique server ID.")
 	cmd.Flags().String("bind-addr", "127.0.0.1:8401", "Address to bind Serf on.")
 	cmd.Flags().Int("rpc-port", 8400, "Port for RPC clients (and Raft) connections.")
+	cmd.Flags().Int("http-port", 0, "Port for the HTTP/JSON gateway, disabled when 0.")
 	cmd.Flags().StringSlice("start-join-addrs", nil, "Serf addresses to join.")
 	cmd.Flags().Bool("bootstrap", false, "Bootstrap the cluster.")
 	cmd.Flags().String("acl-model-file", "", "Path to ACL model.")
 	cmd.Flags().String("acl-policy-file", "", "Path to ACL policy.")
+	cmd.Flags().String("tokens-file", "", "Path to a JSON file mapping static bearer tokens to their subject.")
+	cmd.Flags().String("jwt-key-set-file", "", "Path to the JWK set verifying JWT bearer tokens.")
+	cmd.Flags().String("jwt-issuer", "", "Issuer JWT bearer tokens must have.")
+	cmd.Flags().String("jwt-audience", "", "Audience JWT bearer tokens must have.")
 	cmd.Flags().String("server-tls-cert-file", "", "Path to server tls cert.")
 	cmd.Flags().String("server-tls-key-file", "", "Path to server tls key.")
 	cmd.Flags().String("server-tls-ca-file", "", "Path to server certificate authority.")
@@ -87,10 +93,15 @@ func (c *cli) setupConfig(cmd *cobra.Command, args []string) error {
 	c.cfg.NodeName = viper.GetString("node-name")
 	c.cfg.BindAddr = viper.GetString("bind-addr")
 	c.cfg.RPCPort = viper.GetInt("rpc-port")
+	c.cfg.HTTPPort = viper.GetInt("http-port")
 	c.cfg.StartJoinAddrs = viper.GetStringSlice("start-join-addrs")
 	c.cfg.Bootstrap = viper.GetBool("bootstrap")
-	c.cfg.ACLModelFile = viper.GetString("acl-mode-file")
+	c.cfg.ACLModelFile = viper.GetString("acl-model-file")
 	c.cfg.ACLPolicyFile = viper.GetString("acl-policy-file")
+	c.cfg.TokensFile = viper.GetString("tokens-file")
+	c.cfg.JWTKeySetFile = viper.GetString("jwt-key-set-file")
+	c.cfg.JWTIssuer = viper.GetString("jwt-issuer")
+	c.cfg.JWTAudience = viper.GetString("jwt-audience")
 	c.cfg.ServerTLSConfig.CertFile = viper.GetString("server-tls-cert-file")
 	c.cfg.ServerTLSConfig.KeyFile = viper.GetString("server-tls-key-file")
 
@@ -1,13 +1,80 @@
 package main
 
 import (
+	"flag"
 	"log"
 
+	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/auth"
+	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/config"
+	commitlog "github.com/ahmad-khatib0/go/distributed-services/proglog/internal/log"
 	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/server"
 )
 
+// main serves the HTTP/JSON gateway over a single log that isn’t replicated, cmd/proglog runs the
+// cluster. Clients authenticate with the bearer tokens of the tokens file, JWTs, or their TLS certs.
 func main() {
+	addr := flag.String("addr", ":8080", "Address to serve the HTTP/JSON gateway on.")
+	dataDir := flag.String("data-dir", "/tmp/proglog", "Directory to store the log in.")
+	aclModelFile := flag.String("acl-model-file", config.ACLModelFile, "Path to ACL model.")
+	aclPolicyFile := flag.String("acl-policy-file", config.ACLPolicyFile, "Path to ACL policy.")
+	tokensFile := flag.String("tokens-file", "", "Path to a JSON file mapping bearer tokens to subjects.")
+	jwtKeySetFile := flag.String("jwt-key-set-file", "", "Path to the JWK set verifying JWT bearer tokens.")
+	jwtIssuer := flag.String("jwt-issuer", "", "Issuer JWT bearer tokens must have.")
+	jwtAudience := flag.String("jwt-audience", "", "Audience JWT bearer tokens must have.")
+	tlsCertFile := flag.String("tls-cert-file", "", "Path to server tls cert.")
+	tlsKeyFile := flag.String("tls-key-file", "", "Path to server tls key.")
+	tlsCAFile := flag.String("tls-ca-file", "", "Path to the certificate authority of the client certs.")
+	flag.Parse()
 
-	srv := server.NewHTTPServer(":8080")
-	log.Fatal(srv.ListenAndServe())
+	var authenticators []auth.Authenticator
+	if *tokensFile != "" {
+		tokens, err := auth.LoadTokens(*tokensFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authenticators = append(authenticators, tokens)
+	}
+	if *jwtKeySetFile != "" {
+		jwt, err := auth.NewJWT(auth.JWTConfig{
+			KeySetFile: *jwtKeySetFile,
+			Issuer:     *jwtIssuer,
+			Audience:   *jwtAudience,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		authenticators = append(authenticators, jwt)
+	}
+	// every client would be anonymous, and the ACL lets no one use the log anonymously
+	clientCerts := *tlsCertFile != "" && *tlsCAFile != ""
+	if len(authenticators) == 0 && !clientCerts {
+		log.Fatal("clients can't authenticate: set -tokens-file, -jwt-key-set-file or the tls flags")
+	}
+	authenticators = append(authenticators, auth.TLS{})
+
+	clog, err := commitlog.NewLog(*dataDir, commitlog.Config{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer clog.Close()
+
+	srv := server.NewHTTPServer(*addr, &server.Config{
+		CommitLog:     clog,
+		Authorizer:    auth.New(*aclModelFile, *aclPolicyFile),
+		Authenticator: auth.Chain(authenticators...),
+	}, nil)
+
+	if *tlsCertFile == "" {
+		log.Fatal(srv.ListenAndServe())
+	}
+	srv.TLSConfig, err = config.SetupTLSConfig(config.TLSConfig{
+		CertFile: *tlsCertFile,
+		KeyFile:  *tlsKeyFile,
+		CAFile:   *tlsCAFile,
+		Server:   true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Fatal(srv.ListenAndServeTLS("", ""))
 }
@@ -2,22 +2,30 @@ package agent
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
+	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	api "github.com/ahmad-khatib0/go/distributed-services/proglog/api/v1"
+
 	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/auth"
 	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/discovery"
+	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/loadbalance"
 	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/log"
 	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/server"
 	"github.com/hashicorp/raft"
 	"github.com/soheilhy/cmux"
+	"go.opencensus.io/stats/view"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 // Agent runs on every service instance, setting up and connecting all the different components.
@@ -26,6 +34,10 @@ type Agent struct {
 	mux          cmux.CMux
 	log          *log.DistributedLog
 	server       *grpc.Server
+	authorizer   *auth.Authorizer
+	httpServer   *http.Server
+	serverConfig *server.Config
+	forwardConn  *grpc.ClientConn
 	membership   *discovery.Membership
 	shutdown     bool
 	shutdowns    chan struct{}
@@ -39,10 +51,17 @@ type Config struct {
 	DataDir         string
 	BindAddr        string
 	RPCPort         int
+	HTTPPort        int // the HTTP/JSON gateway is only served when it’s set
 	NodeName        string
 	StartJoinAddrs  []string
 	ACLModelFile    string
 	ACLPolicyFile   string
+	// besides mTLS, clients can authenticate with the static bearer tokens of TokensFile or
+	// with JWTs signed by a key of JWTKeySetFile
+	TokensFile    string
+	JWTKeySetFile string
+	JWTIssuer     string
+	JWTAudience   string
 }
 
 func (c Config) RPCAddr() (string, error) {
@@ -64,6 +83,7 @@ func New(config Config) (*Agent, error) {
 		a.setupMux,
 		a.setupLog,
 		a.setupServer,
+		a.setupHTTPServer,
 		a.setupMembership,
 	}
 
@@ -110,6 +130,10 @@ func (a *Agent) setupLog() error {
 	logConfig.Raft.LocalID = raft.ServerID(a.Config.NodeName)
 	logConfig.Raft.Bootstrap = a.Config.Bootstrap
 
+	if err := view.Register(log.SnapshotViews...); err != nil {
+		return err
+	}
+
 	// configure and create the distributed log
 	a.log, err = log.NewDistributedLog(a.Config.DataDir, logConfig)
 	if err != nil {
@@ -124,11 +148,23 @@ func (a *Agent) setupLog() error {
 }
 
 func (a *Agent) setupServer() error {
-	authorizer := auth.New(a.Config.ACLModelFile, a.Config.ACLPolicyFile)
-	serverConfig := &server.Config{
-		CommitLog:   a.log,
-		Authorizer:  authorizer,
-		GetServerer: a.log,
+	a.authorizer = auth.New(a.Config.ACLModelFile, a.Config.ACLPolicyFile)
+	if err := a.authorizer.Watch(); err != nil {
+		return err
+	}
+
+	authenticator, err := a.setupAuthenticator()
+	if err != nil {
+		return err
+	}
+
+	a.serverConfig = &server.Config{
+		CommitLog:     a.log,
+		Authorizer:    a.authorizer,
+		GetServerer:   a.log,
+		Administrator: a.log,
+		Replica:       a.log,
+		Authenticator: authenticator,
 	}
 
 	var opts []grpc.ServerOption
@@ -137,8 +173,7 @@ func (a *Agent) setupServer() error {
 		opts = append(opts, grpc.Creds(creds))
 	}
 
-	var err error
-	a.server, err = server.NewGRPCServer(serverConfig, opts...)
+	a.server, err = server.NewGRPCServer(a.serverConfig, opts...)
 	if err != nil {
 		return err
 	}
@@ -157,6 +192,83 @@ func (a *Agent) setupServer() error {
 	return err
 }
 
+// setupAuthenticator() chains the credentials the server accepts. Bearer tokens come first so a client
+// that holds a cert, like a gateway calling on behalf of its users, can act as the token’s subject.
+func (a *Agent) setupAuthenticator() (server.Authenticator, error) {
+	var authenticators []auth.Authenticator
+	if a.Config.TokensFile != "" {
+		tokens, err := auth.LoadTokens(a.Config.TokensFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, tokens)
+	}
+	if a.Config.JWTKeySetFile != "" {
+		jwt, err := auth.NewJWT(auth.JWTConfig{
+			KeySetFile: a.Config.JWTKeySetFile,
+			Issuer:     a.Config.JWTIssuer,
+			Audience:   a.Config.JWTAudience,
+		})
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwt)
+	}
+	authenticators = append(authenticators, auth.TLS{})
+	return auth.Chain(authenticators...), nil
+}
+
+// setupHTTPServer() serves the HTTP/JSON gateway on its own port: with TLS, cmux can’t tell HTTP
+// connections from gRPC ones. The gateway forwards the produces a follower gets to the leader with a
+// client that uses our resolver and picker, like any other client of the cluster.
+func (a *Agent) setupHTTPServer() error {
+	if a.Config.HTTPPort == 0 {
+		return nil
+	}
+
+	rpcAddr, err := a.Config.RPCAddr()
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(rpcAddr)
+	if err != nil {
+		return err
+	}
+
+	creds := insecure.NewCredentials()
+	if a.Config.PeerTLSConfig != nil {
+		creds = credentials.NewTLS(a.Config.PeerTLSConfig)
+	}
+	a.forwardConn, err = grpc.Dial(
+		fmt.Sprintf("%s:///%s", loadbalance.Name, rpcAddr),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(loadbalance.ConsistencyInterceptor()),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.httpServer = server.NewHTTPServer(
+		net.JoinHostPort(host, strconv.Itoa(a.Config.HTTPPort)),
+		a.serverConfig,
+		api.NewLogClient(a.forwardConn),
+	)
+	ln, err := net.Listen("tcp", a.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	if a.Config.ServerTLSConfig != nil {
+		ln = tls.NewListener(ln, a.Config.ServerTLSConfig)
+	}
+
+	go func() {
+		if err := a.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			_ = a.Shutdown()
+		}
+	}()
+	return nil
+}
+
 func (a *Agent) setupMembership() error {
 	rpcAddr, err := a.Config.RPCAddr()
 	if err != nil {
@@ -193,6 +305,18 @@ func (a *Agent) Shutdown() error {
 		// Closing the replicator so it doesn’t continue to replicate;
 		// a.replicator.Close,
 
+		func() error {
+			if a.httpServer == nil {
+				return nil
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := a.httpServer.Shutdown(ctx); err != nil {
+				return err
+			}
+			return a.forwardConn.Close()
+		},
+
 		func() error {
 			// Gracefully stopping the server, which stops the server from accepting new connections and
 			// blocks until all the pending RPCs have finished;
@@ -201,6 +325,7 @@ func (a *Agent) Shutdown() error {
 		},
 
 		a.log.Close,
+		a.authorizer.Close,
 	}
 
 	for _, fn := range shutdown {
@@ -136,7 +136,10 @@ func TestAgent(t *testing.T) {
 
 func client(t *testing.T, agent *agent.Agent, tlsConfig *tls.Config) api.LogClient {
 	tlsCreds := credentials.NewTLS(tlsConfig)
-	opts := []grpc.DialOption{grpc.WithTransportCredentials(tlsCreds)}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(tlsCreds),
+		grpc.WithUnaryInterceptor(loadbalance.ConsistencyInterceptor()),
+	}
 	rpcAddr, err := agent.Config.RPCAddr()
 	require.NoError(t, err)
 
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//  +-----------------------------------------------------------------------------------------------+
+//  |  ┌───────────────┐                                                                           |
+//  |    Authenticators                                                                            |
+//  |  └───────────────┘                                                                           |
+//  | An authenticator finds out who’s calling from the RPC’s context: the CN of the client’s     |
+//  | certificate, a static bearer token or a JWT. The subject it returns is what the Authorizer  |
+//  | checks the policy with, however the client proved who it is.                                 |
+//  +-----------------------------------------------------------------------------------------------+
+
+// ErrNoCredentials is returned by an authenticator when the call doesn’t carry the kind of credentials
+// it checks, so the next one in the chain can try.
+var ErrNoCredentials = errors.New("no credentials")
+
+type Authenticator interface {
+	Authenticate(ctx context.Context) (subject string, err error)
+}
+
+// Chain(authenticators ...Authenticator) tries the authenticators in order and returns the subject of the
+// first one that recognizes the call’s credentials. A call without any credentials gets the empty
+// subject, which the policy doesn’t grant anything, but a bearer token nobody recognizes is rejected.
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+type chain []Authenticator
+
+func (c chain) Authenticate(ctx context.Context) (string, error) {
+	for _, a := range c {
+		subject, err := a.Authenticate(ctx)
+		if err == ErrNoCredentials {
+			continue
+		}
+		return subject, err
+	}
+
+	if bearerToken(ctx) != "" {
+		return "", status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+	return "", nil
+}
+
+// TLS authenticates the client with the CN of the certificate it verified with.
+type TLS struct{}
+
+func (TLS) Authenticate(ctx context.Context) (string, error) {
+	peer, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", status.New(codes.Unknown, "couldn't find peer info").Err()
+	}
+	tlsInfo, ok := peer.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return "", ErrNoCredentials
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName, nil
+}
+
+// Tokens authenticates the client with a static bearer token, it maps each token to its subject.
+type Tokens map[string]string
+
+// LoadTokens(path string) reads the tokens from a JSON file mapping each token to its subject:
+//
+//	{"s3cr3t": "root", "0th3r": "nobody"}
+func LoadTokens(path string) (Tokens, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens Tokens
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (t Tokens) Authenticate(ctx context.Context) (string, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return "", ErrNoCredentials
+	}
+	subject, ok := t[token]
+	if !ok {
+		// maybe it’s a JWT, or a token the next authenticator knows
+		return "", ErrNoCredentials
+	}
+	return subject, nil
+}
+
+// bearerToken(ctx context.Context) returns the token of the call’s `authorization: Bearer <token>`
+// metadata, if any.
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, v := range md.Get("authorization") {
+		scheme, token, ok := strings.Cut(v, " ")
+		if ok && strings.EqualFold(scheme, "bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
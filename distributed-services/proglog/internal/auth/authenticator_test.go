@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticate(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keySet, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "test",
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(pub),
+		}},
+	})
+	require.NoError(t, err)
+	keySetFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(keySetFile, keySet, 0644))
+
+	jwtAuth, err := NewJWT(JWTConfig{KeySetFile: keySetFile, Issuer: "issuer"})
+	require.NoError(t, err)
+
+	sign := func(claims jwt.RegisteredClaims, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+		token.Header["kid"] = kid
+		s, err := token.SignedString(priv)
+		require.NoError(t, err)
+		return s
+	}
+	valid := jwt.RegisteredClaims{
+		Subject:   "alice",
+		Issuer:    "issuer",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	otherIssuer := valid
+	otherIssuer.Issuer = "someone else"
+
+	authenticator := Chain(Tokens{"s3cr3t": "root"}, jwtAuth, TLS{})
+
+	for scenario, tc := range map[string]struct {
+		token   string
+		subject string
+		code    codes.Code
+	}{
+		"no credentials is anonymous":  {token: "", subject: ""},
+		"static token":                 {token: "s3cr3t", subject: "root"},
+		"unknown static token":         {token: "guess", code: codes.Unauthenticated},
+		"jwt":                          {token: sign(valid, "test"), subject: "alice"},
+		"expired jwt":                  {token: sign(expired, "test"), code: codes.Unauthenticated},
+		"jwt from another issuer":      {token: sign(otherIssuer, "test"), code: codes.Unauthenticated},
+		"jwt signed by an unknown key": {token: sign(valid, "other"), code: codes.Unauthenticated},
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			// a client connected without TLS
+			ctx := peer.NewContext(context.Background(), &peer.Peer{})
+			if tc.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+tc.token))
+			}
+
+			subject, err := authenticator.Authenticate(ctx)
+			if tc.code != codes.OK {
+				require.Equal(t, tc.code, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.subject, subject)
+		})
+	}
+}
@@ -2,8 +2,12 @@ package auth
 
 import (
 	"fmt"
+	"path/filepath"
+	"sync"
 
 	"github.com/casbin/casbin"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -11,15 +15,41 @@ import (
 func New(model, policy string) *Authorizer {
 
 	enforcer := casbin.NewEnforcer(model, policy)
-	return &Authorizer{enforcer: enforcer}
+	return &Authorizer{
+		model:    model,
+		policy:   policy,
+		enforcer: enforcer,
+		audit:    zap.L().Named("audit"),
+	}
 }
 
+// Authorizer checks the ACL policy. Objects are topics, the model matches them with keyMatch so a
+// policy can grant "*" (every topic) or "orders.*" (every topic starting with "orders.").
 type Authorizer struct {
+	model  string
+	policy string
+
+	mu       sync.RWMutex
 	enforcer *casbin.Enforcer
+	watcher  *fsnotify.Watcher
+
+	audit *zap.Logger
 }
 
 func (a *Authorizer) Authorize(subject, object, action string) error {
-	if !a.enforcer.Enforce(subject, object, action) {
+	a.mu.RLock()
+	allowed := a.enforcer.Enforce(subject, object, action)
+	a.mu.RUnlock()
+
+	if !allowed {
+		// every denied request goes to the audit log, so operators can see who tried what
+		a.audit.Warn(
+			"permission denied",
+			zap.String("subject", subject),
+			zap.String("object", object),
+			zap.String("action", action),
+		)
+
 		msg := fmt.Sprintf("%s not permitted to %s to %s", subject, action, object)
 
 		st := status.New(codes.PermissionDenied, msg)
@@ -27,3 +57,66 @@ func (a *Authorizer) Authorize(subject, object, action string) error {
 	}
 	return nil
 }
+
+// Reload() reads the model and policy files again. A policy that doesn’t load leaves the one we have
+// in place, a typo in the file shouldn’t lock everybody out.
+func (a *Authorizer) Reload() error {
+	enforcer, err := casbin.NewEnforcerSafe(a.model, a.policy)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.enforcer = enforcer
+	a.mu.Unlock()
+	return nil
+}
+
+// Watch() reloads the policy every time its file changes, until Close() is called. We watch the
+// file’s directory rather than the file because editors and config management tools often replace
+// the file instead of writing to it, and a watch on the old file would stop firing.
+func (a *Authorizer) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(a.policy)); err != nil {
+		watcher.Close()
+		return err
+	}
+	a.watcher = watcher
+
+	policy := filepath.Clean(a.policy)
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != policy || !event.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+				if err := a.Reload(); err != nil {
+					a.audit.Error("failed to reload policy", zap.String("policy", a.policy), zap.Error(err))
+					continue
+				}
+				a.audit.Info("reloaded policy", zap.String("policy", a.policy))
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				a.audit.Error("policy watcher", zap.Error(err))
+			}
+		}
+	}()
+	return nil
+}
+
+// Close() stops watching the policy file.
+func (a *Authorizer) Close() error {
+	if a.watcher == nil {
+		return nil
+	}
+	return a.watcher.Close()
+}
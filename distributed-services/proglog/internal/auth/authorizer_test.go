@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizerTopics(t *testing.T) {
+	policy := filepath.Join(t.TempDir(), "policy.csv")
+	require.NoError(t, os.WriteFile(policy, []byte("p, alice, orders.*, produce\n"), 0644))
+
+	authorizer := New(config.ACLModelFile, policy)
+	require.NoError(t, authorizer.Watch())
+	defer authorizer.Close()
+
+	require.NoError(t, authorizer.Authorize("alice", "orders.eu", "produce"))
+	require.Error(t, authorizer.Authorize("alice", "orders.eu", "consume"))
+	require.Error(t, authorizer.Authorize("alice", "payments", "produce"))
+	// the wildcard object is every topic, a prefix doesn’t grant it
+	require.Error(t, authorizer.Authorize("alice", "*", "produce"))
+
+	// the policy is reloaded when its file changes
+	require.NoError(t, os.WriteFile(policy, []byte("p, alice, *, consume\n"), 0644))
+	require.Eventually(t, func() bool {
+		return authorizer.Authorize("alice", "payments", "consume") == nil
+	}, 3*time.Second, 20*time.Millisecond)
+	require.Error(t, authorizer.Authorize("alice", "orders.eu", "produce"))
+}
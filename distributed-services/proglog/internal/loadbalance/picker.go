@@ -1,10 +1,15 @@
 package loadbalance
 
 import (
+	"context"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	api "github.com/ahmad-khatib0/go/distributed-services/proglog/api/v1"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/balancer/base"
 )
@@ -16,6 +21,12 @@ type Picker struct {
 	leader    balancer.SubConn
 	followers []balancer.SubConn
 	current   uint64
+	// nextOffsets is the next offset each server reported in its last consume’s trailer, it tells
+	// which followers have caught up enough to serve a read-your-writes consume.
+	nextOffsets map[balancer.SubConn]uint64
+	// staleness is how long ago each server had heard from the leader when it served its last consume,
+	// it tells which followers lag little enough to serve a bounded-staleness consume.
+	staleness map[balancer.SubConn]time.Duration
 }
 
 func init() {
@@ -27,7 +38,16 @@ func (p *Picker) Build(buildInfo base.PickerBuildInfo) balancer.Picker {
 	defer p.mu.Unlock()
 
 	var followers []balancer.SubConn
+	nextOffsets := make(map[balancer.SubConn]uint64, len(buildInfo.ReadySCs))
+	staleness := make(map[balancer.SubConn]time.Duration, len(buildInfo.ReadySCs))
 	for sc, scInfo := range buildInfo.ReadySCs {
+		if off, ok := p.nextOffsets[sc]; ok {
+			nextOffsets[sc] = off
+		}
+		if stale, ok := p.staleness[sc]; ok {
+			staleness[sc] = stale
+		}
+
 		isLeader := scInfo.Address.Attributes.Value("is_leader").(bool)
 
 		if isLeader {
@@ -38,6 +58,8 @@ func (p *Picker) Build(buildInfo base.PickerBuildInfo) balancer.Picker {
 	}
 
 	p.followers = followers
+	p.nextOffsets = nextOffsets
+	p.staleness = staleness
 	return p
 }
 
@@ -56,16 +78,47 @@ func (p *Picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
 		result.SubConn = p.leader
 
 	} else if strings.Contains(info.FullMethodName, "Consume") {
-		result.SubConn = p.nextFollower()
+		result.SubConn = p.pickConsume(info.Ctx)
 	}
 
 	if result.SubConn == nil {
 		return result, balancer.ErrNoSubConnAvailable
 	}
 
+	result.Done = p.done(result.SubConn)
 	return result, nil
 }
 
+// pickConsume(ctx context.Context) picks a server that can serve the consume with the consistency it asks
+// for. The leader is always up to date, so it’s where we fall back when no follower is known to be.
+func (p *Picker) pickConsume(ctx context.Context) balancer.SubConn {
+	var req *api.ConsumeRequest
+	if ctx != nil {
+		req, _ = ctx.Value(consumeRequestKey{}).(*api.ConsumeRequest)
+	}
+	if req == nil {
+		return p.nextFollower()
+	}
+
+	switch req.Consistency {
+	case api.Consistency_CONSISTENCY_LEADER:
+		return p.leader
+	case api.Consistency_CONSISTENCY_READ_YOUR_WRITES:
+		if sc := p.nextFollowerAt(req.MinOffset); sc != nil {
+			return sc
+		}
+		return p.leader
+	case api.Consistency_CONSISTENCY_BOUNDED_STALENESS:
+		// a follower that turns out staler than it last reported waits to hear from the leader
+		if sc := p.nextFollowerWithin(req.MaxStaleness.AsDuration()); sc != nil {
+			return sc
+		}
+		return p.leader
+	default:
+		return p.nextFollower()
+	}
+}
+
 // nextFollower() balance the consume calls across the followers with the round-robin algorithm.
 func (p *Picker) nextFollower() balancer.SubConn {
 	cur := atomic.AddUint64(&p.current, uint64(1))
@@ -74,3 +127,74 @@ func (p *Picker) nextFollower() balancer.SubConn {
 	idx := int(cur % len)
 	return p.followers[idx]
 }
+
+// nextFollowerAt(offset uint64) round-robins over the followers that reported having the record at the
+// given offset, it returns nil when none did.
+func (p *Picker) nextFollowerAt(offset uint64) balancer.SubConn {
+	cur := atomic.AddUint64(&p.current, uint64(1))
+	for i := range p.followers {
+		sc := p.followers[(int(cur)+i)%len(p.followers)]
+		if p.nextOffsets[sc] > offset {
+			return sc
+		}
+	}
+	return nil
+}
+
+// nextFollowerWithin(staleness time.Duration) round-robins over the followers that reported having heard
+// from the leader within the given staleness, it returns nil when none did.
+func (p *Picker) nextFollowerWithin(staleness time.Duration) balancer.SubConn {
+	cur := atomic.AddUint64(&p.current, uint64(1))
+	for i := range p.followers {
+		sc := p.followers[(int(cur)+i)%len(p.followers)]
+		if stale, ok := p.staleness[sc]; ok && stale <= staleness {
+			return sc
+		}
+	}
+	return nil
+}
+
+// done(sc balancer.SubConn) returns the callback gRPC calls when an RPC on the subconnection finishes,
+// it records the next offset and the staleness the server sent in the trailer.
+func (p *Picker) done(sc balancer.SubConn) func(balancer.DoneInfo) {
+	return func(info balancer.DoneInfo) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.nextOffsets == nil {
+			return
+		}
+
+		if values := info.Trailer.Get(api.NextOffsetKey); len(values) > 0 {
+			off, err := strconv.ParseUint(values[0], 10, 64)
+			if err == nil && off > p.nextOffsets[sc] {
+				p.nextOffsets[sc] = off
+			}
+		}
+		if values := info.Trailer.Get(api.StalenessKey); len(values) > 0 {
+			if stale, err := time.ParseDuration(values[0]); err == nil {
+				p.staleness[sc] = stale
+			}
+		}
+	}
+}
+
+type consumeRequestKey struct{}
+
+// ConsistencyInterceptor() is a unary client interceptor that lets the picker honor the consistency of
+// consume calls. gRPC gives Pick() the RPC’s context but not its request, so we put the request in it.
+// Clients that dial with our resolver install it with grpc.WithUnaryInterceptor().
+func ConsistencyInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if consume, ok := req.(*api.ConsumeRequest); ok {
+			ctx = context.WithValue(ctx, consumeRequestKey{}, consume)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -1,14 +1,24 @@
 package loadbalance_test
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
 	"testing"
+	"time"
 
+	api "github.com/ahmad-khatib0/go/distributed-services/proglog/api/v1"
 	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/loadbalance"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/attributes"
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/resolver"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 // TestPickerNoSubConnAvailable() tests that a picker initially returns balancer.ErrNoSubConnAvailable
@@ -58,6 +68,155 @@ func TestPickerConsumesFromFollowers(t *testing.T) {
 	}
 }
 
+// TestPickerConsumeConsistency() tests that the picker routes consume calls that ask for a consistency
+// to the servers that can serve them.
+func TestPickerConsumeConsistency(t *testing.T) {
+	picker, subConns := setupTest()
+
+	pick := func(req *api.ConsumeRequest) balancer.PickResult {
+		var ctx context.Context
+		invoker := func(c context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			ctx = c
+			return nil
+		}
+		err := loadbalance.ConsistencyInterceptor()(context.Background(), "/log.vX.Log/Consume", req, nil, nil, invoker)
+		require.NoError(t, err)
+
+		result, err := picker.Pick(balancer.PickInfo{FullMethodName: "/log.vX.Log/Consume", Ctx: ctx})
+		require.NoError(t, err)
+		return result
+	}
+
+	for i := 0; i < 5; i++ {
+		result := pick(&api.ConsumeRequest{Consistency: api.Consistency_CONSISTENCY_LEADER})
+		require.Same(t, subConns[0], result.SubConn)
+	}
+
+	// no follower reported having offset 3 yet
+	result := pick(&api.ConsumeRequest{Consistency: api.Consistency_CONSISTENCY_READ_YOUR_WRITES, MinOffset: 3})
+	require.Same(t, subConns[0], result.SubConn)
+
+	// a follower that has the record reports it in the trailer of a consume it served
+	result = pick(&api.ConsumeRequest{})
+	follower := result.SubConn
+	require.NotSame(t, subConns[0], follower)
+	result.Done(balancer.DoneInfo{Trailer: metadata.Pairs(api.NextOffsetKey, "4")})
+
+	for i := 0; i < 5; i++ {
+		result := pick(&api.ConsumeRequest{Consistency: api.Consistency_CONSISTENCY_READ_YOUR_WRITES, MinOffset: 3})
+		require.Same(t, follower, result.SubConn)
+	}
+	result = pick(&api.ConsumeRequest{Consistency: api.Consistency_CONSISTENCY_READ_YOUR_WRITES, MinOffset: 4})
+	require.Same(t, subConns[0], result.SubConn)
+
+	// the followers that lag more than the consume allows are skipped
+	result.Done(balancer.DoneInfo{Trailer: metadata.Pairs(api.StalenessKey, "0s")})
+	result = pick(&api.ConsumeRequest{})
+	result.Done(balancer.DoneInfo{Trailer: metadata.Pairs(api.StalenessKey, "50ms")})
+	lagging := result.SubConn
+	result = pick(&api.ConsumeRequest{})
+	result.Done(balancer.DoneInfo{Trailer: metadata.Pairs(api.StalenessKey, "5s")})
+	stale := result.SubConn
+	require.NotSame(t, lagging, stale)
+
+	bounded := func(staleness time.Duration) *api.ConsumeRequest {
+		return &api.ConsumeRequest{
+			Consistency:  api.Consistency_CONSISTENCY_BOUNDED_STALENESS,
+			MaxStaleness: durationpb.New(staleness),
+		}
+	}
+	for i := 0; i < 5; i++ {
+		result := pick(bounded(time.Second))
+		require.Same(t, lagging, result.SubConn)
+	}
+	result = pick(bounded(time.Millisecond))
+	require.Same(t, subConns[0], result.SubConn)
+}
+
+// TestPickerDialedClient() tests that a client dialed with our resolver, picker and interceptor sends the
+// consumes to the servers that can serve their consistency.
+func TestPickerDialedClient(t *testing.T) {
+	leader := &logServer{name: "leader", nextOffset: 10}
+	follower := &logServer{name: "follower", nextOffset: 5, staleness: 20 * time.Millisecond}
+	leaderAddr := serve(t, leader)
+	followerAddr := serve(t, follower)
+	leader.servers = []*api.Server{
+		{Id: "leader", RpcAddr: leaderAddr, IsLeader: true},
+		{Id: "follower", RpcAddr: followerAddr},
+	}
+
+	conn, err := grpc.Dial(
+		fmt.Sprintf("%s:///%s", loadbalance.Name, leaderAddr),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(loadbalance.ConsistencyInterceptor()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := api.NewLogClient(conn)
+
+	consume := func(req *api.ConsumeRequest) string {
+		res, err := client.Consume(context.Background(), req)
+		require.NoError(t, err)
+		return string(res.Record.Value)
+	}
+
+	// the picker only balances across the followers once their subconnection is ready
+	require.Eventually(t, func() bool {
+		return consume(&api.ConsumeRequest{}) == "follower"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, "leader", consume(&api.ConsumeRequest{Consistency: api.Consistency_CONSISTENCY_LEADER}))
+
+	readYourWrites := func(offset uint64) *api.ConsumeRequest {
+		return &api.ConsumeRequest{Consistency: api.Consistency_CONSISTENCY_READ_YOUR_WRITES, MinOffset: offset}
+	}
+	require.Equal(t, "follower", consume(readYourWrites(4)))
+	require.Equal(t, "leader", consume(readYourWrites(5)))
+
+	bounded := func(staleness time.Duration) *api.ConsumeRequest {
+		return &api.ConsumeRequest{
+			Consistency:  api.Consistency_CONSISTENCY_BOUNDED_STALENESS,
+			MaxStaleness: durationpb.New(staleness),
+		}
+	}
+	require.Equal(t, "follower", consume(bounded(time.Second)))
+	require.Equal(t, "leader", consume(bounded(time.Millisecond)))
+}
+
+func serve(t *testing.T, srv api.LogServer) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	gsrv := grpc.NewServer()
+	api.RegisterLogServer(gsrv, srv)
+	go gsrv.Serve(l)
+	t.Cleanup(gsrv.Stop)
+
+	return l.Addr().String()
+}
+
+// logServer implements the consumes of api.LogServer with a record naming the server that served it,
+// and reports its log’s progress in the trailer like our server does.
+type logServer struct {
+	api.UnimplementedLogServer
+	name       string
+	nextOffset uint64
+	staleness  time.Duration
+	servers    []*api.Server
+}
+
+func (s *logServer) Consume(ctx context.Context, req *api.ConsumeRequest) (*api.ConsumeResponse, error) {
+	_ = grpc.SetTrailer(ctx, metadata.Pairs(
+		api.NextOffsetKey, strconv.FormatUint(s.nextOffset, 10),
+		api.StalenessKey, s.staleness.String(),
+	))
+	return &api.ConsumeResponse{Record: &api.Record{Value: []byte(s.name)}}, nil
+}
+
+func (s *logServer) GetServers(ctx context.Context, req *api.GetServersRequest) (*api.GetServersResponse, error) {
+	return &api.GetServersResponse{Servers: s.servers}, nil
+}
+
 func setupTest() (*loadbalance.Picker, []*subConn) {
 	var subConns []*subConn
 
@@ -68,8 +227,7 @@ func setupTest() (*loadbalance.Picker, []*subConn) {
 		addr := resolver.Address{Attributes: attributes.New("is_leader", i == 0)}
 
 		sc.UpdateAddresses([]resolver.Address{addr})
-		// FIX:
-		// buildInfo.ReadySCs[sc] = base.SubConnInfo{Address: addr}
+		buildInfo.ReadySCs[sc] = base.SubConnInfo{Address: addr}
 
 		subConns = append(subConns, sc)
 	}
@@ -82,6 +240,7 @@ func setupTest() (*loadbalance.Picker, []*subConn) {
 
 // subConn implements balancer.SubConn.
 type subConn struct {
+	balancer.SubConn
 	addrs []resolver.Address
 }
 
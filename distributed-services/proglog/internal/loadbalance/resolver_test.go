@@ -2,6 +2,7 @@ package loadbalance_test
 
 import (
 	"net"
+	"net/url"
 	"testing"
 
 	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/config"
@@ -57,7 +58,7 @@ func TestResolver(t *testing.T) {
 	r := &loadbalance.Resolver{}
 
 	// configures the target end-point to point to the server we set up in first step
-	_, err = r.Build(resolver.Target{URL: l.Addr().String()}, conn, opts)
+	_, err = r.Build(resolver.Target{URL: url.URL{Path: l.Addr().String()}}, conn, opts)
 	require.NoError(t, err)
 
 	wantState := resolver.State{
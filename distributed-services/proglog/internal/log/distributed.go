@@ -2,15 +2,19 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/raft"
+	"go.opencensus.io/stats"
 	"google.golang.org/protobuf/proto"
 
 	api "github.com/ahmad-khatib0/go/distributed-services/proglog/api/v1"
@@ -20,6 +24,7 @@ import (
 type DistributedLog struct {
 	config Config
 	log    *Log
+	fsm    *fsm
 	raft   *raft.Raft
 }
 
@@ -50,7 +55,7 @@ func (l *DistributedLog) setupLog(dataDir string) error {
 
 // setupRaft(dataDir string) configures and creates the server’s Raft instance.
 func (l *DistributedLog) setupRaft(dataDir string) error {
-	fsm := &fsm{log: l.log} // creating finite-state-machine (FSM)
+	l.fsm = &fsm{log: l.log} // creating finite-state-machine (FSM)
 
 	logDir := filepath.Join(dataDir, "raft", "log")
 	if err := os.MkdirAll(logDir, 0755); err != nil {
@@ -109,7 +114,7 @@ func (l *DistributedLog) setupRaft(dataDir string) error {
 	}
 
 	// create the Raft instance and bootstrap the cluster:
-	l.raft, err = raft.NewRaft(config, fsm, logStore, stableStore, snapshotStore, transport)
+	l.raft, err = raft.NewRaft(config, l.fsm, logStore, stableStore, snapshotStore, transport)
 	if err != nil {
 		return err
 	}
@@ -193,11 +198,101 @@ func (l *DistributedLog) Read(offset uint64) (*api.Record, error) {
 	return l.log.Read(offset)
 }
 
+//  +-----------------------------------------------------------------------------------------------+
+//  |  ┌─────────────┐                                                                             |
+//  |    Consistency                                                                               |
+//  |  └─────────────┘                                                                             |
+//  | Followers apply the records the leader commits a little later, so a read on a follower can   |
+//  | miss a record that was just produced. These let the server wait until its log is fresh       |
+//  | enough for what the client asked for before reading.                                         |
+//  +-----------------------------------------------------------------------------------------------+
+
+// IsLeader() reports whether this server is the Raft leader.
+func (l *DistributedLog) IsLeader() bool {
+	return l.raft.State() == raft.Leader
+}
+
+// NextOffset() returns the offset of the next record this server will apply, it has applied every
+// record before it.
+func (l *DistributedLog) NextOffset() uint64 {
+	return l.log.nextOffset()
+}
+
+// WaitForOffset(ctx context.Context, offset uint64) blocks until this server has applied the record at
+// offset, or ctx is done.
+func (l *DistributedLog) WaitForOffset(ctx context.Context, offset uint64) error {
+	for {
+		// we take the channel before checking so we can't miss an apply in between
+		applied := l.fsm.appliedCh()
+		if offset < l.log.nextOffset() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-applied:
+		}
+	}
+}
+
+// Staleness() returns how long ago this server heard from the leader, false if it never did.
+// The leader is never stale.
+func (l *DistributedLog) Staleness() (time.Duration, bool) {
+	if l.IsLeader() {
+		return 0, true
+	}
+	last := l.raft.LastContact()
+	if last.IsZero() {
+		return 0, false
+	}
+	return time.Since(last), true
+}
+
+// WaitForLeaderContact(ctx context.Context, staleness time.Duration) blocks until this server has
+// heard from the leader within the given staleness, or ctx is done.
+func (l *DistributedLog) WaitForLeaderContact(ctx context.Context, staleness time.Duration) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if stale, ok := l.Staleness(); ok && stale <= staleness {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
 var _ raft.FSM = (*fsm)(nil)
 
 // fsm finite-state-machine
 type fsm struct {
 	log *Log
+
+	mu      sync.Mutex
+	applied chan struct{} // closed every time the log changes, to wake up the readers waiting on it
+}
+
+// appliedCh() returns a channel that’s closed the next time the FSM applies to the log.
+func (f *fsm) appliedCh() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.applied == nil {
+		f.applied = make(chan struct{})
+	}
+	return f.applied
+}
+
+func (f *fsm) notifyApplied() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.applied != nil {
+		close(f.applied)
+		f.applied = nil
+	}
 }
 
 var _ raft.LogStore = (*logStore)(nil)
@@ -289,6 +384,7 @@ func (l *fsm) applyAppend(b []byte) interface{} {
 	if err != nil {
 		return err
 	}
+	l.notifyApplied()
 
 	return &api.ProduceResponse{Offset: offset}
 }
@@ -302,70 +398,59 @@ func (l *fsm) applyAppend(b []byte) interface{} {
 // SnapshotInterval (how often Raft checks if it should snapshot—default is two minutes) and
 // SnapshotThreshold  (how many logs since the last snapshot before making a new snapshot—default is 8192).
 func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
-	r := f.log.Reader()
-
-	// call Reader() to return an io.Reader that will read all the log’s data
-	return &snapshot{reader: r}, nil
+	// rather than reading the whole log record by record, we capture its segment files as they are
+	segments, err := f.log.snapshotSegments()
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot{segments: segments}, nil
 }
 
 var _ raft.FSMSnapshot = (*snapshot)(nil)
 
 type snapshot struct {
-	reader io.Reader
+	segments []*segmentSnapshot
 }
 
 // Raft calls Persist() on the FSMSnapshot we created to write its state to some sink that, depending on
 // the snapshot store we configured Raft with, could be in-memory, a file, an S3 bucket—something
 // to store the bytes in.
 func (s *snapshot) Persist(sink raft.SnapshotSink) error {
-	if _, err := io.Copy(sink, s.reader); err != nil {
+	start := time.Now()
+	n, err := writeSegments(sink, s.segments)
+	if err != nil {
 		_ = sink.Cancel()
 		return err
 	}
+
+	stats.Record(context.Background(),
+		SnapshotSize.M(n),
+		SnapshotDuration.M(float64(time.Since(start))/float64(time.Millisecond)),
+	)
 	return sink.Close()
 }
 
 // Raft calls Release() when it’s finished with the snapshot.
-func (s *snapshot) Release() {}
+func (s *snapshot) Release() {
+	closeSegmentSnapshots(s.segments)
+}
 
 // Raft calls Restore() to restore an FSM from a snapshot
-// In our Restore() implementation, we reset the log and configure its initial offset
-// to the first record’s offset we read from the snapshot so the log’s offsets match.
-// Then we read the records in the snapshot and append them to our new log.
+// In our Restore() implementation, we keep the segments our log already has in common with the
+// snapshot, which is most of them for a server that fell behind, and copy the rest as they are.
 func (f *fsm) Restore(r io.ReadCloser) error {
-	b := make([]byte, lenWidth)
-	var buf bytes.Buffer
-
-	for i := 0; ; i++ {
-		_, err := io.ReadFull(r, b)
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return err
-		}
-
-		size := int64(enc.Uint64(b))
-		if _, err = io.CopyN(&buf, r, size); err != nil {
-			return err
-		}
-
-		record := &api.Record{}
-		if err = proto.Unmarshal(buf.Bytes(), record); err != nil {
-			return err
-		}
-
-		if i == 0 {
-			f.log.Config.Segment.InitialOffset = record.Offset
-			if err := f.log.Reset(); err != nil {
-				return err
-			}
-		}
-
-		if _, err = f.log.Append(record); err != nil {
-			return err
-		}
-		buf.Reset()
+	start := time.Now()
+	written, skipped, err := f.log.restoreSegments(r)
+	if err != nil {
+		return err
 	}
+	f.notifyApplied()
+
+	stats.Record(context.Background(),
+		RestoreSize.M(written),
+		RestoreSkipped.M(skipped),
+		RestoreDuration.M(float64(time.Since(start))/float64(time.Millisecond)),
+	)
 	return nil
 }
 
@@ -480,6 +565,16 @@ func (s *StreamLayer) Addr() net.Addr {
 //
 // └─────────────────────────────────────────────────────────────────────────────────────────────────────┘
 func (l *DistributedLog) Join(id, addr string) error {
+	return l.join(id, addr, true)
+}
+
+// JoinNonVoter(id, addr string) adds the server to the Raft cluster as a non-voter, it gets the replicated
+// log but takes no part in elections or in deciding when an entry is committed.
+func (l *DistributedLog) JoinNonVoter(id, addr string) error {
+	return l.join(id, addr, false)
+}
+
+func (l *DistributedLog) join(id, addr string, voter bool) error {
 	configFuture := l.raft.GetConfiguration()
 	if err := configFuture.Error(); err != nil {
 		return err
@@ -504,7 +599,12 @@ func (l *DistributedLog) Join(id, addr string) error {
 		}
 	}
 
-	addFuture := l.raft.AddVoter(serverID, serverAddr, 0, 0)
+	var addFuture raft.IndexFuture
+	if voter {
+		addFuture = l.raft.AddVoter(serverID, serverAddr, 0, 0)
+	} else {
+		addFuture = l.raft.AddNonvoter(serverID, serverAddr, 0, 0)
+	}
 	if err := addFuture.Error(); err != nil {
 		return err
 	}
@@ -559,7 +659,86 @@ func (l *DistributedLog) GetServers() ([]*api.Server, error) {
 			Id:       string(server.ID),
 			RpcAddr:  string(server.Address),
 			IsLeader: l.raft.Leader() == server.Address,
+			Suffrage: server.Suffrage.String(),
 		})
 	}
 	return servers, nil
 }
+
+//+-------------------------------------------------------------------------------------------------+
+//|  ┌────────────────┐                                                                             |
+//|    Administration                                                                               |
+//|  └────────────────┘                                                                             |
+//| Besides Serf-driven discovery, operators can inspect and change the cluster by hand through the |
+//| Admin gRPC service. Every change to the cluster must happen on the leader, otherwise Raft       |
+//| returns raft.ErrNotLeader                                                                       |
+//+-------------------------------------------------------------------------------------------------+
+
+// RaftState() returns this server’s view of the Raft cluster. Followers apply entries after the leader
+// commits them, so comparing a follower’s applied index with the leader’s commit index gives its lag.
+func (l *DistributedLog) RaftState() (*api.RaftState, error) {
+	stats := l.raft.Stats()
+
+	var err error
+	parse := func(key string) uint64 {
+		if err != nil {
+			return 0
+		}
+		var v uint64
+		v, err = strconv.ParseUint(stats[key], 10, 64)
+		return v
+	}
+	term := parse("term")
+	commitIndex := parse("commit_index")
+	lastSnapshotIndex := parse("last_snapshot_index")
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := l.GetServers()
+	if err != nil {
+		return nil, err
+	}
+
+	leaderAddr, leaderID := l.raft.LeaderWithID()
+	return &api.RaftState{
+		Id:                string(l.config.Raft.LocalID),
+		RpcAddr:           l.config.Raft.BindAddr,
+		State:             l.raft.State().String(),
+		Term:              term,
+		CommitIndex:       commitIndex,
+		AppliedIndex:      l.raft.AppliedIndex(),
+		LastLogIndex:      l.raft.LastIndex(),
+		LastSnapshotIndex: lastSnapshotIndex,
+		LeaderId:          string(leaderID),
+		LeaderAddr:        string(leaderAddr),
+		Servers:           servers,
+	}, nil
+}
+
+// TransferLeadership(id, addr string) makes the leader step down in favor of the given server, with an
+// empty id Raft picks the most up to date follower itself.
+func (l *DistributedLog) TransferLeadership(id, addr string) error {
+	if id == "" {
+		return l.raft.LeadershipTransfer().Error()
+	}
+	return l.raft.LeadershipTransferToServer(raft.ServerID(id), raft.ServerAddress(addr)).Error()
+}
+
+// Snapshot() tells Raft to take a snapshot now instead of waiting for the SnapshotInterval and
+// SnapshotThreshold to kick in, and returns the index of the last entry the snapshot covers.
+func (l *DistributedLog) Snapshot() (uint64, error) {
+	future := l.raft.Snapshot()
+	if err := future.Error(); err != nil {
+		return 0, err
+	}
+
+	meta, r, err := future.Open()
+	if err != nil {
+		return 0, err
+	}
+	if err := r.Close(); err != nil {
+		return 0, err
+	}
+	return meta.Index, nil
+}
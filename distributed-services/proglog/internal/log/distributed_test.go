@@ -1,6 +1,7 @@
 package log_test
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -131,3 +132,108 @@ func TestMultipleNodes(t *testing.T) {
 	require.Equal(t, []byte("third"), record.Value)
 	require.Equal(t, off, record.Offset)
 }
+
+func TestAdministration(t *testing.T) {
+	var logs []*log.DistributedLog
+	nodeCount := 3
+	ports := dynaport.Get(nodeCount)
+
+	for i := 0; i < nodeCount; i++ {
+		dataDir, err := ioutil.TempDir("", "distributed-log-admin-test")
+		require.NoError(t, err)
+		defer func(dir string) {
+			_ = os.RemoveAll(dir)
+		}(dataDir)
+		ln, err := net.Listen(
+			"tcp",
+			fmt.Sprintf("127.0.0.1:%d", ports[i]),
+		)
+		require.NoError(t, err)
+
+		config := log.Config{}
+		config.Raft.StreamLayer = log.NewStreamLayer(ln, nil, nil)
+		config.Raft.LocalID = raft.ServerID(fmt.Sprintf("%d", i))
+		config.Raft.HeartbeatTimeout = 50 * time.Millisecond
+		config.Raft.ElectionTimeout = 50 * time.Millisecond
+		config.Raft.LeaderLeaseTimeout = 50 * time.Millisecond
+		config.Raft.CommitTimeout = 5 * time.Millisecond
+		config.Raft.BindAddr = ln.Addr().String()
+		config.Raft.Bootstrap = i == 0
+
+		l, err := log.NewDistributedLog(dataDir, config)
+		require.NoError(t, err)
+		defer l.Close()
+
+		switch i {
+		case 0:
+			require.NoError(t, l.WaitForLeader(3*time.Second))
+		case 1:
+			require.NoError(t, logs[0].Join("1", ln.Addr().String()))
+		case 2:
+			// the third server only gets the replicated log
+			require.NoError(t, logs[0].JoinNonVoter("2", ln.Addr().String()))
+		}
+
+		logs = append(logs, l)
+	}
+
+	off, err := logs[0].Append(&api.Record{Value: []byte("first")})
+	require.NoError(t, err)
+
+	// a follower can wait to have applied a record before reading it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, logs[2].WaitForOffset(ctx, off))
+	require.Equal(t, off+1, logs[2].NextOffset())
+	require.NoError(t, logs[2].WaitForLeaderContact(ctx, time.Second))
+	require.True(t, logs[0].IsLeader())
+	require.False(t, logs[2].IsLeader())
+
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer shortCancel()
+	require.Equal(t, context.DeadlineExceeded, logs[2].WaitForOffset(shortCtx, off+1))
+
+	require.Eventually(t, func() bool {
+		state, err := logs[0].RaftState()
+		if err != nil {
+			return false
+		}
+		for _, l := range logs[1:] {
+			s, err := l.RaftState()
+			if err != nil || s.AppliedIndex < state.CommitIndex {
+				return false
+			}
+		}
+		return true
+	}, 500*time.Millisecond, 50*time.Millisecond)
+
+	state, err := logs[0].RaftState()
+	require.NoError(t, err)
+	require.Equal(t, "0", state.Id)
+	require.Equal(t, raft.Leader.String(), state.State)
+	require.Equal(t, "0", state.LeaderId)
+	require.NotZero(t, state.Term)
+	require.Equal(t, 3, len(state.Servers))
+	require.Equal(t, raft.Voter.String(), state.Servers[1].Suffrage)
+	require.Equal(t, raft.Nonvoter.String(), state.Servers[2].Suffrage)
+
+	index, err := logs[0].Snapshot()
+	require.NoError(t, err)
+	require.True(t, index > 0)
+
+	// only the leader can change the cluster
+	err = logs[1].JoinNonVoter("3", "127.0.0.1:0")
+	require.Equal(t, raft.ErrNotLeader, err)
+
+	err = logs[0].TransferLeadership("1", state.Servers[1].RpcAddr)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		state, err := logs[1].RaftState()
+		return err == nil && state.State == raft.Leader.String()
+	}, 3*time.Second, 50*time.Millisecond)
+
+	record, err := logs[2].Read(off)
+	require.NoError(t, err)
+	require.Equal(t, []byte("first"), record.Value)
+}
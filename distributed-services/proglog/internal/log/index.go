@@ -40,9 +40,37 @@ func newIndex(f *os.File, c Config) (*index, error) {
 	); err != nil {
 		return nil, err
 	}
+
+	//╒════════════════════════════════════════════════════════════════════════════════════════════════════════╕
+	//  If the service crashed, Close() never truncated the file back, so its size is the max index size and
+	//  not the amount of data in it. We don’t trust the file’s size, and count the entries in it instead.
+	//╘════════════════════════════════════════════════════════════════════════════════════════════════════════╛
+	if idx.size > uint64(len(idx.mmap)) {
+		idx.size = uint64(len(idx.mmap))
+	}
+	idx.size = indexEntries(idx.mmap[:idx.size]) * entWidth
 	return idx, nil
 }
 
+// indexEntries(b []byte) returns the number of entries in the index data b, ignoring a partially written
+// entry at the end and the zeroed space a crash leaves behind. Only the first entry can be all zeros,
+// since it’s the only one with a relative offset and a position of 0.
+func indexEntries(b []byte) uint64 {
+	n := nearestMultiple(uint64(len(b)), entWidth) / entWidth
+	for n > 1 {
+		if enc.Uint32(b[(n-1)*entWidth:]) != 0 {
+			break
+		}
+		n--
+	}
+	return n
+}
+
+// Reset() empties the index so it can be rebuilt from its store.
+func (i *index) Reset() {
+	i.size = 0
+}
+
 // Close() makes sure the memory-mapped file has synced its data to the persisted
 // file and that the persisted file has flushed its contents to stable storage. Then
 // it truncates the persisted file to the amount of data that’s actually in it and
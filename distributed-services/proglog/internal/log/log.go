@@ -66,6 +66,16 @@ func (l *Log) setup() error {
 		if err = l.newSegment(l.Config.Segment.InitialOffset); err != nil {
 			return err
 		}
+		return nil
+	}
+
+	// only the active segment was being written to, so only its tail can be torn
+	if err = l.activeSegment.recover(); err != nil {
+		return err
+	}
+	// a segment whose index filled up can’t take another record
+	if l.activeSegment.IsMaxed() {
+		return l.newSegment(l.activeSegment.nextOffset)
 	}
 	return nil
 }
@@ -78,6 +88,10 @@ func (l *Log) Append(record *api.Record) (uint64, error) {
 		return 0, err
 	}
 	if l.activeSegment.IsMaxed() {
+		// we only recover the active segment on restart, so the segment we’re sealing must be on disk
+		if err = l.activeSegment.store.Flush(); err != nil {
+			return 0, err
+		}
 		err = l.newSegment(off + 1)
 	}
 	return off, err
@@ -134,6 +148,11 @@ func (l *Log) Reset() error {
 	if err := l.Remove(); err != nil {
 		return err
 	}
+	// the removed segments are closed, setup starts over from the empty directory
+	l.segments, l.activeSegment = nil, nil
+	if err := os.MkdirAll(l.Dir, 0755); err != nil {
+		return err
+	}
 	return l.setup()
 }
 
@@ -153,6 +172,13 @@ func (l *Log) HighestOffset() (uint64, error) {
 	return off - 1, nil
 }
 
+// nextOffset() returns the offset the next appended record will get.
+func (l *Log) nextOffset() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.activeSegment.nextOffset
+}
+
 // Truncate(lowest uint64) removes all segments whose highest offset is lower than lowest.
 func (l *Log) Truncate(lowest uint64) error {
 	l.mu.Lock()
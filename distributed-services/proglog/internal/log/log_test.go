@@ -19,6 +19,9 @@ func TestLog(t *testing.T) {
 		"init with existing segments":       testInitExisting,
 		"reader":                            testReader,
 		"truncate":                          testTruncate,
+		"recover from a torn write":         testRecoverTornWrite,
+		"refuse a corrupt committed record": testRecoverCorrupt,
+		"roll a segment with a full index":  testRecoverFullIndex,
 	} {
 		t.Run(scenario, func(t *testing.T) {
 			dir, err := ioutil.TempDir("", "store-test")
@@ -102,8 +105,11 @@ func testReader(t *testing.T, log *Log) {
 	b, err := ioutil.ReadAll(reader)
 	require.NoError(t, err)
 
+	// the first segment’s store starts with its magic, then the record’s length and checksum
+	n := enc.Uint64(b[magicWidth:])
+	start := uint64(magicWidth + lenWidth + crcWidth)
 	read := &api.Record{}
-	err = proto.Unmarshal(b[lenWidth:], read)
+	err = proto.Unmarshal(b[start:start+n], read)
 	require.NoError(t, err)
 	require.Equal(t, append.Value, read.Value)
 }
@@ -125,3 +131,98 @@ func testTruncate(t *testing.T, log *Log) {
 	_, err = log.Read(0)
 	require.Error(t, err)
 }
+
+// testRecoverTornWrite(*testing.T, *log.Log) tests that a log which crashed halfway through appending a
+// record drops the partial record when it restarts, and keeps appending after the last complete one.
+func testRecoverTornWrite(t *testing.T, o *Log) {
+	append := &api.Record{
+		Value: []byte("hello world"),
+	}
+	for i := 0; i < 3; i++ {
+		_, err := o.Append(append)
+		require.NoError(t, err)
+	}
+
+	// the log is never closed, so its index files keep their max size like after a crash
+	s := o.activeSegment
+	require.NoError(t, s.store.Flush())
+	_, err := s.store.File.Write([]byte{0, 0, 0, 0, 0, 0, 0, 42, 1, 2, 3})
+	require.NoError(t, err)
+
+	n, err := NewLog(o.Dir, o.Config)
+	require.NoError(t, err)
+
+	off, err := n.HighestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), off)
+
+	for i := uint64(0); i < 3; i++ {
+		read, err := n.Read(i)
+		require.NoError(t, err)
+		require.Equal(t, append.Value, read.Value)
+	}
+
+	off, err = n.Append(append)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), off)
+
+	read, err := n.Read(off)
+	require.NoError(t, err)
+	require.Equal(t, append.Value, read.Value)
+}
+
+// testRecoverCorrupt(*testing.T, *log.Log) tests that a log with a corrupt record in front of other
+// records refuses to start rather than truncating the committed records after it.
+func testRecoverCorrupt(t *testing.T, l *Log) {
+	c := l.Config
+	c.Segment.MaxStoreBytes = 1024
+	o, err := NewLog(l.Dir, c)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := o.Append(&api.Record{Value: []byte("hello")})
+		require.NoError(t, err)
+	}
+
+	s := o.activeSegment
+	require.NoError(t, s.store.Flush())
+	f, err := os.OpenFile(s.store.Name(), os.O_RDWR, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{'H'}, int64(magicWidth+lenWidth+crcWidth))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	size := s.store.size
+
+	_, err = NewLog(o.Dir, o.Config)
+	require.Error(t, err)
+
+	fi, err := os.Stat(s.store.Name())
+	require.NoError(t, err)
+	require.Equal(t, int64(size), fi.Size())
+}
+
+// testRecoverFullIndex(*testing.T, *log.Log) tests that restarting with a smaller index than the active
+// segment's records need keeps the records, and rolls a new segment instead of appending to the full one.
+func testRecoverFullIndex(t *testing.T, l *Log) {
+	c := l.Config
+	c.Segment.MaxStoreBytes = 1024
+	o, err := NewLog(l.Dir, c)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := o.Append(&api.Record{Value: []byte("hi")})
+		require.NoError(t, err)
+	}
+	require.NoError(t, o.activeSegment.store.Flush())
+	size := o.activeSegment.store.size
+
+	c.Segment.MaxIndexBytes = entWidth
+	n, err := NewLog(o.Dir, c)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(n.segments))
+	require.Equal(t, size, n.segments[0].store.size)
+
+	off, err := n.Append(&api.Record{Value: []byte("hi")})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), off)
+}
@@ -1,7 +1,9 @@
 package log
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
 
@@ -91,6 +93,9 @@ func (s *segment) Read(off uint64) (*api.Record, error) {
 		return nil, err
 	}
 	p, err := s.store.Read(pos)
+	if err == errCorrupt {
+		return nil, api.ErrCorruptRecord{Offset: off}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -99,6 +104,50 @@ func (s *segment) Read(off uint64) (*api.Record, error) {
 	return record, err
 }
 
+// errIndexFull stops rebuilding the index once it has no room left.
+var errIndexFull = errors.New("index full")
+
+// recover() rebuilds the index from the store. A crash can leave a torn record at the end of the store,
+// or records the index never got an entry for, so we run it on the segment we were appending to. Only a
+// torn tail is dropped: a corrupt record with more records after it is committed data we won’t truncate,
+// so we refuse to start until proglog verify has been looked at. When the index fills up before the end
+// of the store, which happens when MaxIndexBytes was lowered, the records it can’t hold are left in the
+// store and the segment is maxed, so the log rolls a new one.
+func (s *segment) recover() error {
+	s.index.Reset()
+	var rel uint32
+	end, err := scanRecords(s.store, s.store.size, s.store.legacy, func(pos uint64, _ []byte) error {
+		if err := s.index.Write(rel, pos); err != nil {
+			if err == io.EOF {
+				return errIndexFull
+			}
+			return err
+		}
+		rel++
+		return nil
+	})
+	s.nextOffset = s.baseOffset + uint64(rel)
+
+	switch err {
+	case nil, errIndexFull:
+		return nil
+	case errCorrupt:
+		torn, err := isTornTail(s.store, end, s.store.size, s.store.legacy)
+		if err != nil {
+			return err
+		}
+		if !torn {
+			return fmt.Errorf(
+				"segment %d: record at offset %d (position %d) is corrupt and isn't the last one, run proglog verify",
+				s.baseOffset, s.nextOffset, end,
+			)
+		}
+		return s.store.Truncate(end)
+	default:
+		return err
+	}
+}
+
 // IsMaxed() returns whether the segment has reached its max size
 func (s *segment) IsMaxed() bool {
 	return s.store.size >= s.config.Segment.MaxStoreBytes ||
@@ -0,0 +1,260 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+//  ╒═══════════════════════════════════════════════════════════════════════════════════════════════════════╕
+//  │ A snapshot is the log’s segment files copied as they are on disk, instead of its records one by one. │
+//  │ Sealed segments never change, so a server that restores a snapshot keeps the segments it already    │
+//  │ has and only writes the ones it’s missing, without decoding or re-appending a single record.         │
+//  │                                                                                                       │
+//  │   version | segment count | for each segment: base offset | next offset | store size | index size    │
+//  │                                             | store bytes | index bytes                               │
+//  ╘═══════════════════════════════════════════════════════════════════════════════════════════════════════╛
+
+const snapshotVersion uint8 = 1
+
+// segmentMetaWidth is the width of a segment’s metadata in a snapshot: base offset, next offset,
+// store size and index size.
+const segmentMetaWidth = 4 * 8
+
+var (
+	SnapshotSize     = stats.Int64("proglog/snapshot/size", "Bytes persisted to a Raft snapshot", stats.UnitBytes)
+	SnapshotDuration = stats.Float64("proglog/snapshot/duration", "Time to persist a Raft snapshot", stats.UnitMilliseconds)
+	RestoreSize      = stats.Int64("proglog/restore/size", "Bytes written to the log restoring a Raft snapshot", stats.UnitBytes)
+	RestoreSkipped   = stats.Int64("proglog/restore/skipped", "Bytes of a Raft snapshot the log already had", stats.UnitBytes)
+	RestoreDuration  = stats.Float64("proglog/restore/duration", "Time to restore a Raft snapshot", stats.UnitMilliseconds)
+)
+
+var (
+	bytesDistribution = view.Distribution(1<<10, 1<<15, 1<<20, 1<<25, 1<<30, 1<<35)
+	msDistribution    = view.Distribution(1, 10, 100, 1000, 10000, 60000, 600000)
+)
+
+// SnapshotViews are the views of the snapshot metrics, register them to export the metrics.
+var SnapshotViews = []*view.View{
+	{Measure: SnapshotSize, Aggregation: bytesDistribution},
+	{Measure: SnapshotDuration, Aggregation: msDistribution},
+	{Measure: RestoreSize, Aggregation: bytesDistribution},
+	{Measure: RestoreSkipped, Aggregation: bytesDistribution},
+	{Measure: RestoreDuration, Aggregation: msDistribution},
+}
+
+// segmentSnapshot is a segment’s files as of when the snapshot was taken. The log only appends to its
+// files, so the first storeSize and indexSize bytes won’t change while we copy them.
+type segmentSnapshot struct {
+	baseOffset uint64
+	nextOffset uint64
+	storeSize  uint64
+	indexSize  uint64
+	store      *os.File
+	index      *os.File
+}
+
+// snapshotSegments() captures the segments’ sizes and opens their files. Raft calls Snapshot() between
+// applies, so it must be quick: the copying happens later in Persist(), concurrently with new appends.
+func (l *Log) snapshotSegments() ([]*segmentSnapshot, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// the active segment may have records only in the store’s buffer
+	if err := l.activeSegment.store.Flush(); err != nil {
+		return nil, err
+	}
+
+	segments := make([]*segmentSnapshot, 0, len(l.segments))
+	for _, s := range l.segments {
+		ss := &segmentSnapshot{
+			baseOffset: s.baseOffset,
+			nextOffset: s.nextOffset,
+			storeSize:  s.store.size,
+			indexSize:  s.index.size,
+		}
+		var err error
+		if ss.store, err = os.Open(s.store.Name()); err == nil {
+			ss.index, err = os.Open(s.index.Name())
+		}
+		segments = append(segments, ss)
+		if err != nil {
+			closeSegmentSnapshots(segments)
+			return nil, err
+		}
+	}
+	return segments, nil
+}
+
+func closeSegmentSnapshots(segments []*segmentSnapshot) {
+	for _, ss := range segments {
+		if ss.store != nil {
+			ss.store.Close()
+		}
+		if ss.index != nil {
+			ss.index.Close()
+		}
+	}
+}
+
+// writeSegments(w io.Writer, segments []*segmentSnapshot) writes the segments in the snapshot format
+// and returns the number of bytes written.
+func writeSegments(w io.Writer, segments []*segmentSnapshot) (int64, error) {
+	header := make([]byte, 1+8)
+	header[0] = snapshotVersion
+	enc.PutUint64(header[1:], uint64(len(segments)))
+	n, err := w.Write(header)
+	written := int64(n)
+	if err != nil {
+		return written, err
+	}
+
+	meta := make([]byte, segmentMetaWidth)
+	for _, ss := range segments {
+		enc.PutUint64(meta[0:8], ss.baseOffset)
+		enc.PutUint64(meta[8:16], ss.nextOffset)
+		enc.PutUint64(meta[16:24], ss.storeSize)
+		enc.PutUint64(meta[24:32], ss.indexSize)
+		n, err := w.Write(meta)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+
+		for _, section := range []*io.SectionReader{
+			io.NewSectionReader(ss.store, 0, int64(ss.storeSize)),
+			io.NewSectionReader(ss.index, 0, int64(ss.indexSize)),
+		} {
+			n, err := io.Copy(w, section)
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// restoreSegments(r io.Reader) makes the log’s segments match the snapshot’s. Segments the log already has
+// with the same offsets and size are kept and their bytes skipped, the others are written straight to
+// disk, and segments the snapshot doesn’t have are removed. It returns the bytes written and skipped.
+func (l *Log) restoreSegments(r io.Reader) (written, skipped int64, err error) {
+	header := make([]byte, 1+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, err
+	}
+	if header[0] != snapshotVersion {
+		return 0, 0, fmt.Errorf("unsupported snapshot version %d", header[0])
+	}
+	count := enc.Uint64(header[1:])
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	local := make(map[uint64]*segment, len(l.segments))
+	for _, s := range l.segments {
+		local[s.baseOffset] = s
+	}
+
+	var segments []*segment
+	meta := make([]byte, segmentMetaWidth)
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, meta); err != nil {
+			return written, skipped, err
+		}
+		baseOffset := enc.Uint64(meta[0:8])
+		nextOffset := enc.Uint64(meta[8:16])
+		storeSize := enc.Uint64(meta[16:24])
+		indexSize := enc.Uint64(meta[24:32])
+
+		if s, ok := local[baseOffset]; ok {
+			delete(local, baseOffset)
+			if s.nextOffset == nextOffset && s.store.size == storeSize {
+				n, err := io.CopyN(io.Discard, r, int64(storeSize+indexSize))
+				skipped += n
+				if err != nil {
+					return written, skipped, err
+				}
+				segments = append(segments, s)
+				continue
+			}
+			if err := s.Remove(); err != nil {
+				return written, skipped, err
+			}
+		}
+
+		n, err := l.writeSegmentFiles(r, baseOffset, storeSize, indexSize)
+		written += n
+		if err != nil {
+			return written, skipped, err
+		}
+
+		c := l.Config
+		if c.Segment.MaxIndexBytes < indexSize {
+			c.Segment.MaxIndexBytes = indexSize
+		}
+		s, err := newSegment(l.Dir, baseOffset, c)
+		if err != nil {
+			return written, skipped, err
+		}
+		segments = append(segments, s)
+	}
+
+	// whatever the snapshot doesn’t have isn’t part of the state we’re restoring to
+	for _, s := range local {
+		if err := s.Remove(); err != nil {
+			return written, skipped, err
+		}
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].baseOffset < segments[j].baseOffset
+	})
+	l.segments = segments
+	if len(segments) == 0 {
+		s, err := newSegment(l.Dir, l.Config.Segment.InitialOffset, l.Config)
+		if err != nil {
+			return written, skipped, err
+		}
+		l.segments = append(l.segments, s)
+	}
+	l.activeSegment = l.segments[len(l.segments)-1]
+	l.Config.Segment.InitialOffset = l.segments[0].baseOffset
+	return written, skipped, nil
+}
+
+// writeSegmentFiles(r io.Reader, baseOffset, storeSize, indexSize uint64) copies a segment’s store and
+// index from the snapshot into the log’s directory.
+func (l *Log) writeSegmentFiles(r io.Reader, baseOffset, storeSize, indexSize uint64) (int64, error) {
+	var written int64
+	for _, f := range []struct {
+		ext  string
+		size uint64
+	}{{".store", storeSize}, {".index", indexSize}} {
+		file, err := os.OpenFile(
+			path.Join(l.Dir, fmt.Sprintf("%d%s", baseOffset, f.ext)),
+			os.O_RDWR|os.O_CREATE|os.O_TRUNC,
+			0644,
+		)
+		if err != nil {
+			return written, err
+		}
+		n, err := io.CopyN(file, r, int64(f.size))
+		written += n
+		if err == nil {
+			err = file.Sync()
+		}
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
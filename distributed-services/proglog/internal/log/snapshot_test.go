@@ -0,0 +1,117 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	api "github.com/ahmad-khatib0/go/distributed-services/proglog/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T, c Config, leader *Log, snapshot []byte){
+		"restore onto an empty log":                 testRestoreEmpty,
+		"restore onto a log with a prefix":          testRestorePrefix,
+		"restore onto a log that diverged":          testRestoreDiverged,
+		"snapshot of the log restores the same log": testRestoreRoundTrip,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "snapshot-test")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			c := Config{}
+			c.Segment.MaxStoreBytes = 64
+			leader, err := NewLog(dir, c)
+			require.NoError(t, err)
+			appendRecords(t, leader, 0, 10)
+
+			segments, err := leader.snapshotSegments()
+			require.NoError(t, err)
+			defer closeSegmentSnapshots(segments)
+
+			var buf bytes.Buffer
+			n, err := writeSegments(&buf, segments)
+			require.NoError(t, err)
+			require.Equal(t, int64(buf.Len()), n)
+
+			fn(t, c, leader, buf.Bytes())
+		})
+	}
+}
+
+func appendRecords(t *testing.T, l *Log, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		off, err := l.Append(&api.Record{Value: []byte{byte(i)}})
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), off)
+	}
+}
+
+func newFollower(t *testing.T, c Config) *Log {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "snapshot-test-follower")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	l, err := NewLog(dir, c)
+	require.NoError(t, err)
+	return l
+}
+
+func requireRecords(t *testing.T, l *Log, n int) {
+	t.Helper()
+	off, err := l.HighestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(n-1), off)
+	for i := 0; i < n; i++ {
+		record, err := l.Read(uint64(i))
+		require.NoError(t, err)
+		require.Equal(t, []byte{byte(i)}, record.Value)
+	}
+
+	// the restored log keeps appending where the snapshot ended
+	off, err = l.Append(&api.Record{Value: []byte{byte(n)}})
+	require.NoError(t, err)
+	require.Equal(t, uint64(n), off)
+}
+
+func testRestoreEmpty(t *testing.T, c Config, _ *Log, snapshot []byte) {
+	follower := newFollower(t, c)
+
+	written, skipped, err := follower.restoreSegments(bytes.NewReader(snapshot))
+	require.NoError(t, err)
+	require.NotZero(t, written)
+	require.Zero(t, skipped)
+	requireRecords(t, follower, 10)
+}
+
+func testRestorePrefix(t *testing.T, c Config, _ *Log, snapshot []byte) {
+	follower := newFollower(t, c)
+	appendRecords(t, follower, 0, 5)
+
+	written, skipped, err := follower.restoreSegments(bytes.NewReader(snapshot))
+	require.NoError(t, err)
+	require.NotZero(t, skipped)
+	require.True(t, written < int64(len(snapshot)))
+	requireRecords(t, follower, 10)
+}
+
+func testRestoreDiverged(t *testing.T, c Config, _ *Log, snapshot []byte) {
+	follower := newFollower(t, c)
+	appendRecords(t, follower, 0, 12)
+
+	_, _, err := follower.restoreSegments(bytes.NewReader(snapshot))
+	require.NoError(t, err)
+	requireRecords(t, follower, 10)
+}
+
+func testRestoreRoundTrip(t *testing.T, _ Config, leader *Log, snapshot []byte) {
+	written, skipped, err := leader.restoreSegments(bytes.NewReader(snapshot))
+	require.NoError(t, err)
+	require.Zero(t, written)
+	require.NotZero(t, skipped)
+	requireRecords(t, leader, 10)
+}
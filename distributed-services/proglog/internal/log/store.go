@@ -2,19 +2,40 @@ package log
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
 	"os"
 	"sync"
 )
 
 var enc = binary.BigEndian // the encoding that we persist record sizes and index entries in
 const lenWidth = 8         // the number of bytes used to store the record’s length
+const crcWidth = 4         // the number of bytes used to store the record’s checksum
+
+// storeMagic starts every store file written since records have a checksum. The files without it are
+// from before, their records are only a length and the data, and we keep reading and appending to them
+// in that format. Read as a record length the magic is exabytes, so an older file can’t start with it.
+var storeMagic = []byte{'p', 'r', 'o', 'g', 'l', 'o', 'g', 2}
+
+const magicWidth = 8 // the number of bytes of the magic at the start of the store
+
+// crcTable is the CRC-32 Castagnoli polynomial, which modern CPUs compute in hardware
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errCorrupt is returned when a record’s checksum doesn’t match its data, or its length points past
+// the end of the store, which is what a torn write after a crash looks like.
+var errCorrupt = errors.New("corrupt record")
 
 type store struct {
 	*os.File
 	mu   sync.Mutex
 	buf  *bufio.Writer
 	size uint64
+	// legacy is set for the stores written before records had a checksum
+	legacy bool
 }
 
 func newStore(f *os.File) (*store, error) {
@@ -26,12 +47,61 @@ func newStore(f *os.File) (*store, error) {
 	if err != nil {
 		return nil, err
 	}
-	size := uint64(fi.Size())
-	return &store{
+	s := &store{
 		File: f,
-		size: size,
+		size: uint64(fi.Size()),
 		buf:  bufio.NewWriter(f),
-	}, nil
+	}
+
+	s.legacy, err = isLegacyStore(f, s.size)
+	if err != nil {
+		return nil, err
+	}
+	// a new store, or one that crashed before its magic made it to disk
+	if !s.legacy && s.size < magicWidth {
+		if err := f.Truncate(0); err != nil {
+			return nil, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(storeMagic); err != nil {
+			return nil, err
+		}
+		s.size = magicWidth
+	}
+	return s, nil
+}
+
+// isLegacyStore(r io.ReaderAt, size uint64) returns whether the store of the given size was written
+// before records had a checksum. A store too short to hold the magic is a new one when what it has
+// is the start of the magic.
+func isLegacyStore(r io.ReaderAt, size uint64) (bool, error) {
+	b := make([]byte, magicWidth)
+	if size < magicWidth {
+		b = b[:size]
+	}
+	if _, err := r.ReadAt(b, 0); err != nil && err != io.EOF {
+		return false, err
+	}
+	return !bytes.HasPrefix(storeMagic, b), nil
+}
+
+// recordHeaderWidth(legacy bool) returns the number of bytes in front of a record’s data: its length,
+// and its checksum unless it’s in a legacy store.
+func recordHeaderWidth(legacy bool) uint64 {
+	if legacy {
+		return lenWidth
+	}
+	return lenWidth + crcWidth
+}
+
+// firstRecord(legacy bool) returns the position of the first record in a store.
+func firstRecord(legacy bool) uint64 {
+	if legacy {
+		return 0
+	}
+	return magicWidth
 }
 
 func (s *store) Append(p []byte) (n uint64, pos uint64, err error) {
@@ -42,6 +112,13 @@ func (s *store) Append(p []byte) (n uint64, pos uint64, err error) {
 		return 0, 0, err
 	}
 
+	// the checksum lets us tell a record apart from garbage left by a write that didn’t make it to disk
+	if !s.legacy {
+		if err := binary.Write(s.buf, enc, crc32.Checksum(p, crcTable)); err != nil {
+			return 0, 0, err
+		}
+	}
+
 	//  ╒═══════════════════════════════════════════════════════════════════════════════╕
 	//    We write to the buffered writer instead of directly to the file to reduce the
 	//    number of system calls and improve performance.
@@ -54,7 +131,7 @@ func (s *store) Append(p []byte) (n uint64, pos uint64, err error) {
 	//  ╒════════════════════════════════════════════════════════════════════════════════════════════════════╕
 	//    We write the length of the record so that, when we read the record, we know how many bytes to read
 	//  ╘════════════════════════════════════════════════════════════════════════════════════════════════════╛
-	w += lenWidth
+	w += int(recordHeaderWidth(s.legacy))
 	s.size += uint64(w)
 	return uint64(w), pos, nil
 }
@@ -70,17 +147,94 @@ func (s *store) Read(pos uint64) ([]byte, error) {
 	if err := s.buf.Flush(); err != nil {
 		return nil, err
 	}
-	size := make([]byte, lenWidth)
-	if _, err := s.File.ReadAt(size, int64(pos)); err != nil {
+	return readRecord(s.File, pos, s.size, s.legacy)
+}
+
+// readRecord(r io.ReaderAt, pos, size uint64, legacy bool) reads the record at pos out of a store of the
+// given size and validates its checksum. The records of a legacy store have none to validate.
+func readRecord(r io.ReaderAt, pos, size uint64, legacy bool) ([]byte, error) {
+	width := recordHeaderWidth(legacy)
+	if size < pos || size-pos < width {
+		return nil, errCorrupt
+	}
+	header := make([]byte, width)
+	if _, err := r.ReadAt(header, int64(pos)); err != nil {
 		return nil, err
 	}
-	b := make([]byte, enc.Uint64(size))
-	if _, err := s.File.ReadAt(b, int64(pos+lenWidth)); err != nil {
+
+	// check the length before allocating for it, a torn length could be anything
+	n := enc.Uint64(header[:lenWidth])
+	if n > size-pos-width {
+		return nil, errCorrupt
+	}
+	b := make([]byte, n)
+	if _, err := r.ReadAt(b, int64(pos+width)); err != nil {
 		return nil, err
 	}
+	if !legacy && crc32.Checksum(b, crcTable) != enc.Uint32(header[lenWidth:]) {
+		return nil, errCorrupt
+	}
 	return b, nil
 }
 
+// isTornTail(r io.ReaderAt, pos, size uint64, legacy bool) returns whether the corrupt record at pos is
+// the last thing in the store, which is what a write cut short by a crash leaves. A corrupt record with
+// more data after it is damage to records that were already committed.
+func isTornTail(r io.ReaderAt, pos, size uint64, legacy bool) (bool, error) {
+	width := recordHeaderWidth(legacy)
+	if size-pos < width {
+		return true, nil
+	}
+	header := make([]byte, lenWidth)
+	if _, err := r.ReadAt(header, int64(pos)); err != nil {
+		return false, err
+	}
+	return enc.Uint64(header) >= size-pos-width, nil
+}
+
+// scanRecords(r io.ReaderAt, size uint64, legacy bool, fn) calls fn with the position and data of every
+// record in a store of the given size, in order. It returns the position right after the last valid
+// record, and errCorrupt if it stopped early because of a record it couldn’t read.
+func scanRecords(r io.ReaderAt, size uint64, legacy bool, fn func(pos uint64, p []byte) error) (uint64, error) {
+	pos := firstRecord(legacy)
+	for pos < size {
+		p, err := readRecord(r, pos, size, legacy)
+		if err != nil {
+			return pos, err
+		}
+		if err := fn(pos, p); err != nil {
+			return pos, err
+		}
+		pos += recordHeaderWidth(legacy) + uint64(len(p))
+	}
+	return pos, nil
+}
+
+// Flush() writes the buffered records to the file and syncs it to stable storage.
+func (s *store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	return s.File.Sync()
+}
+
+// Truncate(size uint64) drops everything in the store after size, used to get rid of a partially
+// written record after a crash.
+func (s *store) Truncate(size uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if err := s.File.Truncate(int64(size)); err != nil {
+		return err
+	}
+	s.size = size
+	return nil
+}
+
 func (s *store) ReadAt(p []byte, off int64) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"hash/crc32"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -10,7 +11,7 @@ import (
 
 var (
 	write = []byte("hello world")
-	width = uint64(len(write)) + lenWidth
+	width = uint64(len(write)) + lenWidth + crcWidth
 )
 
 func TestStoreAppendRead(t *testing.T) {
@@ -39,13 +40,13 @@ func testAppend(t *testing.T, s *store) {
 	for i := uint64(1); i < 4; i++ {
 		n, pos, err := s.Append(write)
 		require.NoError(t, err)
-		require.Equal(t, pos+n, width*i)
+		require.Equal(t, pos+n, magicWidth+width*i)
 	}
 }
 
 func testRead(t *testing.T, s *store) {
 	t.Helper()
-	var pos uint64
+	pos := uint64(magicWidth)
 	for i := uint64(1); i < 4; i++ {
 		read, err := s.Read(pos)
 		require.NoError(t, err)
@@ -56,23 +57,98 @@ func testRead(t *testing.T, s *store) {
 
 func testReadAt(t *testing.T, s *store) {
 	t.Helper()
-	for i, off := uint64(1), int64(0); i < 4; i++ {
-		b := make([]byte, lenWidth)
+	for i, off := uint64(1), int64(magicWidth); i < 4; i++ {
+		b := make([]byte, lenWidth+crcWidth)
 		n, err := s.ReadAt(b, off)
 		require.NoError(t, err)
-		require.Equal(t, lenWidth, n)
+		require.Equal(t, lenWidth+crcWidth, n)
 		off += int64(n)
 
-		size := enc.Uint64(b)
+		size := enc.Uint64(b[:lenWidth])
+		sum := enc.Uint32(b[lenWidth:])
 		b = make([]byte, size)
 		n, err = s.ReadAt(b, off)
 		require.NoError(t, err)
 		require.Equal(t, write, b)
 		require.Equal(t, int(size), n)
+		require.Equal(t, crc32.Checksum(write, crcTable), sum)
 		off += int64(n)
 	}
 }
 
+func TestStoreCorrupt(t *testing.T) {
+	f, err := ioutil.TempFile("", "store_corrupt_test")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	f, _, err = openFile(f.Name())
+	require.NoError(t, err)
+	s, err := newStore(f)
+	require.NoError(t, err)
+	testAppend(t, s)
+	require.NoError(t, s.Flush())
+
+	// flip a byte of the second record’s data
+	c, err := os.OpenFile(f.Name(), os.O_RDWR, 0644)
+	require.NoError(t, err)
+	_, err = c.WriteAt([]byte{'H'}, int64(magicWidth+width+lenWidth+crcWidth))
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+
+	_, err = s.Read(magicWidth)
+	require.NoError(t, err)
+	_, err = s.Read(magicWidth + width)
+	require.Equal(t, errCorrupt, err)
+
+	end, err := scanRecords(s, s.size, false, func(uint64, []byte) error { return nil })
+	require.Equal(t, errCorrupt, err)
+	require.Equal(t, magicWidth+width, end)
+
+	// the third record comes after it, so it isn’t a torn write
+	torn, err := isTornTail(s, end, s.size, false)
+	require.NoError(t, err)
+	require.False(t, torn)
+
+	require.NoError(t, s.Truncate(end))
+	_, _, err = s.Append(write)
+	require.NoError(t, err)
+	read, err := s.Read(magicWidth + width)
+	require.NoError(t, err)
+	require.Equal(t, write, read)
+}
+
+func TestStoreLegacy(t *testing.T) {
+	f, err := ioutil.TempFile("", "store_legacy_test")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	// a store written before records had a checksum: their length, then their data
+	for i := 0; i < 2; i++ {
+		b := make([]byte, lenWidth)
+		enc.PutUint64(b, uint64(len(write)))
+		_, err = f.Write(append(b, write...))
+		require.NoError(t, err)
+	}
+
+	s, err := newStore(f)
+	require.NoError(t, err)
+	require.True(t, s.legacy)
+
+	_, pos, err := s.Append(write)
+	require.NoError(t, err)
+	require.Equal(t, 2*(lenWidth+uint64(len(write))), pos)
+
+	var positions []uint64
+	end, err := scanRecords(s, s.size, true, func(pos uint64, p []byte) error {
+		require.Equal(t, write, p)
+		positions = append(positions, pos)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, s.size, end)
+	require.Equal(t, []uint64{0, lenWidth + uint64(len(write)), pos}, positions)
+}
+
 func TestStoreClose(t *testing.T) {
 	f, err := ioutil.TempFile("", "store_close_test")
 	require.NoError(t, err)
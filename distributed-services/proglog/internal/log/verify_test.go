@@ -0,0 +1,60 @@
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	api "github.com/ahmad-khatib0/go/distributed-services/proglog/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := Config{}
+	c.Segment.MaxStoreBytes = 40
+	l, err := NewLog(dir, c)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := l.Append(&api.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Close())
+
+	reports, err := Verify(dir)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(reports))
+	for _, report := range reports {
+		require.NoError(t, report.Err)
+	}
+	require.Equal(t, uint64(2), reports[0].Records)
+	require.Equal(t, uint64(2), reports[1].BaseOffset)
+	require.Equal(t, uint64(1), reports[1].Records)
+
+	// flip the last byte of the first segment’s second record
+	f, err := os.OpenFile(path.Join(dir, fmt.Sprintf("%d.store", 0)), os.O_RDWR, 0644)
+	require.NoError(t, err)
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, fi.Size()-1)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	reports, err = Verify(dir)
+	require.NoError(t, err)
+	require.Error(t, reports[0].Err)
+	require.Equal(t, uint64(1), reports[0].Records)
+	require.NoError(t, reports[1].Err)
+
+	// reading the record through the log reports it too
+	l, err = NewLog(dir, c)
+	require.NoError(t, err)
+	_, err = l.Read(1)
+	require.Equal(t, api.ErrCorruptRecord{Offset: 1}, err)
+}
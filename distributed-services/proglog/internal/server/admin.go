@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+
+	api "github.com/ahmad-khatib0/go/distributed-services/proglog/api/v1"
+	"github.com/hashicorp/raft"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const adminAction = "admin"
+
+var _ api.AdminServer = (*adminServer)(nil)
+
+// adminServer serves the Admin service next to the Log service on the same gRPC server,
+// every RPC checks the caller is allowed to administer the cluster before touching Raft.
+type adminServer struct {
+	api.UnimplementedAdminServer
+	*Config
+}
+
+func newAdminServer(config *Config) *adminServer {
+	return &adminServer{Config: config}
+}
+
+// Administrator is implemented by DistributedLog. Like GetServerer, we keep it out of the CommitLog
+// interface because a non-distributed log has no cluster to administer.
+type Administrator interface {
+	RaftState() (*api.RaftState, error)
+	TransferLeadership(id, addr string) error
+	Join(id, addr string) error
+	JoinNonVoter(id, addr string) error
+	Leave(id string) error
+	Snapshot() (uint64, error)
+}
+
+func (s *adminServer) GetRaftState(ctx context.Context, req *api.GetRaftStateRequest) (*api.GetRaftStateResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, adminAction); err != nil {
+		return nil, err
+	}
+
+	state, err := s.Administrator.RaftState()
+	if err != nil {
+		return nil, adminError(err)
+	}
+	return &api.GetRaftStateResponse{State: state}, nil
+}
+
+func (s *adminServer) TransferLeadership(ctx context.Context, req *api.TransferLeadershipRequest) (*api.TransferLeadershipResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, adminAction); err != nil {
+		return nil, err
+	}
+
+	if err := s.Administrator.TransferLeadership(req.Id, req.RpcAddr); err != nil {
+		return nil, adminError(err)
+	}
+	return &api.TransferLeadershipResponse{}, nil
+}
+
+func (s *adminServer) AddVoter(ctx context.Context, req *api.AddVoterRequest) (*api.AddVoterResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, adminAction); err != nil {
+		return nil, err
+	}
+	if req.Id == "" || req.RpcAddr == "" {
+		return nil, status.Error(codes.InvalidArgument, "id and rpc_addr are required")
+	}
+
+	if err := s.Administrator.Join(req.Id, req.RpcAddr); err != nil {
+		return nil, adminError(err)
+	}
+	return &api.AddVoterResponse{}, nil
+}
+
+func (s *adminServer) AddNonVoter(ctx context.Context, req *api.AddNonVoterRequest) (*api.AddNonVoterResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, adminAction); err != nil {
+		return nil, err
+	}
+	if req.Id == "" || req.RpcAddr == "" {
+		return nil, status.Error(codes.InvalidArgument, "id and rpc_addr are required")
+	}
+
+	if err := s.Administrator.JoinNonVoter(req.Id, req.RpcAddr); err != nil {
+		return nil, adminError(err)
+	}
+	return &api.AddNonVoterResponse{}, nil
+}
+
+func (s *adminServer) RemoveServer(ctx context.Context, req *api.RemoveServerRequest) (*api.RemoveServerResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, adminAction); err != nil {
+		return nil, err
+	}
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	if err := s.Administrator.Leave(req.Id); err != nil {
+		return nil, adminError(err)
+	}
+	return &api.RemoveServerResponse{}, nil
+}
+
+func (s *adminServer) Snapshot(ctx context.Context, req *api.SnapshotRequest) (*api.SnapshotResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, adminAction); err != nil {
+		return nil, err
+	}
+
+	index, err := s.Administrator.Snapshot()
+	if err != nil {
+		return nil, adminError(err)
+	}
+	return &api.SnapshotResponse{Index: index}, nil
+}
+
+// adminError(err error) maps the Raft errors an operator can act on to gRPC codes, so the CLI can tell
+// "ask the leader instead" apart from a real failure.
+func adminError(err error) error {
+	switch err {
+	case raft.ErrNotLeader, raft.ErrLeadershipTransferInProgress, raft.ErrNothingNewToSnapshot:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case raft.ErrUnsupportedProtocol:
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+	return err
+}
@@ -1,19 +1,57 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	api "github.com/ahmad-khatib0/go/distributed-services/proglog/api/v1"
 	"github.com/gorilla/mux"
+	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
-func NewHTTPServer(addr string) *http.Server {
+//  ╒════════════════════════════════════════════════════════════════════════════════════════════════╕
+//  │ The HTTP/JSON gateway lets services that don’t speak gRPC use the log. It isn’t a second       │
+//  │ implementation: every request is authenticated by the same Authenticator and served by the    │
+//  │ same grpcServer methods, so the ACLs and the consistency levels work the same way. Bodies are │
+//  │ the API’s messages in the proto3 JSON mapping, e.g. a record’s value is base64.                │
+//  │                                                                                                │
+//  │   POST /v1/records                  {"record": {"value": "aGVsbG8=", "topic": "orders"}}      │
+//  │   GET  /v1/records/{offset}         ?topic=&consistency=&min_offset=&max_staleness=&wait=     │
+//  │   GET  /v1/servers                                                                             │
+//  ╘════════════════════════════════════════════════════════════════════════════════════════════════╛
 
-	httpsrv := newHTTPServer()
+// maxRequestBytes is the gRPC server’s default max message size, the gateway accepts no bigger requests.
+const maxRequestBytes = 4 << 20
+
+// maxLongPoll bounds how long a consume waits for a record that isn’t in the log yet.
+const maxLongPoll = 30 * time.Second
+
+// NewHTTPServer(addr string, config *Config, forwarder api.LogClient) creates the HTTP/JSON gateway.
+// Only the leader can append to a replicated log, so a follower sends the produces it gets to the
+// forwarder, a client that picks the leader like the loadbalance picker does. It can be nil when
+// the log isn’t replicated.
+func NewHTTPServer(addr string, config *Config, forwarder api.LogClient) *http.Server {
+
+	httpsrv := newHTTPServer(config, forwarder)
 	r := mux.NewRouter()
 
-	r.HandleFunc("/", httpsrv.handleProduce).Methods("POST")
-	r.HandleFunc("/", httpsrv.handleConsume).Methods("GET")
+	r.HandleFunc("/v1/records", httpsrv.handleProduce).Methods("POST")
+	r.HandleFunc("/v1/records/{offset:[0-9]+}", httpsrv.handleConsume).Methods("GET")
+	r.HandleFunc("/v1/servers", httpsrv.handleGetServers).Methods("GET")
 
 	return &http.Server{
 		Addr:    addr,
@@ -22,73 +60,247 @@ func NewHTTPServer(addr string) *http.Server {
 }
 
 type httpServer struct {
-	Log *Log
+	grpc         *grpcServer
+	authenticate grpc_auth.AuthFunc
+	forwarder    api.LogClient
 }
 
-func newHTTPServer() *httpServer {
+func newHTTPServer(config *Config, forwarder api.LogClient) *httpServer {
 	return &httpServer{
-		Log: NewLog(),
+		grpc:         &grpcServer{Config: config},
+		authenticate: authenticate(config.Authenticator),
+		forwarder:    forwarder,
 	}
 }
 
-type ProduceRequest struct {
-	Record Record `json:"record"`
-}
-type ProduceResponse struct {
-	Offset uint64 `json:"offset"`
+// context(r *http.Request) authenticates the request the way the gRPC server authenticates an RPC: the
+// Authorization header becomes the RPC’s metadata and the TLS connection its peer.
+func (s *httpServer) context(r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	if values := r.Header.Values("Authorization"); len(values) != 0 {
+		ctx = metadata.NewIncomingContext(ctx, metadata.MD{"authorization": values})
+	}
+
+	p := &peer.Peer{}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	return s.authenticate(peer.NewContext(ctx, p))
 }
-type ConsumeRequest struct {
-	Offset uint64 `json:"offset"`
+
+func (s *httpServer) handleProduce(w http.ResponseWriter, r *http.Request) {
+	ctx, err := s.context(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	req := &api.ProduceRequest{}
+	if err := readJSON(w, r, req); err != nil {
+		code := http.StatusBadRequest
+		if _, ok := err.(*http.MaxBytesError); ok {
+			code = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if req.Record == nil {
+		http.Error(w, "record is required", http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.produce(ctx, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, res)
 }
-type ConsumeResponse struct {
-	Record Record `json:"record"`
+
+// produce(ctx context.Context, req *api.ProduceRequest) appends the record, or forwards it to the leader
+// when this server is a follower. The leader authorizes the caller we forward the record for, we
+// authorize it too so a denied produce doesn’t cost a round trip.
+func (s *httpServer) produce(ctx context.Context, req *api.ProduceRequest) (*api.ProduceResponse, error) {
+	if s.forwarder == nil || s.grpc.Replica == nil || s.grpc.Replica.IsLeader() {
+		return s.grpc.Produce(ctx, req)
+	}
+
+	if err := s.grpc.Authorizer.Authorize(subject(ctx), topicObject(req.Record.Topic), produceAction); err != nil {
+		return nil, err
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, api.ForwardedForKey, subject(ctx))
+	return s.forwarder.Produce(ctx, req)
 }
 
-func (s *httpServer) handleProduce(w http.ResponseWriter, r *http.Request) {
-	var req ProduceRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+func (s *httpServer) handleConsume(w http.ResponseWriter, r *http.Request) {
+	ctx, err := s.context(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	req, wait, err := consumeRequest(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	off, err := s.Log.Append(req.Record)
+	res, err := s.grpc.Consume(ctx, req)
+	if _, ok := err.(api.ErrOffsetOutOfRange); ok && wait > 0 {
+		// long poll: hold the request until someone produces the record
+		if err = s.waitForRecord(ctx, req.Offset, wait); err == nil {
+			res, err = s.grpc.Consume(ctx, req)
+		} else if err == context.DeadlineExceeded && ctx.Err() == nil {
+			// nothing yet, the client polls again from the same offset
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	if ctx.Err() == context.Canceled {
+		// the client went away while we waited, there’s no one to answer
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+	writeJSON(w, res)
+}
+
+// waitForRecord(ctx context.Context, offset uint64, wait time.Duration) blocks until the log has the record
+// at offset. A replica tells us when it applies records, a plain log has to be polled.
+func (s *httpServer) waitForRecord(ctx context.Context, offset uint64, wait time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	if s.grpc.Replica != nil {
+		return s.grpc.Replica.WaitForOffset(ctx, offset)
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := s.grpc.CommitLog.Read(offset); err == nil {
+				return nil
+			}
+		}
+	}
+}
 
-	res := ProduceResponse{Offset: off}
-	err = json.NewEncoder(w).Encode(res)
+// consumeRequest(r *http.Request) reads the consume request from the path and query, and how long the
+// client is willing to wait for the record.
+func consumeRequest(r *http.Request) (*api.ConsumeRequest, time.Duration, error) {
+	offset, err := strconv.ParseUint(mux.Vars(r)["offset"], 10, 64)
 	if err != nil {
+		return nil, 0, err
+	}
+	req := &api.ConsumeRequest{Offset: offset}
+
+	query := r.URL.Query()
+	req.Topic = query.Get("topic")
+
+	if v := query.Get("consistency"); v != "" {
+		consistency, ok := api.Consistency_value["CONSISTENCY_"+strings.ToUpper(v)]
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown consistency %q", v)
+		}
+		req.Consistency = api.Consistency(consistency)
+	}
+	if v := query.Get("min_offset"); v != "" {
+		if req.MinOffset, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, 0, err
+		}
+	}
+	if v := query.Get("max_staleness"); v != "" {
+		d, err := time.ParseDuration(v)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, 0, err
+		}
+		req.MaxStaleness = durationpb.New(d)
+	}
+
+	var wait time.Duration
+	if v := query.Get("wait"); v != "" {
+		if wait, err = time.ParseDuration(v); err != nil {
+			return nil, 0, err
+		}
+		if wait > maxLongPoll {
+			wait = maxLongPoll
 		}
 	}
+	return req, wait, nil
 }
 
-func (s *httpServer) handleConsume(w http.ResponseWriter, r *http.Request) {
-	var req ConsumeRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+func (s *httpServer) handleGetServers(w http.ResponseWriter, r *http.Request) {
+	ctx, err := s.context(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	record, err := s.Log.Read(req.Offset)
-	if err == ErrOffsetNotFound {
-		http.Error(w, err.Error(), http.StatusNotFound)
+
+	if s.grpc.GetServerer == nil {
+		http.Error(w, "the log isn't replicated", http.StatusNotFound)
 		return
 	}
+
+	res, err := s.grpc.GetServers(ctx, &api.GetServersRequest{})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+	writeJSON(w, res)
+}
+
+// readJSON(w http.ResponseWriter, r *http.Request, m proto.Message) reads the request’s body into m. The
+// body is as big as a gRPC client may send, bigger ones fail with an *http.MaxBytesError.
+func readJSON(w http.ResponseWriter, r *http.Request, m proto.Message) error {
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(b, m)
+}
 
-	res := ConsumeResponse{Record: record}
-	err = json.NewEncoder(w).Encode(res)
+func writeJSON(w http.ResponseWriter, m proto.Message) {
+	b, err := protojson.Marshal(m)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+// writeError(w http.ResponseWriter, err error) writes the error with the HTTP status matching its gRPC
+// code, so HTTP clients can tell a missing record from a denied request.
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	if _, ok := err.(api.ErrOffsetOutOfRange); ok {
+		code = http.StatusNotFound
+	} else {
+		switch status.Code(err) {
+		case codes.InvalidArgument, codes.OutOfRange:
+			code = http.StatusBadRequest
+		case codes.Unauthenticated:
+			code = http.StatusUnauthorized
+		case codes.PermissionDenied:
+			code = http.StatusForbidden
+		case codes.NotFound:
+			code = http.StatusNotFound
+		case codes.FailedPrecondition:
+			code = http.StatusConflict
+		case codes.Unavailable:
+			code = http.StatusServiceUnavailable
+		case codes.DeadlineExceeded:
+			code = http.StatusGatewayTimeout
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": status.Convert(err).Message()})
 }
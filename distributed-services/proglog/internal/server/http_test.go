@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/ahmad-khatib0/go/distributed-services/proglog/api/v1"
+	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/auth"
+	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/config"
+	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/log"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestHTTPServer(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T, srv *httptest.Server, cfg *Config){
+		"produce/consume a record succeeds":      testHTTPProduceConsume,
+		"consume waits for the record":           testHTTPLongPoll,
+		"a canceled consume isn't answered":      testHTTPLongPollCanceled,
+		"requests go through the authorizer":     testHTTPUnauthorized,
+		"a follower forwards produces":           testHTTPForward,
+		"servers of a log that isn't replicated": testHTTPNoServers,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			clog, err := log.NewLog(t.TempDir(), log.Config{})
+			require.NoError(t, err)
+			defer clog.Close()
+
+			cfg := &Config{
+				CommitLog:     clog,
+				Authorizer:    auth.New(config.ACLModelFile, config.ACLPolicyFile),
+				Authenticator: auth.Chain(auth.Tokens{"root-token": "root", "nobody-token": "nobody"}, auth.TLS{}),
+			}
+			srv := httptest.NewServer(NewHTTPServer("", cfg, nil).Handler)
+			defer srv.Close()
+
+			fn(t, srv, cfg)
+		})
+	}
+}
+
+// do(srv *httptest.Server, token, method, path, body string) sends a request as the token’s subject and
+// returns the response’s status and body.
+func do(t *testing.T, srv *httptest.Server, token, method, path, body string) (int, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, b
+}
+
+func testHTTPProduceConsume(t *testing.T, srv *httptest.Server, _ *Config) {
+	code, body := do(t, srv, "root-token", "POST", "/v1/records", `{"record": {"value": "aGVsbG8gd29ybGQ="}}`)
+	require.Equal(t, http.StatusOK, code, string(body))
+	produce := &api.ProduceResponse{}
+	require.NoError(t, protojson.Unmarshal(body, produce))
+
+	code, body = do(t, srv, "root-token", "GET", "/v1/records/0", "")
+	require.Equal(t, http.StatusOK, code, string(body))
+	consume := &api.ConsumeResponse{}
+	require.NoError(t, protojson.Unmarshal(body, consume))
+	require.Equal(t, []byte("hello world"), consume.Record.Value)
+	require.Equal(t, produce.Offset, consume.Record.Offset)
+
+	code, body = do(t, srv, "root-token", "GET", "/v1/records/1", "")
+	require.Equal(t, http.StatusNotFound, code, string(body))
+
+	code, _ = do(t, srv, "root-token", "GET", "/v1/records/0?consistency=sometimes", "")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	big := `{"record": {"value": "` + strings.Repeat("A", maxRequestBytes) + `"}}`
+	code, _ = do(t, srv, "root-token", "POST", "/v1/records", big)
+	require.Equal(t, http.StatusRequestEntityTooLarge, code)
+}
+
+func testHTTPLongPoll(t *testing.T, srv *httptest.Server, cfg *Config) {
+	// nothing is produced within the wait
+	code, _ := do(t, srv, "root-token", "GET", "/v1/records/0?wait=100ms", "")
+	require.Equal(t, http.StatusNoContent, code)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_, _ = cfg.CommitLog.Append(&api.Record{Value: []byte("hello world")})
+	}()
+
+	code, body := do(t, srv, "root-token", "GET", "/v1/records/0?wait=5s", "")
+	require.Equal(t, http.StatusOK, code, string(body))
+	consume := &api.ConsumeResponse{}
+	require.NoError(t, protojson.Unmarshal(body, consume))
+	require.Equal(t, []byte("hello world"), consume.Record.Value)
+}
+
+func testHTTPLongPollCanceled(t *testing.T, _ *httptest.Server, cfg *Config) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/v1/records/0?wait=5s", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer root-token")
+	rec := httptest.NewRecorder()
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+	NewHTTPServer("", cfg, nil).Handler.ServeHTTP(rec, req)
+	require.False(t, rec.Flushed)
+	require.Zero(t, rec.Body.Len())
+	require.NotEqual(t, http.StatusInternalServerError, rec.Code)
+}
+
+func testHTTPUnauthorized(t *testing.T, srv *httptest.Server, _ *Config) {
+	code, _ := do(t, srv, "nobody-token", "POST", "/v1/records", `{"record": {"value": "aGVsbG8="}}`)
+	require.Equal(t, http.StatusForbidden, code)
+
+	code, _ = do(t, srv, "", "GET", "/v1/records/0", "")
+	require.Equal(t, http.StatusForbidden, code)
+
+	code, body := do(t, srv, "guess", "GET", "/v1/records/0", "")
+	require.Equal(t, http.StatusUnauthorized, code)
+	var res map[string]string
+	require.NoError(t, json.Unmarshal(body, &res))
+	require.NotEmpty(t, res["error"])
+
+	// nobody may produce to the public topics
+	code, body = do(t, srv, "nobody-token", "POST", "/v1/records", `{"record": {"value": "aGVsbG8=", "topic": "public.news"}}`)
+	require.Equal(t, http.StatusOK, code, string(body))
+}
+
+func testHTTPForward(t *testing.T, _ *httptest.Server, cfg *Config) {
+	forwarder := &forwarder{}
+	cfg.Replica = &replica{}
+	srv := httptest.NewServer(NewHTTPServer("", cfg, forwarder).Handler)
+	defer srv.Close()
+
+	code, _ := do(t, srv, "nobody-token", "POST", "/v1/records", `{"record": {"value": "aGVsbG8="}}`)
+	require.Equal(t, http.StatusForbidden, code)
+	require.Nil(t, forwarder.req)
+
+	code, body := do(t, srv, "root-token", "POST", "/v1/records", `{"record": {"value": "aGVsbG8="}}`)
+	require.Equal(t, http.StatusOK, code, string(body))
+	require.Equal(t, []byte("hello"), forwarder.req.Record.Value)
+	require.Equal(t, []string{"root"}, forwarder.md.Get(api.ForwardedForKey))
+	require.Equal(t, `{"offset":"42"}`, strings.ReplaceAll(string(body), " ", ""))
+}
+
+func testHTTPNoServers(t *testing.T, srv *httptest.Server, _ *Config) {
+	code, _ := do(t, srv, "root-token", "GET", "/v1/servers", "")
+	require.Equal(t, http.StatusNotFound, code)
+}
+
+// forwarder implements api.LogClient standing in for the leader.
+type forwarder struct {
+	api.LogClient
+	req *api.ProduceRequest
+	md  metadata.MD
+}
+
+func (f *forwarder) Produce(ctx context.Context, req *api.ProduceRequest, opts ...grpc.CallOption) (*api.ProduceResponse, error) {
+	f.req = req
+	f.md, _ = metadata.FromOutgoingContext(ctx)
+	return &api.ProduceResponse{Offset: 42}, nil
+}
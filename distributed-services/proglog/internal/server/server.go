@@ -2,8 +2,10 @@ package server
 
 import (
 	"context"
+	"strconv"
 
 	api "github.com/ahmad-khatib0/go/distributed-services/proglog/api/v1"
+	"github.com/ahmad-khatib0/go/distributed-services/proglog/internal/auth"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
 	"go.opencensus.io/plugin/ocgrpc"
@@ -12,9 +14,8 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
-	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	// grpc_zap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
@@ -28,15 +29,19 @@ import (
 )
 
 type Config struct {
-	CommitLog   CommitLog
-	Authorizer  Authorizer
-	GetServerer GetServerer
+	CommitLog     CommitLog
+	Authorizer    Authorizer
+	GetServerer   GetServerer
+	Administrator Administrator
+	Replica       Replica
+	Authenticator Authenticator
 }
 
 const (
 	objectWildcard = "*"
 	produceAction  = "produce"
 	consumeAction  = "consume"
+	forwardAction  = "forward"
 )
 
 var _ api.LogServer = (*grpcServer)(nil)
@@ -77,7 +82,7 @@ func NewGRPCServer(config *Config, opts ...grpc.ServerOption) (*grpc.Server, err
 			grpc_middleware.ChainStreamServer(
 				grpc_ctxtags.StreamServerInterceptor(),
 				grpc_zap.StreamServerInterceptor(logger, zapOpts...),
-				grpc_auth.StreamServerInterceptor(authenticate),
+				grpc_auth.StreamServerInterceptor(authenticate(config.Authenticator)),
 			),
 		),
 
@@ -85,7 +90,7 @@ func NewGRPCServer(config *Config, opts ...grpc.ServerOption) (*grpc.Server, err
 			grpc_middleware.ChainUnaryServer(
 				grpc_ctxtags.UnaryServerInterceptor(),
 				grpc_zap.UnaryServerInterceptor(logger, zapOpts...),
-				grpc_auth.UnaryServerInterceptor(authenticate),
+				grpc_auth.UnaryServerInterceptor(authenticate(config.Authenticator)),
 			)),
 		grpc.StatsHandler(&ocgrpc.ServerHandler{}),
 	)
@@ -104,12 +109,20 @@ func NewGRPCServer(config *Config, opts ...grpc.ServerOption) (*grpc.Server, err
 	}
 
 	api.RegisterLogServer(gsrv, srv)
+
+	// the Admin service is only served when there's a cluster to administer
+	if config.Administrator != nil {
+		api.RegisterAdminServer(gsrv, newAdminServer(config))
+	}
 	return gsrv, nil
 }
 
 func (s *grpcServer) Produce(ctx context.Context, req *api.ProduceRequest) (*api.ProduceResponse, error) {
-
-	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, produceAction); err != nil {
+	caller, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Authorizer.Authorize(caller, topicObject(req.Record.GetTopic()), produceAction); err != nil {
 		return nil, err
 	}
 
@@ -121,10 +134,38 @@ func (s *grpcServer) Produce(ctx context.Context, req *api.ProduceRequest) (*api
 }
 
 func (s *grpcServer) Consume(ctx context.Context, req *api.ConsumeRequest) (*api.ConsumeResponse, error) {
-	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, consumeAction); err != nil {
+	res, err := s.consume(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// the record at the offset may belong to another topic than the one the client said it reads
+	if topic := res.Record.Topic; req.Topic != "" && topic != req.Topic {
+		if err := s.Authorizer.Authorize(subject(ctx), topicObject(topic), consumeAction); err != nil {
+			return nil, err
+		}
+	}
+
+	// tell the client’s picker how far along this server’s log is, so it knows where
+	// it can send the next read-your-writes and bounded-staleness consumes
+	if s.Replica != nil {
+		trailer := metadata.Pairs(api.NextOffsetKey, strconv.FormatUint(s.Replica.NextOffset(), 10))
+		if stale, ok := s.Replica.Staleness(); ok {
+			trailer.Set(api.StalenessKey, stale.String())
+		}
+		_ = grpc.SetTrailer(ctx, trailer)
+	}
+	return res, nil
+}
+
+func (s *grpcServer) consume(ctx context.Context, req *api.ConsumeRequest) (*api.ConsumeResponse, error) {
+	if err := s.Authorizer.Authorize(subject(ctx), topicObject(req.Topic), consumeAction); err != nil {
 		return nil, err
 
 	}
+	if err := s.waitForConsistency(ctx, req); err != nil {
+		return nil, err
+	}
 	record, err := s.CommitLog.Read(req.Offset)
 	if err != nil {
 		return nil, err
@@ -132,6 +173,40 @@ func (s *grpcServer) Consume(ctx context.Context, req *api.ConsumeRequest) (*api
 	return &api.ConsumeResponse{Record: record}, nil
 }
 
+// maxConsistencyWait bounds how long a consume waits for this server to catch up, a follower that’s
+// further behind than that should let the client go to another server.
+const maxConsistencyWait = 10 * time.Second
+
+// waitForConsistency(ctx context.Context, req *api.ConsumeRequest) blocks until this server’s log is as
+// fresh as the consume asks for. A log that isn’t replicated is always up to date.
+func (s *grpcServer) waitForConsistency(ctx context.Context, req *api.ConsumeRequest) error {
+	if s.Replica == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxConsistencyWait)
+	defer cancel()
+
+	var err error
+	switch req.Consistency {
+	case api.Consistency_CONSISTENCY_LEADER:
+		if !s.Replica.IsLeader() {
+			return status.Error(codes.FailedPrecondition, "not the leader")
+		}
+	case api.Consistency_CONSISTENCY_READ_YOUR_WRITES:
+		err = s.Replica.WaitForOffset(ctx, req.MinOffset)
+	case api.Consistency_CONSISTENCY_BOUNDED_STALENESS:
+		if req.MaxStaleness.AsDuration() <= 0 {
+			return status.Error(codes.InvalidArgument, "max_staleness must be positive")
+		}
+		err = s.Replica.WaitForLeaderContact(ctx, req.MaxStaleness.AsDuration())
+	}
+	if err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
+
 // ProduceStream(api.Log_ProduceStreamServer) implements a bidirectional streaming RPC so the client can
 // stream data into the server’s log and the server can tell the client whether each request succeeded
 func (s *grpcServer) ProduceStream(stream api.Log_ProduceStreamServer) error {
@@ -155,13 +230,14 @@ func (s *grpcServer) ProduceStream(stream api.Log_ProduceStreamServer) error {
 // will stream every record that follows—even records that aren’t in the log yet!
 // When the server reaches the end of the log, the server will wait until someone
 // appends a record to the log and then continue streaming records to the client.
+// When the request has a topic, the records of the other topics are skipped.
 func (s *grpcServer) ConsumeStream(req *api.ConsumeRequest, stream api.Log_ConsumeStreamServer) error {
 	for {
 		select {
 		case <-stream.Context().Done():
 			return nil
 		default:
-			res, err := s.Consume(stream.Context(), req)
+			res, err := s.consume(stream.Context(), req)
 			switch err.(type) {
 			case nil:
 			case api.ErrOffsetOutOfRange:
@@ -169,6 +245,10 @@ func (s *grpcServer) ConsumeStream(req *api.ConsumeRequest, stream api.Log_Consu
 			default:
 				return err
 			}
+			if req.Topic != "" && res.Record.Topic != req.Topic {
+				req.Offset++
+				continue
+			}
 			if err = stream.Send(res); err != nil {
 				return err
 			}
@@ -199,26 +279,45 @@ type CommitLog interface {
 	Read(uint64) (*api.Record, error)
 }
 
+// Replica is implemented by DistributedLog, the server uses it to enforce the consistency consumes ask
+// for. Like GetServerer, a log that isn’t replicated doesn’t need to implement it.
+type Replica interface {
+	IsLeader() bool
+	NextOffset() uint64
+	Staleness() (time.Duration, bool)
+	WaitForOffset(ctx context.Context, offset uint64) error
+	WaitForLeaderContact(ctx context.Context, staleness time.Duration) error
+}
+
 type Authorizer interface {
 	Authorize(subject, object, action string) error
 }
 
-// authenticate(context.Context) function is an interceptor that reads the subject
-// out of the client’s cert and writes it to the RPC’s context.
-func authenticate(ctx context.Context) (context.Context, error) {
+// Authenticator finds out who the client is, auth.Chain() lets the server accept several kinds of
+// credentials.
+type Authenticator interface {
+	Authenticate(ctx context.Context) (subject string, err error)
+}
 
-	peer, ok := peer.FromContext(ctx)
-	if !ok {
-		return ctx, status.New(codes.Unknown, "couldn't find peer info").Err()
-	}
-	if peer.AuthInfo == nil {
-		return context.WithValue(ctx, subjectContextKey{}, ""), nil
+// authenticate(Authenticator) returns the interceptor function that finds out who the client is and
+// writes its subject to the RPC’s context. Without an authenticator, the subject is the CN of the
+// client’s cert.
+func authenticate(authenticator Authenticator) grpc_auth.AuthFunc {
+	if authenticator == nil {
+		authenticator = auth.TLS{}
 	}
 
-	tlsInfo := peer.AuthInfo.(credentials.TLSInfo)
-	subject := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
-	ctx = context.WithValue(ctx, subjectContextKey{}, subject)
-	return ctx, nil
+	return func(ctx context.Context) (context.Context, error) {
+		subject, err := authenticator.Authenticate(ctx)
+		if err == auth.ErrNoCredentials {
+			subject, err = "", nil
+		}
+		if err != nil {
+			zap.L().Named("audit").Warn("authentication failed", zap.Error(err))
+			return ctx, err
+		}
+		return context.WithValue(ctx, subjectContextKey{}, subject), nil
+	}
 }
 
 // subject(context.Context) function returns the client’s cert’s subject so
@@ -228,3 +327,26 @@ func subject(ctx context.Context) string {
 }
 
 type subjectContextKey struct{}
+
+// caller(ctx context.Context) returns the subject to authorize the RPC for: the subject a gateway forwarded
+// it for, if the gateway may forward requests, or else the client’s own subject.
+func (s *grpcServer) caller(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	forwarded := md.Get(api.ForwardedForKey)
+	if len(forwarded) == 0 {
+		return subject(ctx), nil
+	}
+	if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, forwardAction); err != nil {
+		return "", err
+	}
+	return forwarded[0], nil
+}
+
+// topicObject(topic string) returns the ACL object of a topic. Records without a topic are only
+// available to the subjects granted every topic.
+func topicObject(topic string) string {
+	if topic == "" {
+		return objectWildcard
+	}
+	return topic
+}
@@ -18,6 +18,8 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -49,6 +51,8 @@ func TestServer(t *testing.T) {
 		"produce/consume stream succeeds":                    testProduceConsumeStream,
 		"consume past log boundary fails":                    testConsumePastBoundary,
 		"unauthorized fails":                                 testUnauthorized,
+		"topic permissions":                                  testTopics,
+		"forwarded produces are authorized for the caller":   testForwarded,
 	} {
 		t.Run(scenario, func(t *testing.T) {
 			rootClient, nobodyClient, config, teardown := setupTest(t, nil)
@@ -297,3 +301,124 @@ func testUnauthorized(t *testing.T, _, client api.LogClient, config *Config) {
 	}
 
 }
+
+func testForwarded(t *testing.T, rootClient, nobodyClient api.LogClient, config *Config) {
+	req := &api.ProduceRequest{Record: &api.Record{Value: []byte("hello world")}}
+
+	// root may forward, but not produce for nobody
+	ctx := metadata.AppendToOutgoingContext(context.Background(), api.ForwardedForKey, "nobody")
+	_, err := rootClient.Produce(ctx, req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), api.ForwardedForKey, "root")
+	_, err = rootClient.Produce(ctx, req)
+	require.NoError(t, err)
+
+	// nobody can’t claim to be root
+	_, err = nobodyClient.Produce(ctx, req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func testTopics(t *testing.T, rootClient, nobodyClient api.LogClient, config *Config) {
+	ctx := context.Background()
+
+	// nobody may only use the public topics
+	_, err := nobodyClient.Produce(ctx, &api.ProduceRequest{
+		Record: &api.Record{Value: []byte("secret"), Topic: "private"},
+	})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	private, err := rootClient.Produce(ctx, &api.ProduceRequest{
+		Record: &api.Record{Value: []byte("secret"), Topic: "private"},
+	})
+	require.NoError(t, err)
+
+	public, err := nobodyClient.Produce(ctx, &api.ProduceRequest{
+		Record: &api.Record{Value: []byte("hello world"), Topic: "public.news"},
+	})
+	require.NoError(t, err)
+
+	consume, err := nobodyClient.Consume(ctx, &api.ConsumeRequest{Offset: public.Offset, Topic: "public.news"})
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello world"), consume.Record.Value)
+
+	// the record at the offset is what’s checked, not the topic the client claims
+	_, err = nobodyClient.Consume(ctx, &api.ConsumeRequest{Offset: private.Offset, Topic: "public.news"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// the stream skips the records of other topics
+	stream, err := nobodyClient.ConsumeStream(ctx, &api.ConsumeRequest{Offset: 0, Topic: "public.news"})
+	require.NoError(t, err)
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, public.Offset, res.Record.Offset)
+}
+
+func TestConsumeConsistency(t *testing.T) {
+	replica := &replica{}
+	client, _, _, teardown := setupTest(t, func(c *Config) {
+		c.Replica = replica
+	})
+	defer teardown()
+
+	ctx := context.Background()
+	produce, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.Record{Value: []byte("hello world")}})
+	require.NoError(t, err)
+
+	var trailer metadata.MD
+	_, err = client.Consume(ctx, &api.ConsumeRequest{
+		Offset:      produce.Offset,
+		Consistency: api.Consistency_CONSISTENCY_LEADER,
+	}, grpc.Trailer(&trailer))
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	replica.leader = true
+	_, err = client.Consume(ctx, &api.ConsumeRequest{
+		Offset:      produce.Offset,
+		Consistency: api.Consistency_CONSISTENCY_LEADER,
+	}, grpc.Trailer(&trailer))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1"}, trailer.Get(api.NextOffsetKey))
+	require.Equal(t, []string{"0s"}, trailer.Get(api.StalenessKey))
+
+	_, err = client.Consume(ctx, &api.ConsumeRequest{
+		Offset:      produce.Offset,
+		Consistency: api.Consistency_CONSISTENCY_READ_YOUR_WRITES,
+		MinOffset:   produce.Offset,
+	})
+	require.NoError(t, err)
+	require.Equal(t, produce.Offset, replica.waitedFor)
+
+	_, err = client.Consume(ctx, &api.ConsumeRequest{
+		Offset:      produce.Offset,
+		Consistency: api.Consistency_CONSISTENCY_BOUNDED_STALENESS,
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Consume(ctx, &api.ConsumeRequest{
+		Offset:       produce.Offset,
+		Consistency:  api.Consistency_CONSISTENCY_BOUNDED_STALENESS,
+		MaxStaleness: durationpb.New(time.Second),
+	})
+	require.NoError(t, err)
+}
+
+// replica implements Replica with a log that’s always caught up.
+type replica struct {
+	leader    bool
+	waitedFor uint64
+}
+
+func (r *replica) IsLeader() bool     { return r.leader }
+func (r *replica) NextOffset() uint64 { return 1 }
+
+func (r *replica) Staleness() (time.Duration, bool) { return 0, true }
+
+func (r *replica) WaitForOffset(ctx context.Context, offset uint64) error {
+	r.waitedFor = offset
+	return nil
+}
+
+func (r *replica) WaitForLeaderContact(ctx context.Context, staleness time.Duration) error {
+	return nil
+}
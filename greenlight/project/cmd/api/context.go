@@ -9,7 +9,12 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey  = contextKey("user")
+	tokenContextKey = contextKey("token")
+
+	permissionsContextKey = contextKey("permissions")
+)
 
 // contextSetUser() returns a new copy of the request with the provided User struct added to the context.
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
@@ -25,3 +30,27 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 
 	return user
 }
+
+// contextSetToken() adds the plaintext authentication token of the request to the context.
+func (app *application) contextSetToken(r *http.Request, token string) *http.Request {
+	ctx := context.WithValue(r.Context(), tokenContextKey, token)
+	return r.WithContext(ctx)
+}
+
+// contextGetToken() returns the authentication token of the request, "" for an anonymous request.
+func (app *application) contextGetToken(r *http.Request) string {
+	token, _ := r.Context().Value(tokenContextKey).(string)
+	return token
+}
+
+// contextSetPermissions() adds the permission codes of the request's user to the context.
+func (app *application) contextSetPermissions(r *http.Request, permissions data.Permissions) *http.Request {
+	ctx := context.WithValue(r.Context(), permissionsContextKey, permissions)
+	return r.WithContext(ctx)
+}
+
+// contextGetPermissions() returns the permission codes of the request's user, none for an anonymous request.
+func (app *application) contextGetPermissions(r *http.Request) data.Permissions {
+	permissions, _ := r.Context().Value(permissionsContextKey).(data.Permissions)
+	return permissions
+}
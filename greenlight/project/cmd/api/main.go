@@ -16,8 +16,10 @@ import (
 	"github.com/ahmad-khatib0/go/project/greenlight/internal/data"
 	"github.com/ahmad-khatib0/go/project/greenlight/internal/jsonlog"
 	"github.com/ahmad-khatib0/go/project/greenlight/internal/mailer"
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/ratelimit"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 )
 
 // build variable to hold the executable binary build time. Note that this
@@ -40,6 +42,20 @@ type config struct {
 		rps     float64
 		burst   int
 		enabled bool
+		// backend is memory (a token bucket per process) or redis (a sliding window shared by all instances).
+		backend  string
+		redisURL string
+		// authentication is the stricter limit of POST /v1/tokens/authentication, which guesses passwords.
+		authentication struct {
+			rps   float64
+			burst int
+		}
+		// ip is the looser limit of every request by IP, checked before the token lookup. It's shared
+		// by all the users behind a NAT or a proxy, so it only stops floods of made up tokens.
+		ip struct {
+			rps   float64
+			burst int
+		}
 	}
 	smtp struct {
 		host     string
@@ -51,14 +67,32 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	tokens struct {
+		accessTTL  time.Duration
+		refreshTTL time.Duration
+	}
+	permissions struct {
+		cacheTTL time.Duration
+	}
+	outbox struct {
+		workers      int
+		maxAttempts  int
+		pollInterval time.Duration
+		backoff      time.Duration
+	}
 }
 
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config  config
+	logger  *jsonlog.Logger
+	models  data.Models
+	mailer  mailer.Mailer
+	limiter ratelimit.Limiter
+	// outbox is the emails table the outbox workers send the emails of, app.models.Emails.
+	outbox emailOutbox
+	// permissions caches the users' permission sets.
+	permissions *permissionCache
+	wg          sync.WaitGroup
 }
 
 func main() {
@@ -80,6 +114,12 @@ func main() {
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+	flag.StringVar(&cfg.limiter.backend, "limiter-backend", "memory", "Rate limiter backend (memory|redis)")
+	flag.StringVar(&cfg.limiter.redisURL, "limiter-redis-url", os.Getenv("REDIS_URL"), "Rate limiter Redis URL")
+	flag.Float64Var(&cfg.limiter.authentication.rps, "limiter-auth-rps", 0.1, "Rate limiter maximum authentication requests per second")
+	flag.IntVar(&cfg.limiter.authentication.burst, "limiter-auth-burst", 5, "Rate limiter maximum authentication burst")
+	flag.Float64Var(&cfg.limiter.ip.rps, "limiter-ip-rps", 50, "Rate limiter maximum requests per second by IP before authentication")
+	flag.IntVar(&cfg.limiter.ip.burst, "limiter-ip-burst", 100, "Rate limiter maximum burst by IP before authentication")
 
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "sandbox.smtp.mailtrap.io", "SMTP host")
 	flag.IntVar(&cfg.smtp.port, "smtp-port", 2525, "SMTP port")
@@ -87,6 +127,16 @@ func main() {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", os.Getenv("SMTP_PASSWORD"), "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@greenlight.alexedwards.net>", "SMTP sender")
 
+	flag.DurationVar(&cfg.tokens.accessTTL, "access-token-ttl", 15*time.Minute, "Authentication token lifetime")
+	flag.DurationVar(&cfg.tokens.refreshTTL, "refresh-token-ttl", 30*24*time.Hour, "Refresh token lifetime, a session ends when it isn't refreshed within it")
+
+	flag.DurationVar(&cfg.permissions.cacheTTL, "permissions-cache-ttl", time.Minute, "How long a user's permissions are cached")
+
+	flag.IntVar(&cfg.outbox.workers, "outbox-workers", 4, "Number of email outbox workers")
+	flag.IntVar(&cfg.outbox.maxAttempts, "outbox-max-attempts", 8, "Email attempts before giving up")
+	flag.DurationVar(&cfg.outbox.pollInterval, "outbox-poll-interval", time.Second, "Email outbox poll interval")
+	flag.DurationVar(&cfg.outbox.backoff, "outbox-backoff", 30*time.Second, "Delay before the first email retry, doubled after each attempt")
+
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
 		cfg.cors.trustedOrigins = strings.Fields(val)
 		return nil
@@ -124,11 +174,21 @@ func main() {
 	// Publish the current Unix timestamp.
 	expvar.Publish("timestamp", expvar.Func(func() interface{} { return time.Now().Unix() }))
 
+	limiter, err := newLimiter(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	models := data.NewModels(db)
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:  cfg,
+		logger:  logger,
+		models:  models,
+		mailer:  mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		limiter: limiter,
+		outbox:  models.Emails,
+
+		permissions: newPermissionCache(cfg.permissions.cacheTTL),
 	}
 
 	err = app.serve()
@@ -166,3 +226,27 @@ func openDB(cfg config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// The newLimiter() function returns the rate limiter of the configured backend. With Redis
+// the limits hold across restarts and across all the instances behind the load balancer.
+func newLimiter(cfg config) (ratelimit.Limiter, error) {
+	switch cfg.limiter.backend {
+	case "memory":
+		return ratelimit.NewMemory(), nil
+	case "redis":
+		opts, err := redis.ParseURL(cfg.limiter.redisURL)
+		if err != nil {
+			return nil, err
+		}
+		client := redis.NewClient(opts)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			return nil, err
+		}
+		return ratelimit.NewRedis(client), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limiter backend %q", cfg.limiter.backend)
+	}
+}
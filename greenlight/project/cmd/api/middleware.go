@@ -7,14 +7,12 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/ahmad-khatib0/go/project/greenlight/internal/data"
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/ratelimit"
 	"github.com/ahmad-khatib0/go/project/greenlight/internal/validator"
 	"github.com/felixge/httpsnoop"
 	"github.com/tomasen/realip"
-	"golang.org/x/time/rate"
 )
 
 func (app *application) recoverPanic(next http.Handler) http.Handler {
@@ -31,62 +29,80 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
-func (app *application) rateLimit(next http.Handler) http.Handler {
-	// Define a client struct to hold the rate limiter and last seen time for each client.
-	type client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time
-	}
-	var (
-		mu sync.Mutex
-		// Update the map so the values are pointers to a client struct.
-		clients = make(map[string]*client)
-	)
-
-	// Launch a background goroutine which removes old entries from the clients map once every minute.
-	go func() {
-		for {
-			time.Sleep(time.Minute)
-			// Lock the mutex to prevent any rate limiter checks from happening while the cleanup is taking place.
-			mu.Lock()
-
-			// If they haven't been seen within the last three minutes, delete it
-			for ip, client := range clients {
-				if time.Since(client.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
-				}
-			}
-			mu.Unlock()
+// rateLimitIP() applies the looser pre-authentication limit by IP to every request, before
+// authenticate(), so that requests with made up or invalid tokens are limited before they cost a
+// token lookup. The users behind one NAT or proxy share it, so it's well above the global limit.
+func (app *application) rateLimitIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := ratelimit.Limit{RPS: app.config.limiter.ip.rps, Burst: app.config.limiter.ip.burst}
+		if !app.allowRequest(w, r, "preauth", "ip:"+realip.FromRequest(r), limit) {
+			return
 		}
-	}()
+		next.ServeHTTP(w, r)
+	})
+}
 
+// rateLimit() applies the global limit. It runs after authenticate(), so an authenticated user has
+// their own limit wherever they connect from, and anonymous clients are limited by IP.
+func (app *application) rateLimit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Only carry out the check if rate limiting is enabled.
-		if app.config.limiter.enabled {
-			ip := realip.FromRequest(r)
-
-			mu.Lock()
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{
-					limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
-				}
-			}
-			clients[ip].lastSeen = time.Now()
-			if !clients[ip].limiter.Allow() {
-				mu.Unlock()
-				app.rateLimitExceededResponse(w, r)
-				return
-			}
-
-			// Very importantly, unlock the mutex before calling the next handler in the chain. Notice that we
-			// ( DON'T USE DEFER TO UNLOCK THE MUTEX), as that would mean that the mutex isn't unlocked until
-			// ALL the handlers downstream of this middleware have also returned.
-			mu.Unlock()
+		limit := ratelimit.Limit{RPS: app.config.limiter.rps, Burst: app.config.limiter.burst}
+		if !app.allowRequest(w, r, "global", app.rateLimitKey(r), limit) {
+			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// rateLimitRoute() applies a route's own limit on top of the global one, e.g. a stricter
+// limit for the routes that are worth brute forcing.
+func (app *application) rateLimitRoute(name string, limit ratelimit.Limit, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !app.allowRequest(w, r, name, app.rateLimitKey(r), limit) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// allowRequest() checks the named limit of the client key and writes the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers. When the request is over the limit it sends the 429 response with a
+// Retry-After header, and returns false. The global limit runs after the pre-authentication one
+// and a route's limit after the global one, so the headers describe the strictest of them.
+func (app *application) allowRequest(w http.ResponseWriter, r *http.Request, name, key string, limit ratelimit.Limit) bool {
+	// Only carry out the check if rate limiting is enabled.
+	if !app.config.limiter.enabled {
+		return true
+	}
+
+	result, err := app.limiter.Allow(r.Context(), name+":"+key, limit)
+	if err != nil {
+		// We'd rather serve the request than fail every request while the limiter's store is down.
+		app.logError(r, err)
+		return true
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ratelimit.Seconds(result.Reset)))
+
+	if !result.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(ratelimit.Seconds(result.RetryAfter)))
+		app.rateLimitExceededResponse(w, r)
+		return false
+	}
+	return true
+}
+
+// rateLimitKey() identifies the client: the user when the request is authenticated, otherwise the IP.
+func (app *application) rateLimitKey(r *http.Request) string {
+	user := app.contextGetUser(r)
+	if !user.IsAnonymous() {
+		return "user:" + strconv.FormatInt(user.ID, 10)
+	}
+	return "ip:" + realip.FromRequest(r)
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Add the "Vary: Authorization" header to the response. This indicates to any
@@ -125,7 +141,24 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		// Record when the session was last used, for the sessions list. It isn't worth failing the request for.
+		err = app.models.Sessions.TouchForToken(token)
+		if err != nil {
+			app.logError(r, err)
+		}
+
+		// Load the user's permissions once here, from the cache when we can, for requirePermission().
+		permissions, err := app.permissions.get(user.ID, func() (data.Permissions, error) {
+			return app.models.Permissions.GetAllForUser(user.ID)
+		})
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
 		r = app.contextSetUser(r, user)
+		r = app.contextSetToken(r, token)
+		r = app.contextSetPermissions(r, permissions)
 		next.ServeHTTP(w, r)
 
 	})
@@ -164,13 +197,8 @@ func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.Han
 
 func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		user := app.contextGetUser(r)
-
-		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
+		// authenticate() loaded the user's permissions.
+		permissions := app.contextGetPermissions(r)
 
 		if !permissions.Include(code) {
 			app.notPermittedResponse(w, r)
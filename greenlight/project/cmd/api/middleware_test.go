@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/data"
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/jsonlog"
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/ratelimit"
+)
+
+func newRateLimitTestApp() *application {
+	app := &application{
+		logger:  jsonlog.New(io.Discard, jsonlog.LevelOff),
+		limiter: ratelimit.NewMemory(),
+	}
+	app.config.limiter.enabled = true
+	app.config.limiter.rps = 1
+	app.config.limiter.burst = 1
+	app.config.limiter.ip.rps = 1
+	app.config.limiter.ip.burst = 3
+	return app
+}
+
+// serveAs sends a request from the IP through rateLimitIP(), then rateLimit() as the user,
+// the way authenticate() sits between the two.
+func serveAs(app *application, ip string, user *data.User) int {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	asUser := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, app.contextSetUser(r, user))
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+	r.RemoteAddr = ip + ":1234"
+	rr := httptest.NewRecorder()
+	app.rateLimitIP(asUser(app.rateLimit(ok))).ServeHTTP(rr, r)
+	return rr.Code
+}
+
+func TestRateLimitUsersBehindOneIP(t *testing.T) {
+	app := newRateLimitTestApp()
+	alice, bob := &data.User{ID: 1}, &data.User{ID: 2}
+
+	// each user has their own global limit, the IP they share doesn't hold them to one
+	if code := serveAs(app, "10.0.0.1", alice); code != http.StatusOK {
+		t.Errorf("alice: got status %d; want %d", code, http.StatusOK)
+	}
+	if code := serveAs(app, "10.0.0.1", bob); code != http.StatusOK {
+		t.Errorf("bob: got status %d; want %d", code, http.StatusOK)
+	}
+	if code := serveAs(app, "10.0.0.1", alice); code != http.StatusTooManyRequests {
+		t.Errorf("alice again: got status %d; want %d", code, http.StatusTooManyRequests)
+	}
+
+	// the pre-authentication limit of the IP is used up by now
+	carol := &data.User{ID: 3}
+	if code := serveAs(app, "10.0.0.1", carol); code != http.StatusTooManyRequests {
+		t.Errorf("carol: got status %d; want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimitAnonymousByIP(t *testing.T) {
+	app := newRateLimitTestApp()
+
+	if code := serveAs(app, "10.0.0.1", data.AnonymousUser); code != http.StatusOK {
+		t.Errorf("got status %d; want %d", code, http.StatusOK)
+	}
+	if code := serveAs(app, "10.0.0.1", data.AnonymousUser); code != http.StatusTooManyRequests {
+		t.Errorf("got status %d; want the global limit by IP", code)
+	}
+	if code := serveAs(app, "10.0.0.2", data.AnonymousUser); code != http.StatusOK {
+		t.Errorf("another IP: got status %d; want %d", code, http.StatusOK)
+	}
+}
@@ -176,8 +176,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieSearch
 		data.Filters
 	}
 
@@ -186,17 +185,31 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 
 	input.Title = app.readString(qs, "title", "")
 	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.YearMin = int32(app.readInt(qs, "year_min", 0, v))
+	input.YearMax = int32(app.readInt(qs, "year_max", 0, v))
+	input.RuntimeMin = int32(app.readInt(qs, "runtime_min", 0, v))
+	input.RuntimeMax = int32(app.readInt(qs, "runtime_max", 0, v))
+
+	// A title search is ordered by how well the titles match, unless the client asks otherwise.
+	defaultSort := "id"
+	if input.Title != "" {
+		defaultSort = "relevance"
+	}
+
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
-	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.Sort = app.readString(qs, "sort", defaultSort)
+	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "relevance", "-id", "-title", "-year", "-runtime"}
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
 
+	data.ValidateMovieSearch(v, input.MovieSearch)
+	v.Check(input.Filters.Sort != "relevance" || input.Title != "", "sort", "relevance requires a title search")
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieSearch, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
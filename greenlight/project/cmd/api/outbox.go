@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/data"
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/validator"
+)
+
+// The handlers don't send emails, they put them in the outbox table in the transaction of the
+// change the email is about. The outbox workers send them, retrying the failures with an
+// exponential backoff until an email runs out of attempts and is marked as failed.
+const (
+	// outboxLease is how long a claimed email waits before it's sent again, should the worker
+	// sending it die. It's well above the 5s timeout of the mailer.
+	outboxLease = time.Minute
+	// outboxMaxBackoff caps the delay between two attempts.
+	outboxMaxBackoff = time.Hour
+)
+
+// emailOutbox is what the outbox workers need of data.EmailModel.
+type emailOutbox interface {
+	Claim(lease time.Duration) (*data.Email, error)
+	MarkSent(id int64) error
+	Retry(id int64, sendErr error, after time.Duration) error
+	Fail(id int64, sendErr error) error
+}
+
+// startOutboxWorkers() launches the outbox workers and returns the function that stops them. The
+// workers are tracked by app.wg, so the graceful shutdown waits for the emails being sent.
+func (app *application) startOutboxWorkers() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	for i := 0; i < app.config.outbox.workers; i++ {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			app.outboxWorker(ctx)
+		}()
+	}
+
+	return cancel
+}
+
+func (app *application) outboxWorker(ctx context.Context) {
+	for {
+		sent, err := app.sendNextEmail()
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+
+		// Keep draining while there are due emails, otherwise wait for new ones.
+		if sent && err == nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(app.config.outbox.pollInterval):
+		}
+	}
+}
+
+// sendNextEmail() claims a due email and sends it. It returns false when no email is due.
+func (app *application) sendNextEmail() (sent bool, err error) {
+	email, err := app.outbox.Claim(outboxLease)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, nil
+		case email != nil:
+			// The email was claimed but can't be sent, retrying won't change its data.
+			app.logger.PrintError(fmt.Errorf("giving up on email: %w", err), map[string]string{
+				"email_id": fmt.Sprint(email.ID),
+			})
+			return true, app.outbox.Fail(email.ID, err)
+		default:
+			return false, err
+		}
+	}
+
+	// Recover a panic in the templates, like the background() helper does.
+	defer func() {
+		if p := recover(); p != nil {
+			err = app.outbox.Fail(email.ID, fmt.Errorf("%s", p))
+		}
+	}()
+
+	sendErr := app.mailer.Send(email.Recipient, email.Template, email.Data)
+	if sendErr == nil {
+		return true, app.outbox.MarkSent(email.ID)
+	}
+
+	properties := map[string]string{
+		"email_id": fmt.Sprint(email.ID),
+		"attempts": fmt.Sprint(email.Attempts),
+	}
+	if email.Attempts >= app.config.outbox.maxAttempts {
+		app.logger.PrintError(fmt.Errorf("giving up on email: %w", sendErr), properties)
+		return true, app.outbox.Fail(email.ID, sendErr)
+	}
+
+	app.logger.PrintError(sendErr, properties)
+	return true, app.outbox.Retry(email.ID, sendErr, app.outboxBackoff(email.Attempts))
+}
+
+// outboxBackoff() returns the delay before the next attempt: the base backoff doubled after each
+// failed attempt, give or take 10% so the emails that failed together don't retry together.
+func (app *application) outboxBackoff(attempts int) time.Duration {
+	backoff := app.config.outbox.backoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(backoff)/5+1)) - backoff/10
+	return backoff + jitter
+}
+
+func (app *application) listFailedEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-id"
+	input.Filters.SortSafelist = []string{"-id"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	emails, metadata, err := app.models.Emails.GetAllFailed(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"emails": emails, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/data"
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/jsonlog"
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/mailer"
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/mailer/smtptest"
+)
+
+// memoryOutbox is an outbox of one email, recording what the worker did with it.
+type memoryOutbox struct {
+	email *data.Email
+	// claimErr is returned with the claimed email, like the error decoding its data.
+	claimErr error
+
+	sent      bool
+	failed    bool
+	lastError string
+	retryIn   time.Duration
+}
+
+func (o *memoryOutbox) Claim(lease time.Duration) (*data.Email, error) {
+	if o.email == nil || o.sent || o.failed {
+		return nil, data.ErrRecordNotFound
+	}
+	o.email.Attempts++
+	email := *o.email
+	return &email, o.claimErr
+}
+
+func (o *memoryOutbox) MarkSent(id int64) error {
+	o.sent = true
+	o.lastError = ""
+	o.email.Data = nil
+	return nil
+}
+
+func (o *memoryOutbox) Retry(id int64, sendErr error, after time.Duration) error {
+	o.lastError = sendErr.Error()
+	o.retryIn = after
+	return nil
+}
+
+func (o *memoryOutbox) Fail(id int64, sendErr error) error {
+	o.failed = true
+	o.lastError = sendErr.Error()
+	o.email.Data = nil
+	return nil
+}
+
+func newOutboxTestApp(t *testing.T, maxAttempts int) (*application, *memoryOutbox, *smtptest.Server) {
+	t.Helper()
+
+	srv, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	outbox := &memoryOutbox{email: &data.Email{
+		ID:        1,
+		Recipient: "alice@example.com",
+		Template:  "user_welcome.tmpl",
+		Data:      map[string]interface{}{"userID": 1, "activationToken": "ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
+		Status:    data.EmailPending,
+	}}
+
+	app := &application{
+		logger: jsonlog.New(io.Discard, jsonlog.LevelOff),
+		mailer: mailer.New(srv.Host(), srv.Port(), "", "", "Greenlight <no-reply@greenlight.test>"),
+		outbox: outbox,
+	}
+	app.config.outbox.maxAttempts = maxAttempts
+	app.config.outbox.backoff = 30 * time.Second
+
+	return app, outbox, srv
+}
+
+func TestSendNextEmail(t *testing.T) {
+	app, outbox, srv := newOutboxTestApp(t, 3)
+
+	sent, err := app.sendNextEmail()
+	if err != nil || !sent {
+		t.Fatalf("got sent %t, err %v; want an email sent", sent, err)
+	}
+	if !outbox.sent {
+		t.Fatal("the email isn't marked as sent")
+	}
+	if outbox.email.Data != nil {
+		t.Error("the data of the sent email isn't dropped")
+	}
+
+	messages := srv.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages; want 1", len(messages))
+	}
+	if got := messages[0].To; len(got) != 1 || got[0] != "alice@example.com" {
+		t.Errorf("got recipients %q; want alice@example.com", got)
+	}
+	if !strings.Contains(messages[0].Data, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		t.Error("the message doesn't have the activation token")
+	}
+
+	// the outbox is drained
+	sent, err = app.sendNextEmail()
+	if err != nil || sent {
+		t.Fatalf("got sent %t, err %v; want no email due", sent, err)
+	}
+}
+
+func TestSendNextEmailRetry(t *testing.T) {
+	app, outbox, srv := newOutboxTestApp(t, 3)
+	srv.FailNext(2)
+
+	// the delay doubles after each failed attempt, give or take 10%
+	for attempt, want := range []time.Duration{30 * time.Second, time.Minute} {
+		sent, err := app.sendNextEmail()
+		if err != nil || !sent {
+			t.Fatalf("attempt %d: got sent %t, err %v", attempt+1, sent, err)
+		}
+		if outbox.sent || outbox.failed {
+			t.Fatalf("attempt %d: the email isn't pending", attempt+1)
+		}
+		if outbox.lastError == "" {
+			t.Errorf("attempt %d: the error isn't recorded", attempt+1)
+		}
+		if outbox.retryIn < want-want/10 || outbox.retryIn > want+want/10 {
+			t.Errorf("attempt %d: got retry in %s; want %s ±10%%", attempt+1, outbox.retryIn, want)
+		}
+	}
+
+	sent, err := app.sendNextEmail()
+	if err != nil || !sent {
+		t.Fatalf("got sent %t, err %v; want an email sent", sent, err)
+	}
+	if !outbox.sent || outbox.lastError != "" {
+		t.Errorf("got sent %t, last error %q; want the email sent", outbox.sent, outbox.lastError)
+	}
+	if len(srv.Messages()) != 1 {
+		t.Errorf("got %d messages; want 1", len(srv.Messages()))
+	}
+}
+
+func TestSendNextEmailFail(t *testing.T) {
+	app, outbox, srv := newOutboxTestApp(t, 2)
+	srv.FailNext(2)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		if _, err := app.sendNextEmail(); err != nil {
+			t.Fatalf("attempt %d: %v", attempt, err)
+		}
+	}
+	if !outbox.failed {
+		t.Fatal("the email isn't marked as failed after its last attempt")
+	}
+	if outbox.lastError == "" {
+		t.Error("the error isn't recorded")
+	}
+	if outbox.email.Data != nil {
+		t.Error("the data of the failed email isn't dropped")
+	}
+	if len(srv.Messages()) != 0 {
+		t.Errorf("got %d messages; want none", len(srv.Messages()))
+	}
+
+	// a failed email isn't claimed again
+	sent, err := app.sendNextEmail()
+	if err != nil || sent {
+		t.Fatalf("got sent %t, err %v; want no email due", sent, err)
+	}
+}
+
+func TestSendNextEmailUndecodable(t *testing.T) {
+	app, outbox, srv := newOutboxTestApp(t, 3)
+	outbox.claimErr = errors.New("decoding the data of email 1: unexpected end of JSON input")
+
+	sent, err := app.sendNextEmail()
+	if err != nil || !sent {
+		t.Fatalf("got sent %t, err %v; want the email handled", sent, err)
+	}
+	if !outbox.failed {
+		t.Fatal("an email that can't be decoded isn't marked as failed")
+	}
+	if outbox.lastError != outbox.claimErr.Error() {
+		t.Errorf("got last error %q; want %q", outbox.lastError, outbox.claimErr)
+	}
+	if len(srv.Messages()) != 0 {
+		t.Errorf("got %d messages; want none", len(srv.Messages()))
+	}
+
+	// it isn't claimed again
+	sent, err = app.sendNextEmail()
+	if err != nil || sent {
+		t.Fatalf("got sent %t, err %v; want no email due", sent, err)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	app := &application{}
+	app.config.outbox.backoff = time.Minute
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{20, outboxMaxBackoff},
+	}
+	for _, tt := range tests {
+		got := app.outboxBackoff(tt.attempts)
+		if got < tt.want-tt.want/10 || got > tt.want+tt.want/10 {
+			t.Errorf("outboxBackoff(%d) = %s; want %s ±10%%", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/data"
+)
+
+// permissionCache holds the permission sets of the users who made requests recently, so that
+// authenticate() doesn't query them on every request. The admin endpoints invalidate the users
+// they change. Another instance of the API sees a change when its entry expires, so the TTL
+// bounds how long a revoked permission keeps working there.
+type permissionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int64]permissionCacheEntry
+	// generation counts the invalidations. A load that an invalidation ran during may have read
+	// the permissions from before the change, so it doesn't cache them.
+	generation uint64
+}
+
+type permissionCacheEntry struct {
+	permissions data.Permissions
+	expiry      time.Time
+}
+
+func newPermissionCache(ttl time.Duration) *permissionCache {
+	return &permissionCache{ttl: ttl, entries: make(map[int64]permissionCacheEntry)}
+}
+
+// get() returns the user's cached permissions, or calls load and caches them.
+func (c *permissionCache) get(userID int64, load func() (data.Permissions, error)) (data.Permissions, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, found := c.entries[userID]
+	generation := c.generation
+	c.mu.Unlock()
+	if found && now.Before(entry.expiry) {
+		return entry.permissions, nil
+	}
+
+	// Don't hold the lock while we query the database, two requests loading the same user at the
+	// same time just both query it.
+	permissions, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return permissions, nil
+	}
+	if len(c.entries) >= 10_000 {
+		for id, entry := range c.entries {
+			if now.After(entry.expiry) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	c.entries[userID] = permissionCacheEntry{permissions: permissions, expiry: now.Add(c.ttl)}
+	return permissions, nil
+}
+
+func (c *permissionCache) invalidate(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+	c.generation++
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/data"
+)
+
+func TestPermissionCache(t *testing.T) {
+	cache := newPermissionCache(time.Minute)
+
+	loads := 0
+	load := func() (data.Permissions, error) {
+		loads++
+		return data.Permissions{"movies:read"}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.get(1, load); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("got %d loads; want the second get cached", loads)
+	}
+
+	cache.invalidate(1)
+	if _, err := cache.get(1, load); err != nil {
+		t.Fatal(err)
+	}
+	if loads != 2 {
+		t.Errorf("got %d loads; want a load after the invalidation", loads)
+	}
+}
+
+func TestPermissionCacheInvalidatedDuringLoad(t *testing.T) {
+	cache := newPermissionCache(time.Minute)
+
+	// the permissions are revoked while we read them
+	stale := func() (data.Permissions, error) {
+		cache.invalidate(1)
+		return data.Permissions{"movies:read", "movies:write"}, nil
+	}
+	if _, err := cache.get(1, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := false
+	permissions, err := cache.get(1, func() (data.Permissions, error) {
+		loaded = true
+		return data.Permissions{"movies:read"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded || permissions.Include("movies:write") {
+		t.Errorf("got %v; want the permissions loaded after the invalidation", permissions)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/data"
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (app *application) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := app.models.Roles.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"roles": roles}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string   `json:"name"`
+		Permissions []string `json:"permissions"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	role := &data.Role{
+		Name:        input.Name,
+		Permissions: input.Permissions,
+	}
+
+	known, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateRole(v, role, known); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Transaction(func(tx data.Models) error {
+		return tx.Roles.Insert(role)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRole):
+			v.AddError("name", "a role with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"role": role}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showUserPermissionsHandler() returns the roles of the user in the id parameter, and all the
+// permissions the user has, directly or through the roles.
+func (app *application) showUserPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	roles, err := app.models.Roles.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if permissions == nil {
+		permissions = data.Permissions{}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"roles": roles, "permissions": permissions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) grantRoleHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserRole(w, r, data.RoleModel.AddForUser)
+}
+
+func (app *application) revokeRoleHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserRole(w, r, data.RoleModel.RemoveForUser)
+}
+
+// changeUserRole() grants or revokes the role in the role parameter for the user in the id
+// parameter, and drops the user's cached permissions.
+func (app *application) changeUserRole(w http.ResponseWriter, r *http.Request, change func(data.RoleModel, int64, *data.Role) error) {
+	user, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	name := httprouter.ParamsFromContext(r.Context()).ByName("role")
+	role, err := app.models.Roles.GetByName(name)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = change(app.models.Roles, user.ID, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.permissions.invalidate(user.ID)
+	app.showUserPermissionsHandler(w, r)
+}
+
+func (app *application) grantPermissionHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserPermission(w, r, data.PermissionModel.AddForUser)
+}
+
+func (app *application) revokePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserPermission(w, r, data.PermissionModel.RemoveForUser)
+}
+
+// changeUserPermission() grants or revokes the permission in the code parameter for the user in
+// the id parameter, and drops the user's cached permissions. Revoking a permission the user has
+// through a role leaves it in place, the role has to be revoked.
+func (app *application) changeUserPermission(w http.ResponseWriter, r *http.Request, change func(data.PermissionModel, int64, ...string) error) {
+	user, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	code := httprouter.ParamsFromContext(r.Context()).ByName("code")
+
+	known, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !known.Include(code) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = change(app.models.Permissions, user.ID, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.permissions.invalidate(user.ID)
+	app.showUserPermissionsHandler(w, r)
+}
+
+// adminTargetUser() reads the user in the id parameter of the admin endpoints, and sends the
+// error response if there's no such user.
+func (app *application) adminTargetUser(w http.ResponseWriter, r *http.Request) (*data.User, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	user, err := app.models.Users.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+}
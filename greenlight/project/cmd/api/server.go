@@ -20,6 +20,8 @@ func (app *application) serve() error {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	stopOutbox := app.startOutboxWorkers()
+
 	shutdownError := make(chan error)
 	go func() {
 		// We need to use a buffered channel here because signal.Notify() does not wait for a
@@ -44,6 +46,8 @@ func (app *application) serve() error {
 		}
 
 		app.logger.PrintInfo("completing background tasks", map[string]string{"addr": srv.Addr})
+		// The outbox workers finish the email they're sending, the rest stay in the outbox.
+		stopOutbox()
 		app.wg.Wait()
 		shutdownError <- nil
 
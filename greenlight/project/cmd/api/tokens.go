@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -51,14 +52,153 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 
-	// we generate a new token with a 24-hour expiry time and the scope 'authentication'.
-	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	// Start a session with a short-lived authentication token, and a refresh token to get the next one.
+	var accessToken, refreshToken *data.Token
+	err = app.models.Transaction(func(tx data.Models) error {
+		session := &data.Session{UserID: user.ID, UserAgent: r.UserAgent()}
+		err := tx.Sessions.Insert(session)
+		if err != nil {
+			return err
+		}
+
+		accessToken, refreshToken, err = app.newSessionTokens(tx, session)
+		return err
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"authentication_token": accessToken, "refresh_token": refreshToken}
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// newSessionTokens() creates the authentication and refresh tokens of a session.
+func (app *application) newSessionTokens(tx data.Models, session *data.Session) (access, refresh *data.Token, err error) {
+	access, err = tx.Tokens.NewForSession(session, app.config.tokens.accessTTL, data.ScopeAuthentication)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refresh, err = tx.Tokens.NewForSession(session, app.config.tokens.refreshTTL, data.ScopeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return access, refresh, nil
+}
+
+// Exchange a refresh token for a new authentication token and a new refresh token. A refresh token
+// works once: if a used one comes back, either the client or an attacker holds a stolen copy, and
+// we can't tell which, so the whole session is revoked.
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.RefreshToken); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	token, err := app.models.Tokens.Get(data.ScopeRefresh, input.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidRefreshTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if token.UsedAt != nil {
+		app.revokeReusedSession(w, r, token)
+		return
+	}
+
+	if time.Now().After(token.Expiry) {
+		app.invalidRefreshTokenResponse(w, r)
+		return
+	}
+
+	var accessToken, refreshToken *data.Token
+	err = app.models.Transaction(func(tx data.Models) error {
+		err := tx.Tokens.MarkUsed(token)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Sessions.Touch(*token.SessionID)
+		if err != nil {
+			return err
+		}
+
+		session := &data.Session{ID: *token.SessionID, UserID: token.UserID}
+		accessToken, refreshToken, err = app.newSessionTokens(tx, session)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTokenReused):
+			// Another request refreshed with the same token in the meantime.
+			app.revokeReusedSession(w, r, token)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"authentication_token": accessToken, "refresh_token": refreshToken}
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) revokeReusedSession(w http.ResponseWriter, r *http.Request, token *data.Token) {
+	app.logger.PrintInfo("refresh token reused, revoking the session", map[string]string{
+		"user_id":    fmt.Sprint(token.UserID),
+		"session_id": fmt.Sprint(*token.SessionID),
+	})
+
+	err := app.models.Sessions.Delete(*token.SessionID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.invalidRefreshTokenResponse(w, r)
+}
+
+// Log out: end the session of the request's authentication token, its refresh token stops working too.
+func (app *application) deleteAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	token := app.contextGetToken(r)
+
+	err := app.models.Sessions.DeleteForToken(token)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The tokens created before sessions existed don't have one.
+	err = app.models.Tokens.Delete(token)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "you have been logged out"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -101,27 +241,25 @@ func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r
 		return
 	}
 
-	// Otherwise, create a new password reset token with a 45-minute expiry time.
-	token, err := app.models.Tokens.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-	// Email the user with their password reset token.
-	app.background(func() {
-		data := map[string]interface{}{
-			"passwordResetToken": token.Plaintext,
+	// Otherwise, create a new password reset token with a 45-minute expiry time, and put the email
+	// with the token in the outbox in the same transaction.
+	err = app.models.Transaction(func(tx data.Models) error {
+		token, err := tx.Tokens.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
+		if err != nil {
+			return err
 		}
 
 		// Since email addresses MAY be case sensitive, notice that we are sending this
 		// email using the address stored in our database for the user --- not to the
 		// input.Email address provided by the client in this request.
-		err = app.mailer.Send(user.Email, "token_password_reset.tmpl", data)
-		if err != nil {
-			app.logger.PrintError(err, nil)
-		}
+		return tx.Emails.Enqueue(user.Email, "token_password_reset.tmpl", map[string]interface{}{
+			"passwordResetToken": token.Plaintext,
+		})
 	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
 	env := envelope{"message": "an email will be sent to you containing password reset instructions"}
 	err = app.writeJSON(w, http.StatusAccepted, env, nil)
@@ -166,23 +304,21 @@ func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *h
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+	err = app.models.Transaction(func(tx data.Models) error {
+		token, err := tx.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+		if err != nil {
+			return err
+		}
+
+		return tx.Emails.Enqueue(user.Email, "token_activation.tmpl", map[string]interface{}{
+			"activationToken": token.Plaintext,
+		})
+	})
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	app.background(func() {
-		data := map[string]interface{}{
-			"activationToken": token.Plaintext,
-		}
-
-		err = app.mailer.Send(user.Email, "token_activation.tmpl", data)
-		if err != nil {
-			app.logger.PrintError(err, nil)
-		}
-	})
-
 	env := envelope{"message": "an email will be sent to you containing activation instructions"}
 	err = app.writeJSON(w, http.StatusAccepted, env, nil)
 	if err != nil {
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/data"
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/jsonlog"
+)
+
+// newDBTestApp connects to the migrated database of GREENLIGHT_TEST_DB_DSN, and skips the test
+// when it isn't set.
+func newDBTestApp(t *testing.T) *application {
+	t.Helper()
+
+	dsn := os.Getenv("GREENLIGHT_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("GREENLIGHT_TEST_DB_DSN isn't set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &application{
+		logger: jsonlog.New(io.Discard, jsonlog.LevelOff),
+		models: data.NewModels(db),
+	}
+}
+
+func TestUpdateUserPasswordEndsSessions(t *testing.T) {
+	app := newDBTestApp(t)
+
+	user := &data.User{
+		Name:      "Alice",
+		Email:     "alice-" + strconv.FormatInt(time.Now().UnixNano(), 36) + "@example.com",
+		Activated: true,
+	}
+	if err := user.Password.Set("pa55word1234"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Users.Insert(user); err != nil {
+		t.Fatal(err)
+	}
+
+	// someone who knew the old password signed in twice
+	var tokens []*data.Token
+	for i := 0; i < 2; i++ {
+		session := &data.Session{UserID: user.ID}
+		if err := app.models.Sessions.Insert(session); err != nil {
+			t.Fatal(err)
+		}
+		token, err := app.models.Tokens.NewForSession(session, 24*time.Hour, data.ScopeRefresh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	reset, err := app.models.Tokens.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"password": "n3w pa55word1234", "token": "` + reset.Plaintext + `"}`
+	rr := httptest.NewRecorder()
+	app.updateUserPasswordHandler(rr, httptest.NewRequest(http.MethodPut, "/v1/users/password", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, body %s; want %d", rr.Code, rr.Body, http.StatusOK)
+	}
+
+	sessions, err := app.models.Sessions.GetAllActiveForUser(user.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("got %d active sessions; want none after the reset", len(sessions))
+	}
+	for _, token := range tokens {
+		if _, err := app.models.Users.GetForToken(data.ScopeRefresh, token.Plaintext); err != data.ErrRecordNotFound {
+			t.Errorf("got err %v for a refresh token of an ended session; want %v", err, data.ErrRecordNotFound)
+		}
+	}
+	if _, err := app.models.Users.GetForToken(data.ScopePasswordReset, reset.Plaintext); err != data.ErrRecordNotFound {
+		t.Errorf("got err %v for the used reset token; want %v", err, data.ErrRecordNotFound)
+	}
+}
@@ -0,0 +1,190 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+)
+
+// Email is an email in the outbox. Data holds the template's data, which can be a plaintext token,
+// so it isn't part of the JSON and it's cleared once the email is sent or given up on.
+type Email struct {
+	ID            int64                  `json:"id"`
+	CreatedAt     time.Time              `json:"created_at"`
+	Recipient     string                 `json:"recipient"`
+	Template      string                 `json:"template"`
+	Data          map[string]interface{} `json:"-"`
+	Status        string                 `json:"status"`
+	Attempts      int                    `json:"attempts"`
+	NextAttemptAt time.Time              `json:"next_attempt_at"`
+	LastError     string                 `json:"last_error,omitempty"`
+	SentAt        *time.Time             `json:"sent_at,omitempty"`
+}
+
+type EmailModel struct {
+	DB DBTX
+}
+
+// Enqueue() adds an email to the outbox. Call it on the models of a transaction, so the email
+// is only sent if the change it's about is committed.
+func (m EmailModel) Enqueue(recipient, template string, data map[string]interface{}) error {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO emails (recipient, template, data) VALUES ($1, $2, $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, recipient, template, string(js))
+	return err
+}
+
+// Claim() takes the pending email that's been due the longest, and counts the attempt. The
+// email's next attempt is pushed back by lease, so if the worker dies before it reports the
+// result the email is sent again once the lease runs out. SKIP LOCKED lets the workers claim
+// different emails concurrently. If no email is due, it returns ErrRecordNotFound. If the
+// email's data can't be decoded, it returns the claimed email without its data along with the
+// error, so the caller can mark it as failed rather than claim it again after every lease.
+func (m EmailModel) Claim(lease time.Duration) (*Email, error) {
+	query := `UPDATE emails
+							SET attempts = attempts + 1, next_attempt_at = NOW() + $1 * interval '1 second'
+							WHERE id = (
+								SELECT id FROM emails
+								WHERE status = 'pending' AND next_attempt_at <= NOW()
+								ORDER BY next_attempt_at, id
+								LIMIT 1
+								FOR UPDATE SKIP LOCKED
+							)
+							RETURNING id, created_at, recipient, template, data, status, attempts, next_attempt_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var email Email
+	var js []byte
+	err := m.DB.QueryRowContext(ctx, query, lease.Seconds()).Scan(
+		&email.ID,
+		&email.CreatedAt,
+		&email.Recipient,
+		&email.Template,
+		&js,
+		&email.Status,
+		&email.Attempts,
+		&email.NextAttemptAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := json.Unmarshal(js, &email.Data); err != nil {
+		email.Data = nil
+		return &email, fmt.Errorf("decoding the data of email %d: %w", email.ID, err)
+	}
+	return &email, nil
+}
+
+// MarkSent() records that the email was sent, and drops its data.
+func (m EmailModel) MarkSent(id int64) error {
+	query := `UPDATE emails
+							SET status = 'sent', sent_at = NOW(), data = '{}', last_error = ''
+							WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// Retry() records a failed attempt and schedules the next one after the given delay.
+func (m EmailModel) Retry(id int64, sendErr error, after time.Duration) error {
+	query := `UPDATE emails
+							SET next_attempt_at = NOW() + $1 * interval '1 second', last_error = $2
+							WHERE id = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, after.Seconds(), sendErr.Error(), id)
+	return err
+}
+
+// Fail() records the last failed attempt of an email that won't be retried, and drops its data.
+func (m EmailModel) Fail(id int64, sendErr error) error {
+	query := `UPDATE emails
+							SET status = 'failed', data = '{}', last_error = $1
+							WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, sendErr.Error(), id)
+	return err
+}
+
+// GetAllFailed() returns a page of the emails that ran out of attempts, the latest first.
+func (m EmailModel) GetAllFailed(filters Filters) ([]*Email, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), id, created_at, recipient, template, status, attempts, next_attempt_at, last_error
+				FROM emails
+				WHERE status = 'failed'
+				ORDER BY id DESC
+				LIMIT $1 OFFSET $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	emails := []*Email{}
+
+	for rows.Next() {
+		var email Email
+		err := rows.Scan(
+			&totalRecords,
+			&email.ID,
+			&email.CreatedAt,
+			&email.Recipient,
+			&email.Template,
+			&email.Status,
+			&email.Attempts,
+			&email.NextAttemptAt,
+			&email.LastError,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		emails = append(emails, &email)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return emails, metadata, nil
+}
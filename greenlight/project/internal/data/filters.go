@@ -1,6 +1,8 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"math"
 	"strings"
 
@@ -12,18 +14,86 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	// Cursor is the next_cursor or prev_cursor of a previous response. When it's set, the page
+	// starts right after (or ends right before) the record the cursor points to, Page is ignored
+	// and the total number of records isn't counted, so deep pages cost as much as the first one.
+	Cursor string
 }
 
 // Metadata struct for holding the pagination metadata.
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+}
+
+// cursor is the position of a record in a sorted list: the value of the sort column and the id
+// which breaks the ties. It's sent to the clients as opaque base64 JSON.
+type cursor struct {
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v"`
+	ID    int64       `json:"id"`
+	// Before is set on a prev_cursor, the page ends right before the record.
+	Before bool `json:"b,omitempty"`
+}
+
+func (c cursor) encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var c cursor
+	if err := json.Unmarshal(js, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// valid reports whether the cursor's value has the type of its sort column. The clients can
+// tamper with a cursor, and a value the column can't be compared with would fail the query:
+// the id is a bigint, the year and runtime are integers, and the relevance is a real.
+func (c cursor) valid() bool {
+	if c.ID < 1 {
+		return false
+	}
+
+	column := strings.TrimPrefix(c.Sort, "-")
+	switch v := c.Value.(type) {
+	case string:
+		return column == "title"
+	case float64:
+		switch column {
+		case "title":
+			return false
+		case "relevance":
+			return math.Abs(v) <= math.MaxFloat32
+		case "id":
+			// float64(math.MaxInt64) rounds up to 2^63, which is already out of range
+			return v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64
+		default:
+			return v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32
+		}
+	}
+	return false
 }
 
 func ValidateFilters(v *validator.Validator, f Filters) {
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil && c.valid(), "cursor", "invalid cursor")
+		// A cursor only makes sense in the order it was taken from.
+		v.Check(err != nil || c.Sort == f.Sort, "cursor", "must be used with the sort it was returned for")
+	}
 
 	v.Check(f.Page > 0, "page", "must be greater than zero")
 	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
@@ -47,8 +117,9 @@ func (f Filters) sortColumn() string {
 }
 
 // Return the sort direction ("ASC" or "DESC") depending on the prefix character of the Sort field.
+// The "relevance" sort is always descending, the best match comes first.
 func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
+	if strings.HasPrefix(f.Sort, "-") || f.Sort == "relevance" {
 		return "DESC"
 	}
 	return "ASC"
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/validator"
+)
+
+func TestValidateFiltersCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+		valid  bool
+	}{
+		{"year", "year", cursor{Sort: "year", Value: 1999, ID: 7}.encode(), true},
+		{"title", "-title", cursor{Sort: "-title", Value: "Moana", ID: 7}.encode(), true},
+		{"prev cursor", "-id", cursor{Sort: "-id", Value: 7, ID: 7, Before: true}.encode(), true},
+		{"other sort", "title", cursor{Sort: "year", Value: 1999, ID: 7}.encode(), false},
+		{"string year", "year", cursor{Sort: "year", Value: "1999; DROP", ID: 7}.encode(), false},
+		{"numeric title", "title", cursor{Sort: "title", Value: 1999, ID: 7}.encode(), false},
+		{"no value", "year", cursor{Sort: "year", ID: 7}.encode(), false},
+		{"object value", "year", cursor{Sort: "year", Value: map[string]int{"a": 1}, ID: 7}.encode(), false},
+		{"fractional year", "year", cursor{Sort: "year", Value: 1.5, ID: 7}.encode(), false},
+		{"huge year", "year", cursor{Sort: "year", Value: 1e40, ID: 7}.encode(), false},
+		{"year past int32", "-year", cursor{Sort: "-year", Value: 1 << 31, ID: 7}.encode(), false},
+		{"large id", "id", cursor{Sort: "id", Value: 1 << 40, ID: 7}.encode(), true},
+		{"fractional id", "id", cursor{Sort: "id", Value: 1.5, ID: 7}.encode(), false},
+		{"huge id", "-id", cursor{Sort: "-id", Value: 1e40, ID: 7}.encode(), false},
+		{"relevance", "relevance", cursor{Sort: "relevance", Value: 0.0607927, ID: 7}.encode(), true},
+		{"huge relevance", "relevance", cursor{Sort: "relevance", Value: 1e40, ID: 7}.encode(), false},
+		{"no id", "year", cursor{Sort: "year", Value: 1999}.encode(), false},
+		{"string id", "year", base64.RawURLEncoding.EncodeToString([]byte(`{"s":"year","v":1999,"id":"7"}`)), false},
+		{"not base64", "year", "not a cursor!", false},
+	}
+	for _, tt := range tests {
+		v := validator.New()
+		ValidateFilters(v, Filters{
+			Page:         1,
+			PageSize:     20,
+			Sort:         tt.sort,
+			SortSafelist: []string{"id", "title", "year", "-id", "-title", "-year", "relevance"},
+			Cursor:       tt.cursor,
+		})
+		if v.Valid() != tt.valid {
+			t.Errorf("%s: got valid %t, errors %v; want valid %t", tt.name, v.Valid(), v.Errors, tt.valid)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 )
@@ -19,15 +20,59 @@ type Models struct {
 	Users       UserModel
 	Tokens      TokenModel
 	Permissions PermissionModel
+	Reviews     ReviewModel
+	Emails      EmailModel
+	Sessions    SessionModel
+	Roles       RoleModel
+
+	db *sql.DB
+}
+
+// DBTX is what the models need to run their queries. Both *sql.DB and *sql.Tx implement it, so
+// the same models work inside a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 // For ease of use, we also add a New() method which returns a Models struct containing
 // the initialized MovieModel.
 func NewModels(db *sql.DB) Models {
+	models := newModels(db)
+	models.db = db
+	return models
+}
+
+func newModels(db DBTX) Models {
 	return Models{
 		Movies:      MovieModel{DB: db},
 		Users:       UserModel{DB: db},
 		Tokens:      TokenModel{DB: db},
 		Permissions: PermissionModel{DB: db},
+		Reviews:     ReviewModel{DB: db},
+		Emails:      EmailModel{DB: db},
+		Sessions:    SessionModel{DB: db},
+		Roles:       RoleModel{DB: db},
 	}
 }
+
+// Transaction() calls fn with models that run their queries in a transaction, which is committed
+// if fn returns nil and rolled back otherwise. E.g. a new user and their welcome email in the
+// outbox are saved together or not at all.
+func (m Models) Transaction(fn func(tx Models) error) error {
+	tx, err := m.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	err = fn(newModels(tx))
+	if err != nil {
+		// The error of fn is the interesting one, the rollback's can only be that the
+		// transaction is already gone.
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ahmad-khatib0/go/project/greenlight/internal/validator"
@@ -19,10 +20,16 @@ type Movie struct {
 	Runtime   Runtime   `json:"runtime,omitempty"`
 	Genres    []string  `json:"genres,omitempty"`
 	Version   int32     `json:"version"` // starts at 1 and will be incremented each time the movie information is updated
+	// The average of the reviews' ratings, kept up to date by a trigger on the reviews table.
+	AverageRating float64 `json:"average_rating"`
+	RatingCount   int32   `json:"rating_count"`
 }
 
+// averageRatingColumn is the average rating rounded to one decimal, 0 when there's no review.
+const averageRatingColumn = `COALESCE(round(rating_sum::numeric / NULLIF(rating_count, 0), 1), 0)`
+
 type MovieModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func (m MovieModel) Insert(movie *Movie) error {
@@ -45,7 +52,7 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	}
 
 	// query := ` SELECT pg_sleep(10), id, created_at, title, year, runtime, genres, version
-	query := ` SELECT id, created_at, title, year, runtime, genres, version
+	query := ` SELECT id, created_at, title, year, runtime, genres, version, ` + averageRatingColumn + `, rating_count
 							FROM movies
 							WHERE id = $1`
 
@@ -64,6 +71,8 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		&movie.Runtime,
 		pq.Array(&movie.Genres),
 		&movie.Version,
+		&movie.AverageRating,
+		&movie.RatingCount,
 	)
 
 	if err != nil {
@@ -139,24 +148,102 @@ func (m MovieModel) Delete(id int64) error {
 	return nil
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+// MovieSearch holds the criteria of the movies list, a zero value means no criterion.
+type MovieSearch struct {
+	Title      string
+	Genres     []string
+	YearMin    int32
+	YearMax    int32
+	RuntimeMin int32
+	RuntimeMax int32
+}
+
+func ValidateMovieSearch(v *validator.Validator, search MovieSearch) {
+	v.Check(search.YearMin >= 0 && search.YearMax >= 0, "year", "must not be negative")
+	v.Check(search.YearMax == 0 || search.YearMin <= search.YearMax, "year_min", "must not be greater than year_max")
+	v.Check(search.RuntimeMin >= 0 && search.RuntimeMax >= 0, "runtime", "must not be negative")
+	v.Check(search.RuntimeMax == 0 || search.RuntimeMin <= search.RuntimeMax, "runtime_min", "must not be greater than runtime_max")
+}
+
+// rankColumn is how well the title matches the title search, the "relevance" sort orders by it.
+const rankColumn = `CASE WHEN $1 = '' THEN 0 ELSE ts_rank(to_tsvector('simple', title), plainto_tsquery('simple', $1)) END`
+
+func (m MovieModel) GetAll(search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
+	args := []interface{}{search.Title, pq.Array(search.Genres)}
+	// arg() adds a value to args and returns its placeholder.
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
 	// The (genres @> $2 OR $2 = '{}') condition works in the same way. The @> symbol is the
 	// ‘contains’ operator for PostgreSQL arrays, and this condition will return true if each value
 	// in the placeholder parameter $2 appears in the database genres field or the placeholder
 	// parameter contains an empty array.
+	where := []string{
+		"(to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')",
+		"(genres @> $2 OR $2 = '{}')",
+	}
+	if search.YearMin != 0 {
+		where = append(where, "year >= "+arg(search.YearMin))
+	}
+	if search.YearMax != 0 {
+		where = append(where, "year <= "+arg(search.YearMax))
+	}
+	if search.RuntimeMin != 0 {
+		where = append(where, "runtime >= "+arg(search.RuntimeMin))
+	}
+	if search.RuntimeMax != 0 {
+		where = append(where, "runtime <= "+arg(search.RuntimeMax))
+	}
+
+	column := filters.sortColumn()
+	if column == "relevance" {
+		column = rankColumn
+	}
+	direction := filters.sortDirection()
+	idDirection := "ASC"
+
+	var c *cursor
+	if filters.Cursor != "" {
+		var err error
+		if c, err = decodeCursor(filters.Cursor); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		// Keyset pagination: the records after the cursor in the sort order, with the id breaking
+		// the ties. A prev_cursor walks the list backwards, so we flip the comparisons and the order
+		// and put the records back in order once we have them.
+		after, idAfter := ">", ">"
+		if direction == "DESC" {
+			after = "<"
+		}
+		if c.Before {
+			after, idAfter = flip(after), "<"
+			direction, idDirection = flipDirection(direction), "DESC"
+		}
+		value, id := arg(c.Value), arg(c.ID)
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND id %[4]s %[5]s))", column, after, value, idAfter, id))
+	}
+
+	// Counting every match is what makes deep pages slow, so the cursor pages don't. They fetch
+	// one more record than the page holds instead, to know whether there's a next one.
+	count, limit, offset := "count(*) OVER()", filters.limit(), filters.offset()
+	if c != nil {
+		count, limit, offset = "0", filters.limit()+1, 0
+	}
+
 	query := fmt.Sprintf(`
-        SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version FROM movies
-				WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-				AND (genres @> $2 OR $2 = '{}')
-				ORDER BY %s %s, id ASC
-				LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection(),
+        SELECT %s, id, created_at, title, year, runtime, genres, version, %s, rating_count, %s FROM movies
+				WHERE %s
+				ORDER BY %s %s, id %s
+				LIMIT %s OFFSET %s`,
+		count, averageRatingColumn, column, strings.Join(where, " AND "), column, direction, idDirection, arg(limit), arg(offset),
 	)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{title, pq.Array(genres), filters.limit(), filters.offset()}
-
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
@@ -166,9 +253,12 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 
 	totalRecords := 0
 	movies := []*Movie{}
+	// positions holds the cursor of each movie, the first and last become prev_cursor and next_cursor.
+	positions := []cursor{}
 
 	for rows.Next() {
 		var movie Movie
+		position := cursor{Sort: filters.Sort}
 		err := rows.Scan(
 			&totalRecords,
 			&movie.ID,
@@ -178,22 +268,77 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 			&movie.Runtime,
 			pq.Array(&movie.Genres),
 			&movie.Version,
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&position.Value,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
 
+		position.ID = movie.ID
 		movies = append(movies, &movie)
+		positions = append(positions, position)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, Metadata{}, err
 	}
 
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	var metadata Metadata
+	var hasPrev, hasNext bool
+	if c == nil {
+		metadata = calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+		hasPrev = filters.Page > 1
+		hasNext = filters.offset()+len(movies) < totalRecords
+	} else {
+		more := len(movies) > filters.limit()
+		if more {
+			movies, positions = movies[:filters.limit()], positions[:filters.limit()]
+		}
+		if c.Before {
+			reverse(movies)
+			reverse(positions)
+		}
+		// We came from the other side of the cursor, so there's a page there.
+		hasPrev, hasNext = more || !c.Before, more || c.Before
+		metadata = Metadata{PageSize: filters.PageSize}
+	}
+
+	if len(positions) > 0 {
+		if hasPrev {
+			first := positions[0]
+			first.Before = true
+			metadata.PrevCursor = first.encode()
+		}
+		if hasNext {
+			metadata.NextCursor = positions[len(positions)-1].encode()
+		}
+	}
+
 	return movies, metadata, nil
 }
 
+func flip(op string) string {
+	if op == ">" {
+		return "<"
+	}
+	return ">"
+}
+
+func flipDirection(direction string) string {
+	if direction == "ASC" {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+func reverse[T any](s []T) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
 func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
@@ -2,7 +2,6 @@ package data
 
 import (
 	"context"
-	"database/sql"
 	"time"
 
 	"github.com/lib/pq"
@@ -21,16 +20,22 @@ func (p Permissions) Include(code string) bool {
 }
 
 type PermissionModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
-// GetAllForUser() method returns all permission codes for a specific user in a Permissions slice.
+// GetAllForUser() method returns all permission codes for a specific user in a Permissions slice:
+// the ones granted to the user directly and the ones of the user's roles.
 func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 	query := `SELECT permissions.code
 							FROM permissions
 							INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
-							INNER JOIN users ON users_permissions.user_id = users.id
-							WHERE users.id = $1`
+							WHERE users_permissions.user_id = $1
+						UNION
+						SELECT permissions.code
+							FROM permissions
+							INNER JOIN roles_permissions ON roles_permissions.permission_id = permissions.id
+							INNER JOIN users_roles ON users_roles.role_id = roles_permissions.role_id
+							WHERE users_roles.user_id = $1`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -58,10 +63,35 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 	return permissions, nil
 }
 
-// Add the provided permission codes for a specific user.
+// Add the provided permission codes for a specific user. The codes the user already has are skipped,
+// and a user that doesn't exist gets ErrRecordNotFound.
 func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
 	query := `INSERT INTO users_permissions
-            SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)`
+            SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+            ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "users_permissions" violates foreign key constraint "users_permissions_user_id_fkey"`:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveForUser() removes the permission codes granted to the user directly. The permissions of the
+// user's roles stay, revoke the role to take them away.
+func (m PermissionModel) RemoveForUser(userID int64, codes ...string) error {
+	query := `DELETE FROM users_permissions
+            USING permissions
+            WHERE users_permissions.permission_id = permissions.id
+            AND users_permissions.user_id = $1 AND permissions.code = ANY($2)`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -69,3 +99,33 @@ func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
 	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
 	return err
 }
+
+// GetAll() returns every permission code there is.
+func (m PermissionModel) GetAll() (Permissions, error) {
+	query := `SELECT code FROM permissions ORDER BY code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var permissions Permissions
+	for rows.Next() {
+		var permission string
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
@@ -0,0 +1,172 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/ahmad-khatib0/go/project/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateRole = errors.New("duplicate role")
+
+var RoleNameRX = regexp.MustCompile("^[a-z0-9][a-z0-9_-]*$")
+
+type Role struct {
+	ID          int64       `json:"id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	Name        string      `json:"name"`
+	Permissions Permissions `json:"permissions"`
+}
+
+// ValidateRole() checks the role, its permissions must be among the known permission codes.
+func ValidateRole(v *validator.Validator, role *Role, known Permissions) {
+	v.Check(role.Name != "", "name", "must be provided")
+	v.Check(len(role.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(validator.Matches(role.Name, RoleNameRX), "name", "must contain only lowercase letters, digits, - and _")
+
+	v.Check(role.Permissions != nil, "permissions", "must be provided")
+	v.Check(validator.Unique(role.Permissions), "permissions", "must not contain duplicate values")
+	for _, code := range role.Permissions {
+		v.Check(known.Include(code), "permissions", "unknown permission "+code)
+	}
+}
+
+type RoleModel struct {
+	DB DBTX
+}
+
+// Insert() adds the role and its permissions. Call it on the models of a transaction, so a role
+// isn't left without its permissions.
+func (m RoleModel) Insert(role *Role) error {
+	query := `INSERT INTO roles (name) VALUES ($1) RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, role.Name).Scan(&role.ID, &role.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "roles_name_key"`:
+			return ErrDuplicateRole
+		default:
+			return err
+		}
+	}
+
+	query = `INSERT INTO roles_permissions
+            SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)`
+
+	_, err = m.DB.ExecContext(ctx, query, role.ID, pq.Array(role.Permissions))
+	return err
+}
+
+func (m RoleModel) GetByName(name string) (*Role, error) {
+	query := `SELECT roles.id, roles.created_at, roles.name,
+							COALESCE(array_agg(permissions.code ORDER BY permissions.code) FILTER (WHERE permissions.code IS NOT NULL), '{}')
+							FROM roles
+							LEFT JOIN roles_permissions ON roles_permissions.role_id = roles.id
+							LEFT JOIN permissions ON permissions.id = roles_permissions.permission_id
+							WHERE roles.name = $1
+							GROUP BY roles.id`
+
+	var role Role
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, name).Scan(&role.ID, &role.CreatedAt, &role.Name, pq.Array(&role.Permissions))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &role, nil
+}
+
+func (m RoleModel) GetAll() ([]*Role, error) {
+	query := `SELECT roles.id, roles.created_at, roles.name,
+							COALESCE(array_agg(permissions.code ORDER BY permissions.code) FILTER (WHERE permissions.code IS NOT NULL), '{}')
+							FROM roles
+							LEFT JOIN roles_permissions ON roles_permissions.role_id = roles.id
+							LEFT JOIN permissions ON permissions.id = roles_permissions.permission_id
+							GROUP BY roles.id
+							ORDER BY roles.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	roles := []*Role{}
+	for rows.Next() {
+		var role Role
+		err := rows.Scan(&role.ID, &role.CreatedAt, &role.Name, pq.Array(&role.Permissions))
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// GetAllForUser() returns the names of the user's roles.
+func (m RoleModel) GetAllForUser(userID int64) ([]string, error) {
+	query := `SELECT COALESCE(array_agg(roles.name ORDER BY roles.name), '{}')
+							FROM roles
+							INNER JOIN users_roles ON users_roles.role_id = roles.id
+							WHERE users_roles.user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var names []string
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(pq.Array(&names))
+	return names, err
+}
+
+// AddForUser() grants the role to the user, granting it twice is fine. A user that doesn't exist
+// gets ErrRecordNotFound.
+func (m RoleModel) AddForUser(userID int64, role *Role) error {
+	query := `INSERT INTO users_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, role.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "users_roles" violates foreign key constraint "users_roles_user_id_fkey"`:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m RoleModel) RemoveForUser(userID int64, role *Role) error {
+	query := `DELETE FROM users_roles WHERE user_id = $1 AND role_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, role.ID)
+	return err
+}
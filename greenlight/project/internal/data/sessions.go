@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"crypto/sha512"
+	"time"
+)
+
+// Session is a sign in of a user. Its refresh token rotates on every refresh, and it ends on
+// logout, when its refresh token expires, or when a used refresh token shows up again.
+type Session struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	// Current is set on the session of the request's token.
+	Current bool `json:"current"`
+}
+
+type SessionModel struct {
+	DB DBTX
+}
+
+func (m SessionModel) Insert(session *Session) error {
+	query := `INSERT INTO sessions (user_id, user_agent) VALUES ($1, $2) RETURNING id, created_at, last_used_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, session.UserID, session.UserAgent).Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt)
+}
+
+// Delete() ends the session, its tokens are deleted with it.
+func (m SessionModel) Delete(id int64) error {
+	query := `DELETE FROM sessions WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// DeleteForToken() ends the session of the token.
+func (m SessionModel) DeleteForToken(tokenPlaintext string) error {
+	hash := sha512.Sum512([]byte(tokenPlaintext))
+	query := `DELETE FROM sessions WHERE id = (SELECT session_id FROM tokens WHERE hash = $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, hash[:])
+	return err
+}
+
+// DeleteAllForUser() ends all the user's sessions, their tokens are deleted with them.
+func (m SessionModel) DeleteAllForUser(userID int64) error {
+	query := `DELETE FROM sessions WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID)
+	return err
+}
+
+// Touch() records that the session was used. To save a write per request, the time is only
+// updated when it's more than a minute old.
+func (m SessionModel) Touch(id int64) error {
+	query := `UPDATE sessions SET last_used_at = NOW()
+							WHERE id = $1 AND last_used_at < NOW() - interval '1 minute'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// TouchForToken() records that the session of the token was used, see Touch().
+func (m SessionModel) TouchForToken(tokenPlaintext string) error {
+	hash := sha512.Sum512([]byte(tokenPlaintext))
+	query := `UPDATE sessions SET last_used_at = NOW()
+							FROM tokens
+							WHERE tokens.hash = $1 AND sessions.id = tokens.session_id
+							AND sessions.last_used_at < NOW() - interval '1 minute'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, hash[:])
+	return err
+}
+
+// GetAllActiveForUser() returns the user's sessions that still have a valid refresh token, the
+// most recently used first. The session of currentToken is marked as the current one.
+func (m SessionModel) GetAllActiveForUser(userID int64, currentToken string) ([]*Session, error) {
+	hash := sha512.Sum512([]byte(currentToken))
+	query := `SELECT sessions.id, sessions.created_at, sessions.last_used_at, sessions.user_agent,
+							sessions.id IS NOT DISTINCT FROM (SELECT session_id FROM tokens WHERE hash = $2)
+							FROM sessions
+							WHERE sessions.user_id = $1
+							AND EXISTS (
+								SELECT 1 FROM tokens
+								WHERE tokens.session_id = sessions.id
+								AND tokens.scope = $3 AND tokens.used_at IS NULL AND tokens.expiry > $4
+							)
+							ORDER BY sessions.last_used_at DESC, sessions.id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, hash[:], ScopeRefresh, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session := Session{UserID: userID}
+		err := rows.Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt, &session.UserAgent, &session.Current)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
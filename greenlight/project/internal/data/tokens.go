@@ -6,6 +6,7 @@ import (
 	"crypto/sha512"
 	"database/sql"
 	"encoding/base64"
+	"errors"
 	"time"
 
 	"github.com/ahmad-khatib0/go/project/greenlight/internal/validator"
@@ -15,18 +16,25 @@ const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
 	ScopePasswordReset  = "password-reset"
+	ScopeRefresh        = "refresh"
 )
 
+// ErrTokenReused is returned when a refresh token is used a second time.
+var ErrTokenReused = errors.New("token reused")
+
 type Token struct {
 	Plaintext string    `json:"token"`
 	Hash      []byte    `json:"-"`
 	UserID    int64     `json:"-"`
 	Expiry    time.Time `json:"expiry"`
 	Scope     string    `json:"-"`
+	// SessionID is the session of an authentication or refresh token, nil for the other scopes.
+	SessionID *int64     `json:"-"`
+	UsedAt    *time.Time `json:"-"`
 }
 
 type TokenModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
@@ -73,11 +81,23 @@ func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token,
 	return token, err
 }
 
+// NewForSession() creates a new authentication or refresh token of the session.
+func (m TokenModel) NewForSession(session *Session, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(session.UserID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	token.SessionID = &session.ID
+
+	err = m.Insert(token)
+	return token, err
+}
+
 // Insert() adds the data for a specific token to the tokens table.
 func (m TokenModel) Insert(token *Token) error {
-	query := `INSERT INTO tokens (hash, user_id, expiry, scope)  VALUES ($1, $2, $3, $4)`
+	query := `INSERT INTO tokens (hash, user_id, expiry, scope, session_id)  VALUES ($1, $2, $3, $4, $5)`
 
-	args := []interface{}{token.Hash, token.UserID, token.Expiry, token.Scope}
+	args := []interface{}{token.Hash, token.UserID, token.Expiry, token.Scope, token.SessionID}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -96,3 +116,70 @@ func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	_, err := m.DB.ExecContext(ctx, query, scope, userID)
 	return err
 }
+
+// Get() returns the token of the scope, expired and used ones included, so the caller can tell
+// an expired refresh token from a reused one.
+func (m TokenModel) Get(scope, tokenPlaintext string) (*Token, error) {
+	hash := sha512.Sum512([]byte(tokenPlaintext))
+	query := `SELECT user_id, expiry, scope, session_id, used_at FROM tokens WHERE hash = $1 AND scope = $2`
+
+	token := Token{Plaintext: tokenPlaintext, Hash: hash[:]}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, token.Hash, scope).Scan(
+		&token.UserID,
+		&token.Expiry,
+		&token.Scope,
+		&token.SessionID,
+		&token.UsedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &token, nil
+}
+
+// MarkUsed() marks a refresh token as used. It returns ErrTokenReused if it was already used,
+// e.g. by a concurrent refresh with the same token.
+func (m TokenModel) MarkUsed(token *Token) error {
+	query := `UPDATE tokens SET used_at = NOW() WHERE hash = $1 AND used_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, token.Hash)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrTokenReused
+	}
+
+	return nil
+}
+
+// Delete() deletes a single token.
+func (m TokenModel) Delete(tokenPlaintext string) error {
+	hash := sha512.Sum512([]byte(tokenPlaintext))
+	query := `DELETE FROM tokens WHERE hash = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, hash[:])
+	return err
+}
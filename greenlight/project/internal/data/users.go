@@ -27,7 +27,7 @@ type password struct {
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 var (
@@ -85,6 +85,35 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
+func (m UserModel) GetByID(id int64) (*User, error) {
+	query := `SELECT id, created_at, name, email, password_hash, activated, version
+							FROM users WHERE id = $1`
+
+	var user User
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
 func (m UserModel) Update(user *User) error {
 	query := `UPDATE users
              SET name = $1, email = lower($2), password_hash = $3, activated = $4, version = version + 1
@@ -0,0 +1,171 @@
+// Package smtptest provides a local SMTP server for tests, standing in for the SMTP service so
+// the emails the API sends can be inspected without sending anything.
+//
+//	srv, err := smtptest.NewServer()
+//	...
+//	defer srv.Close()
+//	m := mailer.New(srv.Host(), srv.Port(), "", "", "Greenlight <no-reply@greenlight.test>")
+package smtptest
+
+import (
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Message is an email the server accepted.
+type Message struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Server speaks just enough SMTP for the mailer: no TLS and no authentication.
+type Server struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []Message
+	failures int
+	wg       sync.WaitGroup
+}
+
+// NewServer() starts a server on a random local port.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{listener: l}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Messages() returns the messages accepted so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// FailNext() makes the server reject the next n messages with a temporary error, to test retries.
+func (s *Server) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+}
+
+// Close() stops the server. The connections in progress are finished first.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(textproto.NewConn(conn))
+		}()
+	}
+}
+
+func (s *Server) handle(c *textproto.Conn) {
+	reply := func(code int, msg string) bool {
+		return c.PrintfLine("%d %s", code, msg) == nil
+	}
+
+	if !reply(220, "smtptest ready") {
+		return
+	}
+
+	var msg Message
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			msg = Message{}
+			if !reply(250, "smtptest") {
+				return
+			}
+		case "MAIL":
+			msg = Message{From: address(arg)}
+			reply(250, "OK")
+		case "RCPT":
+			msg.To = append(msg.To, address(arg))
+			reply(250, "OK")
+		case "DATA":
+			if !reply(354, "end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			if s.accept(msg) {
+				reply(250, "OK: queued")
+			} else {
+				reply(451, "temporary failure")
+			}
+			msg = Message{}
+		case "RSET":
+			msg = Message{}
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "command not implemented: "+strconv.Quote(verb))
+		}
+	}
+}
+
+func (s *Server) accept(msg Message) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		return false
+	}
+	s.messages = append(s.messages, msg)
+	return true
+}
+
+// address() extracts the address of "FROM:<gopher@example.com>" or "TO:<gopher@example.com>".
+func address(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr = strings.TrimSpace(addr)
+	if i := strings.IndexByte(addr, ' '); i >= 0 {
+		addr = addr[:i]
+	}
+	return strings.Trim(addr, "<>")
+}
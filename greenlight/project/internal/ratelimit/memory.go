@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Memory is a token bucket per key, held in process memory. It's the simplest option for a
+// single instance, but the limits reset when the process restarts and aren't shared between
+// instances, use Redis for that.
+type Memory struct {
+	mu      sync.Mutex
+	clients map[string]*client
+}
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewMemory() returns a Memory limiter, and launches a background goroutine which removes the
+// keys that haven't been seen within the last three minutes, once every minute.
+func NewMemory() *Memory {
+	m := &Memory{clients: make(map[string]*client)}
+
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			m.mu.Lock()
+			for key, client := range m.clients {
+				if time.Since(client.lastSeen) > 3*time.Minute {
+					delete(m.clients, key)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}()
+
+	return m
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	c, found := m.clients[key]
+	if !found {
+		c = &client{limiter: rate.NewLimiter(rate.Limit(limit.RPS), limit.Burst)}
+		m.clients[key] = c
+	}
+	c.lastSeen = now
+
+	allowed := c.limiter.AllowN(now, 1)
+	tokens := c.limiter.TokensAt(now)
+
+	result := Result{
+		Allowed: allowed,
+		Limit:   limit.Burst,
+		Reset:   limit.refill(float64(limit.Burst) - tokens),
+	}
+	if tokens > 0 {
+		result.Remaining = int(tokens)
+	}
+	if !allowed {
+		result.RetryAfter = limit.refill(1 - tokens)
+	}
+	return result, nil
+}
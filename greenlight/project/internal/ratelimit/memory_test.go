@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	limit := Limit{RPS: 1, Burst: 2}
+
+	for i, remaining := range []int{1, 0} {
+		result, err := m.Allow(context.Background(), "a", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != remaining || result.Limit != 2 || result.RetryAfter != 0 {
+			t.Errorf("request %d: got %+v; want allowed with %d remaining", i, result, remaining)
+		}
+	}
+
+	result, err := m.Allow(context.Background(), "a", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.Remaining != 0 {
+		t.Errorf("got %+v; want denied with none remaining", result)
+	}
+	// the bucket is empty, it takes a second to earn a request and two to fill up
+	if result.RetryAfter <= 0 || result.RetryAfter > time.Second {
+		t.Errorf("got retry after %v; want up to a second", result.RetryAfter)
+	}
+	if result.Reset <= time.Second || result.Reset > 2*time.Second {
+		t.Errorf("got reset %v; want between one and two seconds", result.Reset)
+	}
+
+	// the other keys have their own buckets
+	result, err = m.Allow(context.Background(), "b", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed {
+		t.Errorf("got %+v; want another key allowed", result)
+	}
+}
+
+func TestMemoryRefill(t *testing.T) {
+	m := NewMemory()
+	limit := Limit{RPS: 50, Burst: 1}
+
+	for i, allowed := range []bool{true, false} {
+		result, err := m.Allow(context.Background(), "a", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != allowed {
+			t.Fatalf("request %d: got %+v; want allowed %t", i, result, allowed)
+		}
+	}
+
+	time.Sleep(limit.Window() + 10*time.Millisecond)
+	result, err := m.Allow(context.Background(), "a", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed {
+		t.Errorf("got %+v; want allowed after the refill", result)
+	}
+}
+
+func TestMemoryZeroRPS(t *testing.T) {
+	m := NewMemory()
+	limit := Limit{Burst: 1}
+
+	result, err := m.Allow(context.Background(), "a", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed {
+		t.Fatalf("got %+v; want the burst allowed", result)
+	}
+
+	result, err = m.Allow(context.Background(), "a", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.RetryAfter != maxRefill || result.Reset != maxRefill {
+		t.Errorf("got %+v; want denied with retry after and reset %v", result, maxRefill)
+	}
+}
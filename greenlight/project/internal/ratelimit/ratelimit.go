@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit allows RPS requests per second on average, with bursts of up to Burst requests.
+// A limit with an RPS of 0 allows one burst, and never earns requests back.
+type Limit struct {
+	RPS   float64
+	Burst int
+}
+
+// Window is the time it takes to earn a full burst back. The sliding window limiter allows
+// Burst requests per Window, which averages out to the same RPS.
+func (l Limit) Window() time.Duration {
+	return l.refill(float64(l.Burst))
+}
+
+// refill is the time it takes to earn the requests back, capped at maxRefill so that an RPS
+// of 0, or close to it, doesn't overflow the duration.
+func (l Limit) refill(requests float64) time.Duration {
+	if requests <= 0 {
+		return 0
+	}
+	if l.RPS <= 0 || requests/l.RPS >= maxRefill.Seconds() {
+		return maxRefill
+	}
+	return time.Duration(requests / l.RPS * float64(time.Second))
+}
+
+// maxRefill stands in for never: a day is longer than any client waits for a Retry-After.
+const maxRefill = 24 * time.Hour
+
+// Result describes the state of a key's limit after a request, enough to write the
+// RateLimit-* and Retry-After headers.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the quota starts to come back: the time to refill the bucket,
+	// or for the oldest request to leave the window.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, zero when Allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter decides whether the request identified by key is allowed under limit. The keys
+// of different limits must not overlap, the caller prefixes them with the limit's name.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Seconds rounds d up to whole seconds, the unit of the rate limit headers.
+func Seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
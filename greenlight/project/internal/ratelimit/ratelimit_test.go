@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimitWindow(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit Limit
+		want  time.Duration
+	}{
+		{"refills", Limit{RPS: 2, Burst: 4}, 2 * time.Second},
+		{"no burst", Limit{RPS: 2}, 0},
+		{"zero rps", Limit{Burst: 4}, maxRefill},
+		{"tiny rps", Limit{RPS: 1e-300, Burst: 4}, maxRefill},
+	}
+	for _, tt := range tests {
+		if got := tt.limit.Window(); got != tt.want {
+			t.Errorf("%s: got window %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want int
+	}{
+		{0, 0},
+		{time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+	}
+	for _, tt := range tests {
+		if got := Seconds(tt.d); got != tt.want {
+			t.Errorf("Seconds(%v) = %d; want %d", tt.d, got, tt.want)
+		}
+	}
+}
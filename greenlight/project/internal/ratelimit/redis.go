@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindow keeps the timestamps (in microseconds) of the requests allowed within the
+// last window in a sorted set. The script runs atomically, so concurrent requests from
+// several instances can't all see the same count and all get through.
+//
+// It returns whether the request is allowed, how many requests the window holds, and the
+// timestamp of the oldest one, which is when the window frees up its next slot.
+var slidingWindow = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+local count = redis.call("ZCARD", key)
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", key, now, now .. "-" .. ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call("PEXPIRE", key, math.ceil(window / 1000))
+
+local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+return {allowed, count, tonumber(oldest[2]) or now}
+`)
+
+// Redis is a sliding window limiter shared by every instance of the API.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client, prefix: "ratelimit:"}
+}
+
+func (rl *Redis) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+	window := limit.Window()
+
+	res, err := slidingWindow.Run(ctx, rl.client, []string{rl.prefix + key},
+		now.UnixMicro(), window.Microseconds(), limit.Burst, strconv.FormatInt(now.UnixNano(), 36),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, count, oldest := res[0] == 1, int(res[1]), time.UnixMicro(res[2])
+
+	// the window slides: the oldest request drops out first, then the next, and so on
+	untilFree := oldest.Add(window).Sub(now)
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: limit.Burst - count,
+		Reset:     untilFree,
+	}
+	if !allowed {
+		result.RetryAfter = untilFree
+	}
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis connects to REDIS_URL, or a local server, and skips the test when there's none.
+func newTestRedis(t *testing.T) *Redis {
+	t.Helper()
+
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		url = "redis://localhost:6379"
+	}
+	opt, err := redis.ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := redis.NewClient(opt)
+	t.Cleanup(func() { client.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("no redis server at %s: %v", url, err)
+	}
+
+	rl := NewRedis(client)
+	// the keys of every run are new, the previous runs' windows can't count against them
+	rl.prefix = "ratelimit-test:" + strconv.FormatInt(time.Now().UnixNano(), 36) + ":"
+	return rl
+}
+
+func TestRedis(t *testing.T) {
+	rl := newTestRedis(t)
+	limit := Limit{RPS: 1, Burst: 2}
+
+	for i, remaining := range []int{1, 0} {
+		result, err := rl.Allow(context.Background(), "a", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != remaining || result.Limit != 2 || result.RetryAfter != 0 {
+			t.Errorf("request %d: got %+v; want allowed with %d remaining", i, result, remaining)
+		}
+	}
+
+	result, err := rl.Allow(context.Background(), "a", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.Remaining != 0 {
+		t.Errorf("got %+v; want denied with none remaining", result)
+	}
+	// the first request leaves the two second window first
+	if result.RetryAfter <= time.Second || result.RetryAfter > 2*time.Second {
+		t.Errorf("got retry after %v; want between one and two seconds", result.RetryAfter)
+	}
+	if result.Reset != result.RetryAfter {
+		t.Errorf("got reset %v; want the retry after %v", result.Reset, result.RetryAfter)
+	}
+
+	result, err = rl.Allow(context.Background(), "b", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed {
+		t.Errorf("got %+v; want another key allowed", result)
+	}
+}
+
+func TestRedisSlides(t *testing.T) {
+	rl := newTestRedis(t)
+	limit := Limit{RPS: 20, Burst: 1}
+
+	for i, allowed := range []bool{true, false} {
+		result, err := rl.Allow(context.Background(), "a", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != allowed {
+			t.Fatalf("request %d: got %+v; want allowed %t", i, result, allowed)
+		}
+	}
+
+	time.Sleep(limit.Window() + 10*time.Millisecond)
+	result, err := rl.Allow(context.Background(), "a", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed {
+		t.Errorf("got %+v; want allowed once the request left the window", result)
+	}
+}
+
+func TestRedisZeroRPS(t *testing.T) {
+	rl := newTestRedis(t)
+	limit := Limit{Burst: 1}
+
+	result, err := rl.Allow(context.Background(), "a", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed {
+		t.Fatalf("got %+v; want the burst allowed", result)
+	}
+
+	result, err = rl.Allow(context.Background(), "a", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.RetryAfter <= maxRefill-time.Minute || result.RetryAfter > maxRefill {
+		t.Errorf("got %+v; want denied for about %v", result, maxRefill)
+	}
+}
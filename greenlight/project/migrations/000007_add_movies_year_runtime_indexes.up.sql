@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS movies_year_id_idx ON movies (year, id);
+
+CREATE INDEX IF NOT EXISTS movies_runtime_id_idx ON movies (runtime, id);
+
+CREATE INDEX IF NOT EXISTS movies_title_id_idx ON movies (title, id);
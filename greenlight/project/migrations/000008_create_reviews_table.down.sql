@@ -0,0 +1,8 @@
+DELETE FROM permissions WHERE code = 'reviews:write';
+
+DROP TABLE IF EXISTS reviews;
+
+DROP FUNCTION IF EXISTS reviews_update_movie_rating();
+
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_sum;
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_count;
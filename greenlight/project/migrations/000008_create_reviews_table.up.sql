@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS reviews (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+  rating smallint NOT NULL,
+  body text NOT NULL DEFAULT '',
+  version integer NOT NULL DEFAULT 1,
+  CONSTRAINT reviews_rating_check CHECK (rating BETWEEN 1 AND 10),
+  -- One review per user per movie.
+  CONSTRAINT reviews_movie_id_user_id_key UNIQUE (movie_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_user_id_idx ON reviews (user_id);
+
+-- The aggregated rating lives on the movie, so reading a movie doesn't scan its reviews.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_sum bigint NOT NULL DEFAULT 0;
+
+-- The trigger keeps the aggregate consistent with the reviews in the same transaction, whoever
+-- writes the reviews. It doesn't bump movies.version: a new review isn't an edit of the movie.
+CREATE OR REPLACE FUNCTION reviews_update_movie_rating() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    UPDATE movies SET rating_count = rating_count - 1, rating_sum = rating_sum - OLD.rating
+      WHERE id = OLD.movie_id;
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') THEN
+    UPDATE movies SET rating_count = rating_count + 1, rating_sum = rating_sum + NEW.rating
+      WHERE id = NEW.movie_id;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER reviews_movie_rating AFTER INSERT OR UPDATE OF rating, movie_id OR DELETE ON reviews
+  FOR EACH ROW EXECUTE FUNCTION reviews_update_movie_rating();
+
+INSERT INTO permissions (code) VALUES ('reviews:write');
+
+-- The users who can read the movies can review them.
+INSERT INTO users_permissions
+  SELECT users_permissions.user_id, (SELECT id FROM permissions WHERE code = 'reviews:write')
+  FROM users_permissions
+  INNER JOIN permissions ON users_permissions.permission_id = permissions.id
+  WHERE permissions.code = 'movies:read';
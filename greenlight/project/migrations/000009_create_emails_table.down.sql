@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code = 'emails:read';
+
+DROP TABLE IF EXISTS emails;
@@ -0,0 +1,22 @@
+-- The email outbox. Emails are inserted in the transaction of the change they're about and sent
+-- by the outbox workers, so an email is never lost to a restart nor sent for a rolled back change.
+CREATE TABLE IF NOT EXISTS emails (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  recipient text NOT NULL,
+  template text NOT NULL,
+  data jsonb NOT NULL DEFAULT '{}',
+  -- pending until it's sent, failed when it ran out of attempts.
+  status text NOT NULL DEFAULT 'pending',
+  attempts integer NOT NULL DEFAULT 0,
+  next_attempt_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  last_error text NOT NULL DEFAULT '',
+  sent_at timestamp(0) with time zone,
+  CONSTRAINT emails_status_check CHECK (status IN ('pending', 'sent', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS emails_pending_idx ON emails (next_attempt_at) WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS emails_failed_idx ON emails (id) WHERE status = 'failed';
+
+INSERT INTO permissions (code) VALUES ('emails:read');
@@ -0,0 +1,5 @@
+ALTER TABLE tokens DROP COLUMN IF EXISTS used_at;
+ALTER TABLE tokens DROP COLUMN IF EXISTS created_at;
+ALTER TABLE tokens DROP COLUMN IF EXISTS session_id;
+
+DROP TABLE IF EXISTS sessions;
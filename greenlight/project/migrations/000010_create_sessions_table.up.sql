@@ -0,0 +1,18 @@
+-- A session is a sign in: its access tokens and the chain of refresh tokens that renew them.
+CREATE TABLE IF NOT EXISTS sessions (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  last_used_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  user_agent text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+
+-- Deleting a session revokes all its tokens. A used refresh token is kept until it expires, so
+-- we can tell when it's presented again.
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS session_id bigint REFERENCES sessions ON DELETE CASCADE;
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS created_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS used_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS tokens_session_id_idx ON tokens (session_id);
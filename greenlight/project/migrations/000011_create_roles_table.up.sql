@@ -0,0 +1,30 @@
+-- A role bundles permission codes, a user has the permissions of their roles on top of the ones
+-- granted to them directly.
+CREATE TABLE IF NOT EXISTS roles (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  name text UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS roles_permissions (
+  role_id bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+  permission_id bigint NOT NULL REFERENCES permissions ON DELETE CASCADE,
+  PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS users_roles (
+  user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+  role_id bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+  PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS users_roles_role_id_idx ON users_roles (role_id);
+
+INSERT INTO permissions (code) VALUES ('users:admin');
+
+-- The admin role has every permission. The first admin is granted it by hand:
+-- INSERT INTO users_roles SELECT <user id>, id FROM roles WHERE name = 'admin';
+INSERT INTO roles (name) VALUES ('admin');
+
+INSERT INTO roles_permissions
+  SELECT roles.id, permissions.id FROM roles, permissions WHERE roles.name = 'admin';
@@ -11,6 +11,11 @@ import (
 	"github.com/lib/pq"
 )
 
+// currencyConstraint is the foreign key of an account to its currency in the currencies table.
+const currencyConstraint = "accounts_currency_fkey"
+
+var errUnsupportedCurrency = errors.New("unsupported currency")
+
 type createAccountRequest struct {
 	Currency string `json:"currency" binding:"required,currency"`
 }
@@ -32,6 +37,10 @@ func (server *Server) createAccount(ctx *gin.Context) {
 	account, err := server.store.CreateAccount(ctx, arg)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Constraint == currencyConstraint {
+				ctx.JSON(http.StatusBadRequest, errorResponse(errUnsupportedCurrency))
+				return
+			}
 			switch pqErr.Code.Name() {
 			case "foreign_key_violation", "unique_violation":
 				ctx.JSON(http.StatusForbidden, errorResponse(err))
@@ -68,7 +77,7 @@ func (server *Server) getAccount(ctx *gin.Context) {
 	}
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	if account.Owner != authPayload.Username {
+	if !authPayload.CanAccess(account.Owner) {
 		err := errors.New("account doesn't belong to the authenticated user")
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
@@ -78,8 +87,9 @@ func (server *Server) getAccount(ctx *gin.Context) {
 }
 
 type listAccountRequest struct {
-	PageID   int32 `form:"page_id" binding:"required,min=1"`
-	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+	PageID   int32  `form:"page_id" binding:"required,min=1"`
+	PageSize int32  `form:"page_size" binding:"required,min=5,max=10"`
+	Owner    string `form:"owner" binding:"omitempty,alphanum"`
 }
 
 func (server *Server) listAccounts(ctx *gin.Context) {
@@ -90,8 +100,19 @@ func (server *Server) listAccounts(ctx *gin.Context) {
 	}
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	owner := authPayload.Username
+	if req.Owner != "" {
+		owner = req.Owner
+	}
+
+	if !authPayload.CanAccess(owner) {
+		err := errors.New("cannot list other user's accounts")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
 	arg := db.ListAccountsParams{
-		Owner:  authPayload.Username,
+		Owner:  owner,
 		Limit:  req.PageSize,
 		Offset: (req.PageID - 1) * req.PageSize,
 	}
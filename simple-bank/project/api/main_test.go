@@ -6,6 +6,7 @@ import (
 	"time"
 
 	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange/exchangetest"
 	"github.com/ahmad-khatib0/go/simple-bank/project/util"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
@@ -17,7 +18,7 @@ func newTestServer(t *testing.T, store db.Store) *Server {
 		AccessTokenDuration: time.Minute,
 	}
 
-	server, err := NewServer(config, store)
+	server, err := NewServer(config, store, exchangetest.NewRateProvider(t))
 	require.NoError(t, err)
 
 	return server
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange"
 	"github.com/ahmad-khatib0/go/simple-bank/project/token"
 	"github.com/ahmad-khatib0/go/simple-bank/project/util"
 	"github.com/gin-gonic/gin"
@@ -16,11 +17,12 @@ type Server struct {
 	config     util.Config
 	store      db.Store
 	tokenMaker token.Maker
+	rates      exchange.ExchangeRateProvider
 	router     *gin.Engine
 }
 
 // NewServer creates a new HTTP server and set up routing.
-func NewServer(config util.Config, store db.Store) (*Server, error) {
+func NewServer(config util.Config, store db.Store, rates exchange.ExchangeRateProvider) (*Server, error) {
 	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token maker: %w", err)
@@ -30,6 +32,7 @@ func NewServer(config util.Config, store db.Store) (*Server, error) {
 		config:     config,
 		store:      store,
 		tokenMaker: tokenMaker,
+		rates:      rates,
 	}
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
@@ -54,6 +57,10 @@ func (server *Server) setupRouter() {
 
 	authRoutes.POST("/transfers", server.createTransfer)
 
+	authRoutes.GET("/sessions", server.listSessions)
+	authRoutes.POST("/users/logout", server.logout)
+	authRoutes.POST("/users/logout_all", server.logoutAll)
+
 	server.router = router
 }
 
@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange"
 	"github.com/ahmad-khatib0/go/simple-bank/project/token"
 	"github.com/gin-gonic/gin"
 )
@@ -18,6 +19,9 @@ type transferRequest struct {
 	Currency      string `json:"currency" binding:"required,currency"`
 }
 
+// idempotencyKeyHeader is the header of the key that makes retrying a transfer safe.
+const idempotencyKeyHeader = "Idempotency-Key"
+
 func (server *Server) createTransfer(ctx *gin.Context) {
 	var req transferRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -37,8 +41,14 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 		return
 	}
 
-	_, valid = server.validAccount(ctx, req.ToAccountID, req.Currency)
-	if !valid {
+	toAccount, err := server.store.GetAccount(ctx, req.ToAccountID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
@@ -48,8 +58,42 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 		Amount:        req.Amount,
 	}
 
+	// the amount is in the currency of the from-account, the to-account gets it converted to its own
+	if toAccount.Currency != fromAccount.Currency {
+		toAmount, rate, err := exchange.Convert(ctx, server.rates, fromAccount.Currency, toAccount.Currency, req.Amount)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		arg.ToAmount = toAmount
+		arg.ExchangeRate = rate.String()
+	}
+
+	if key := ctx.GetHeader(idempotencyKeyHeader); key != "" {
+		if len(key) > 255 {
+			err := fmt.Errorf("%s must not be longer than 255 characters", idempotencyKeyHeader)
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		arg.Username = authPayload.Username
+		arg.IdempotencyKey = key
+		arg.IdempotencyKeyDuration = server.config.IdempotencyKeyDuration
+	}
+
 	result, err := server.store.TransferTx(ctx, arg)
 	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrInsufficientFunds),
+			errors.Is(err, db.ErrTransferLimitExceeded),
+			errors.Is(err, db.ErrDailyLimitExceeded):
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		case errors.Is(err, db.ErrIdempotencyKeyConflict):
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
@@ -0,0 +1,4 @@
+ALTER TABLE "accounts" DROP COLUMN "transfer_limit";
+ALTER TABLE "accounts" DROP COLUMN "daily_transfer_limit";
+ALTER TABLE "accounts" DROP COLUMN "daily_transferred";
+ALTER TABLE "accounts" DROP COLUMN "daily_transferred_on";
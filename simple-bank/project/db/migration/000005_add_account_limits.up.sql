@@ -0,0 +1,10 @@
+-- 0 means no limit
+ALTER TABLE "accounts" ADD COLUMN "transfer_limit" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "accounts" ADD COLUMN "daily_transfer_limit" bigint NOT NULL DEFAULT 0;
+
+-- the amount transferred out of the account on daily_transferred_on
+ALTER TABLE "accounts" ADD COLUMN "daily_transferred" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "accounts" ADD COLUMN "daily_transferred_on" date NOT NULL DEFAULT (CURRENT_DATE);
+
+ALTER TABLE "accounts" ADD CONSTRAINT "transfer_limit_check" CHECK ("transfer_limit" >= 0);
+ALTER TABLE "accounts" ADD CONSTRAINT "daily_transfer_limit_check" CHECK ("daily_transfer_limit" >= 0);
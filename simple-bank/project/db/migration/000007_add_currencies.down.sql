@@ -0,0 +1,6 @@
+ALTER TABLE "transfers" DROP COLUMN "exchange_rate";
+ALTER TABLE "transfers" DROP COLUMN "to_amount";
+
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "accounts_currency_fkey";
+
+DROP TABLE IF EXISTS "currencies";
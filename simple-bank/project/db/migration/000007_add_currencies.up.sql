@@ -0,0 +1,23 @@
+CREATE TABLE "currencies" (
+  "code" varchar PRIMARY KEY,
+  "name" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+-- the currencies the server supported before they moved to this table
+INSERT INTO "currencies" ("code", "name") VALUES
+  ('USD', 'US Dollar'),
+  ('EUR', 'Euro'),
+  ('CAD', 'Canadian Dollar');
+
+ALTER TABLE "accounts" ADD FOREIGN KEY ("currency") REFERENCES "currencies" ("code");
+
+-- a transfer debits amount in the currency of the from-account, and credits to_amount in the
+-- currency of the to-account: amount * exchange_rate
+ALTER TABLE "transfers" ADD COLUMN "to_amount" bigint;
+UPDATE "transfers" SET "to_amount" = "amount";
+ALTER TABLE "transfers" ALTER COLUMN "to_amount" SET NOT NULL;
+
+ALTER TABLE "transfers" ADD COLUMN "exchange_rate" numeric(20, 8) NOT NULL DEFAULT 1;
+
+COMMENT ON COLUMN "transfers"."to_amount" IS 'must be positive';
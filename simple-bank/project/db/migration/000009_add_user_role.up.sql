@@ -0,0 +1,2 @@
+-- depositors own accounts, bankers may view and update any user and account
+ALTER TABLE "users" ADD COLUMN "role" varchar NOT NULL DEFAULT 'depositor';
@@ -0,0 +1,2 @@
+ALTER TABLE "sessions" DROP COLUMN "is_rotated";
+ALTER TABLE "sessions" DROP COLUMN "family_id";
@@ -0,0 +1,10 @@
+-- every refresh rotates the session into a new one of the same family, the first session's id
+ALTER TABLE "sessions" ADD COLUMN "family_id" uuid;
+UPDATE "sessions" SET "family_id" = "id";
+ALTER TABLE "sessions" ALTER COLUMN "family_id" SET NOT NULL;
+
+-- a rotated session's refresh token must not be used again
+ALTER TABLE "sessions" ADD COLUMN "is_rotated" boolean NOT NULL DEFAULT false;
+
+CREATE INDEX ON "sessions" ("family_id");
+CREATE INDEX ON "sessions" ("username");
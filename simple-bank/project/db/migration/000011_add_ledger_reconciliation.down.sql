@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "ledger_discrepancies";
+DROP TABLE IF EXISTS "reconciliation_runs";
+ALTER TABLE "entries" DROP COLUMN "transfer_id";
@@ -0,0 +1,49 @@
+ALTER TABLE "entries" ADD COLUMN "transfer_id" bigint;
+
+ALTER TABLE "entries" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+CREATE INDEX ON "entries" ("transfer_id");
+
+-- TransferTx creates a transfer and its two entries in one transaction, so they share its now()
+UPDATE "entries" e
+SET "transfer_id" = t."id"
+FROM "transfers" t
+WHERE
+  e."transfer_id" IS NULL AND
+  e."created_at" = t."created_at" AND (
+    (e."account_id" = t."from_account_id" AND e."amount" = -t."amount") OR
+    (e."account_id" = t."to_account_id" AND e."amount" = t."to_amount")
+  );
+
+CREATE TABLE "reconciliation_runs" (
+  "id" bigserial PRIMARY KEY,
+  "accounts_checked" bigint NOT NULL DEFAULT 0,
+  "transfers_checked" bigint NOT NULL DEFAULT 0,
+  "discrepancies" bigint NOT NULL DEFAULT 0,
+  "started_at" timestamptz NOT NULL DEFAULT (now()),
+  "finished_at" timestamptz
+);
+
+CREATE TABLE "ledger_discrepancies" (
+  "id" bigserial PRIMARY KEY,
+  "run_id" bigint NOT NULL,
+  "kind" varchar NOT NULL,
+  "account_id" bigint,
+  "transfer_id" bigint,
+  "expected" bigint NOT NULL,
+  "actual" bigint NOT NULL,
+  "details" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "ledger_discrepancies" ADD FOREIGN KEY ("run_id") REFERENCES "reconciliation_runs" ("id");
+
+ALTER TABLE "ledger_discrepancies" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "ledger_discrepancies" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+CREATE INDEX ON "ledger_discrepancies" ("run_id");
+
+COMMENT ON COLUMN "reconciliation_runs"."finished_at" IS 'null while the run is in progress, or when it failed';
+
+COMMENT ON COLUMN "ledger_discrepancies"."kind" IS 'account_balance, transfer_entries or transfer_amounts';
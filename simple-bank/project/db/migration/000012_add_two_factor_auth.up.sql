@@ -0,0 +1,31 @@
+-- the TOTP secret is set on enrolment, and only used for logins once a code of it activated it.
+-- totp_last_step is the step of the last code accepted, so that a code can't be replayed
+ALTER TABLE "users" ADD COLUMN "totp_secret" varchar NOT NULL DEFAULT '';
+ALTER TABLE "users" ADD COLUMN "is_totp_enabled" bool NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN "totp_last_step" bigint NOT NULL DEFAULT 0;
+
+-- a login challenge is created when the password of a user with two-factor authentication is right,
+-- its id is the pre-auth token exchanged with a code for the access and refresh tokens
+CREATE TABLE "login_challenges" (
+  "id" uuid PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "email_code" varchar NOT NULL DEFAULT '',
+  "email_codes_sent" int NOT NULL DEFAULT 0,
+  "attempts" int NOT NULL DEFAULT 0,
+  "is_used" bool NOT NULL DEFAULT false,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "hashed_code" varchar NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "login_challenges" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE INDEX ON "recovery_codes" ("username");
@@ -0,0 +1,6 @@
+DELETE FROM "idempotency_keys" WHERE "scope" != 'client';
+
+ALTER TABLE "idempotency_keys" DROP CONSTRAINT "idempotency_keys_pkey";
+ALTER TABLE "idempotency_keys" ADD PRIMARY KEY ("username", "key");
+
+ALTER TABLE "idempotency_keys" DROP COLUMN "scope";
@@ -0,0 +1,6 @@
+-- the keys of the bank's own transfers, like the runs of scheduled transfers, are kept apart from
+-- the keys clients send, so a client can't claim the key of a run before it's made
+ALTER TABLE "idempotency_keys" ADD COLUMN "scope" varchar NOT NULL DEFAULT 'client';
+
+ALTER TABLE "idempotency_keys" DROP CONSTRAINT "idempotency_keys_pkey";
+ALTER TABLE "idempotency_keys" ADD PRIMARY KEY ("username", "scope", "key");
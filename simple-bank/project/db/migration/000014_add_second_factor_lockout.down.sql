@@ -0,0 +1,5 @@
+UPDATE "login_challenges" SET "hashed_email_code" = '';
+ALTER TABLE "login_challenges" RENAME COLUMN "hashed_email_code" TO "email_code";
+
+ALTER TABLE "users" DROP COLUMN "second_factor_locked_until";
+ALTER TABLE "users" DROP COLUMN "failed_second_factor_attempts";
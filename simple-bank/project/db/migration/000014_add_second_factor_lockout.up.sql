@@ -0,0 +1,8 @@
+-- failed_second_factor_attempts counts the incorrect codes in a row, whatever pre-auth token they were tried
+-- with; too many of them lock the second factor of the user until second_factor_locked_until
+ALTER TABLE "users" ADD COLUMN "failed_second_factor_attempts" int NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN "second_factor_locked_until" timestamptz NOT NULL DEFAULT ('0001-01-01 00:00:00Z');
+
+-- the codes sent by email are only stored hashed, like the recovery codes, so the ones sent already stop working
+ALTER TABLE "login_challenges" RENAME COLUMN "email_code" TO "hashed_email_code";
+UPDATE "login_challenges" SET "hashed_email_code" = '';
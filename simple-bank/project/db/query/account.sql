@@ -1,55 +1,68 @@
---
---
---
 -- name: CreateAccount :one
-INSERT INTO accounts (owner, balance, currency)
-VALUES ($ 1, $ 2, $ 3)
-RETURNING *;
---
---
---
+INSERT INTO accounts (
+  owner,
+  balance,
+  currency
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
 -- name: GetAccount :one
-SELECT *
-FROM accounts
-WHERE id = $ 1
-LIMIT 1;
---
---
+SELECT * FROM accounts
+WHERE id = $1 LIMIT 1;
+
 -- name: GetAccountForUpdate :one
-SELECT *
-FROM accounts
-WHERE id = $ 1
-LIMIT 1 FOR NO KEY
-UPDATE;
---
---
---
+SELECT * FROM accounts
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
 -- name: ListAccounts :many
-SELECT *
-FROM accounts
-WHERE owner = $ 1
+SELECT * FROM accounts
+WHERE owner = $1
 ORDER BY id
-LIMIT $ 2 OFFSET $ 3;
---
---
---
+LIMIT $2
+OFFSET $3;
+
 -- name: UpdateAccount :one
 UPDATE accounts
-SET balance = $ 2
-WHERE id = $ 1
+SET balance = $2
+WHERE id = $1
 RETURNING *;
---
---
---
--- this sqlc.arg(amount) will be the name of the generated arguments
+
 -- name: AddAccountBalance :one
 UPDATE accounts
 SET balance = balance + sqlc.arg(amount)
 WHERE id = sqlc.arg(id)
 RETURNING *;
---
---
---
+
+-- DebitAccount takes the amount out of the account, and adds it to the amount transferred
+-- out of the account today. The caller checks the balance and the limits on the result.
+-- name: DebitAccount :one
+UPDATE accounts
+SET
+  balance = balance - sqlc.arg(amount),
+  daily_transferred = CASE
+    WHEN daily_transferred_on = CURRENT_DATE THEN daily_transferred
+    ELSE 0
+  END + sqlc.arg(amount),
+  daily_transferred_on = CURRENT_DATE
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: UpdateAccountLimits :one
+UPDATE accounts
+SET
+  transfer_limit = COALESCE(sqlc.narg(transfer_limit), transfer_limit),
+  daily_transfer_limit = COALESCE(sqlc.narg(daily_transfer_limit), daily_transfer_limit)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
 -- name: DeleteAccount :exec
 DELETE FROM accounts
-WHERE id = $ 1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: ListAllAccounts :many
+SELECT * FROM accounts
+WHERE id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg('limit');
@@ -1,9 +1,10 @@
 -- name: CreateEntry :one
 INSERT INTO entries (
   account_id,
-  amount
+  amount,
+  transfer_id
 ) VALUES (
-  $1, $2
+  $1, $2, $3
 ) RETURNING *;
 
 -- name: GetEntry :one
@@ -16,3 +17,19 @@ WHERE account_id = $1
 ORDER BY id
 LIMIT $2
 OFFSET $3;
+
+-- name: ListEntriesBetween :many
+SELECT * FROM entries
+WHERE account_id = sqlc.arg(account_id)
+  AND created_at >= sqlc.arg(from_time)
+  AND created_at < sqlc.arg(to_time)
+ORDER BY created_at, id;
+
+-- name: GetAccountBalanceAt :one
+-- the balance now minus the entries made since, in a single statement so both come from the same snapshot
+SELECT (a.balance - COALESCE((
+  SELECT SUM(e.amount) FROM entries e
+  WHERE e.account_id = a.id AND e.created_at >= sqlc.arg(at)
+), 0))::bigint AS balance
+FROM accounts a
+WHERE a.id = sqlc.arg(account_id);
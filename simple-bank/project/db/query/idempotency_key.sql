@@ -0,0 +1,49 @@
+-- ClaimIdempotencyKey adds the key, or takes over the key when it has expired. It returns no
+-- row when the key is in use. A concurrent claim of the same key waits for the transaction
+-- holding it to end.
+-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (
+  username,
+  scope,
+  key,
+  from_account_id,
+  to_account_id,
+  amount,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (username, scope, key) DO UPDATE
+SET
+  from_account_id = EXCLUDED.from_account_id,
+  to_account_id = EXCLUDED.to_account_id,
+  amount = EXCLUDED.amount,
+  transfer_id = NULL,
+  result = '{}',
+  created_at = now(),
+  expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at <= now()
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE username = $1 AND scope = $2 AND key = $3 LIMIT 1;
+
+-- name: SetIdempotencyKeyResult :exec
+UPDATE idempotency_keys
+SET
+  transfer_id = $4,
+  result = $5
+WHERE username = $1 AND scope = $2 AND key = $3;
+
+
+-- DeleteExpiredIdempotencyKeys deletes up to limit of the keys that expired, a claim of an
+-- expired key takes it over anyway. The expiry is checked again on the rows we delete, a key
+-- claimed while we waited for it isn't expired anymore.
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE expires_at <= now() AND (username, scope, key) IN (
+  SELECT username, scope, key FROM idempotency_keys
+  WHERE expires_at <= now()
+  LIMIT sqlc.arg('limit')
+);
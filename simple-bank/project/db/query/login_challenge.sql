@@ -0,0 +1,57 @@
+-- name: CreateLoginChallenge :one
+INSERT INTO login_challenges (
+  id,
+  username,
+  expires_at
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: AttemptLoginChallenge :one
+-- AttemptLoginChallenge counts an attempt at a code. It returns no rows when the challenge was used,
+-- expired, or had its attempts already, or when the second factor of its user is locked.
+UPDATE login_challenges
+SET attempts = attempts + 1
+WHERE
+  id = sqlc.arg(id) AND
+  is_used = false AND
+  expires_at > now() AND
+  attempts < sqlc.arg(max_attempts) AND
+  NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE
+      users.username = login_challenges.username AND
+      users.second_factor_locked_until > now()
+  )
+RETURNING *;
+
+-- name: UseLoginChallenge :one
+-- UseLoginChallenge returns no rows when the challenge was used already.
+UPDATE login_challenges
+SET is_used = true
+WHERE
+  id = $1 AND
+  is_used = false
+RETURNING *;
+
+-- name: CountLoginCodeSent :one
+-- CountLoginCodeSent counts an email code sent for the challenge. It returns no rows when the challenge
+-- was used, expired, or had its codes sent already.
+UPDATE login_challenges
+SET email_codes_sent = email_codes_sent + 1
+WHERE
+  id = sqlc.arg(id) AND
+  is_used = false AND
+  expires_at > now() AND
+  email_codes_sent < sqlc.arg(max_sent)
+RETURNING *;
+
+-- name: SetLoginChallengeEmailCode :one
+-- SetLoginChallengeEmailCode returns no rows when the challenge was used or expired.
+UPDATE login_challenges
+SET hashed_email_code = $2
+WHERE
+  id = $1 AND
+  is_used = false AND
+  expires_at > now()
+RETURNING *;
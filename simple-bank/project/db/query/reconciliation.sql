@@ -0,0 +1,67 @@
+-- name: CreateReconciliationRun :one
+INSERT INTO reconciliation_runs DEFAULT VALUES
+RETURNING *;
+
+-- name: FinishReconciliationRun :one
+UPDATE reconciliation_runs
+SET
+  accounts_checked = sqlc.arg(accounts_checked),
+  transfers_checked = sqlc.arg(transfers_checked),
+  discrepancies = sqlc.arg(discrepancies),
+  finished_at = now()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CreateLedgerDiscrepancy :one
+INSERT INTO ledger_discrepancies (
+  run_id,
+  kind,
+  account_id,
+  transfer_id,
+  expected,
+  actual,
+  details
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+) RETURNING *;
+
+-- name: ListLedgerDiscrepancies :many
+SELECT * FROM ledger_discrepancies
+WHERE run_id = $1
+ORDER BY id;
+
+-- name: ListAccountBalanceChecks :many
+-- ListAccountBalanceChecks returns the balance of a page of accounts next to the sum of their entries,
+-- both from the same snapshot, so transfers made meanwhile don't show up as discrepancies.
+SELECT
+  a.id,
+  a.balance,
+  COALESCE(SUM(e.amount), 0)::bigint AS entries_total
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+WHERE a.id > sqlc.arg(after_id)
+GROUP BY a.id
+ORDER BY a.id
+LIMIT sqlc.arg('limit');
+
+-- name: ListTransferEntryChecks :many
+-- ListTransferEntryChecks returns a page of transfers with the currencies of their accounts, the number
+-- of their entries, and the sum of the entries made to the from-account and the to-account.
+SELECT
+  t.id,
+  t.amount,
+  t.to_amount,
+  t.exchange_rate,
+  fa.currency AS from_currency,
+  ta.currency AS to_currency,
+  COUNT(e.id)::bigint AS entry_count,
+  COALESCE(SUM(e.amount) FILTER (WHERE e.account_id = t.from_account_id), 0)::bigint AS from_total,
+  COALESCE(SUM(e.amount) FILTER (WHERE e.account_id = t.to_account_id), 0)::bigint AS to_total
+FROM transfers t
+JOIN accounts fa ON fa.id = t.from_account_id
+JOIN accounts ta ON ta.id = t.to_account_id
+LEFT JOIN entries e ON e.transfer_id = t.id
+WHERE t.id > sqlc.arg(after_id)
+GROUP BY t.id, fa.currency, ta.currency
+ORDER BY t.id
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,27 @@
+-- name: CreateRecoveryCode :one
+INSERT INTO recovery_codes (
+  username,
+  hashed_code
+) VALUES (
+  $1, $2
+) RETURNING *;
+
+-- name: ListUnusedRecoveryCodes :many
+SELECT * FROM recovery_codes
+WHERE
+  username = $1 AND
+  used_at IS NULL
+ORDER BY id;
+
+-- name: UseRecoveryCode :one
+-- UseRecoveryCode returns no rows when the code was used already.
+UPDATE recovery_codes
+SET used_at = now()
+WHERE
+  id = $1 AND
+  used_at IS NULL
+RETURNING *;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE username = $1;
@@ -0,0 +1,70 @@
+-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (
+  owner,
+  from_account_id,
+  to_account_id,
+  amount,
+  schedule,
+  next_run_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: GetScheduledTransfer :one
+SELECT * FROM scheduled_transfers
+WHERE id = $1 LIMIT 1;
+
+-- name: ListScheduledTransfers :many
+SELECT * FROM scheduled_transfers
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3;
+
+-- name: ListDueScheduledTransfers :many
+SELECT * FROM scheduled_transfers
+WHERE status = 'active' AND next_run_at <= sqlc.arg(now)::timestamptz
+ORDER BY next_run_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: CancelScheduledTransfer :one
+UPDATE scheduled_transfers
+SET
+  status = 'cancelled',
+  next_run_at = NULL
+WHERE id = $1 AND status = 'active'
+RETURNING *;
+
+-- name: AdvanceScheduledTransfer :one
+-- moves the transfer on to its next run, or completes it when there's none. It only updates the
+-- transfer if it's still waiting for the run it's advanced from.
+UPDATE scheduled_transfers
+SET
+  next_run_at = sqlc.narg(next_run_at),
+  status = CASE WHEN sqlc.narg(next_run_at)::timestamptz IS NULL THEN 'completed' ELSE status END
+WHERE
+  id = sqlc.arg(id)
+  AND status = 'active'
+  AND next_run_at = sqlc.arg(scheduled_for)::timestamptz
+RETURNING *;
+
+-- name: CreateScheduledTransferRun :one
+INSERT INTO scheduled_transfer_runs (
+  scheduled_transfer_id,
+  scheduled_for,
+  transfer_id,
+  error
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: ListScheduledTransferRuns :many
+SELECT * FROM scheduled_transfer_runs
+WHERE scheduled_transfer_id = $1
+ORDER BY scheduled_for DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: GetScheduledTransferRun :one
+SELECT * FROM scheduled_transfer_runs
+WHERE id = $1 LIMIT 1;
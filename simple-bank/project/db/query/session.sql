@@ -1,6 +1,7 @@
 -- name: CreateSession :one
 INSERT INTO sessions (
   id,
+  family_id,
   username,
   refresh_token,
   user_agent,
@@ -8,9 +9,41 @@ INSERT INTO sessions (
   is_blocked,
   expires_at
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7
+  $1, $2, $3, $4, $5, $6, $7, $8
 ) RETURNING *;
 
 -- name: GetSession :one
 SELECT * FROM sessions
 WHERE id = $1 LIMIT 1;
+
+-- name: ListActiveSessions :many
+-- ListActiveSessions returns the latest session of every family of the user that can still be refreshed.
+SELECT * FROM sessions
+WHERE
+  username = $1 AND
+  is_blocked = false AND
+  is_rotated = false AND
+  expires_at > now()
+ORDER BY created_at DESC;
+
+-- name: RotateSession :one
+-- RotateSession returns no rows when the session was rotated or blocked already.
+UPDATE sessions
+SET is_rotated = true
+WHERE
+  id = $1 AND
+  is_rotated = false AND
+  is_blocked = false
+RETURNING *;
+
+-- name: BlockSessionFamily :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE family_id = $1;
+
+-- name: BlockUserSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE
+  username = $1 AND
+  is_blocked = false;
@@ -23,3 +23,53 @@ SET
 WHERE
   username = sqlc.arg(username)
 RETURNING *;
+
+-- name: SetTOTPSecret :one
+-- SetTOTPSecret returns no rows when the user has two-factor authentication enabled already.
+UPDATE users
+SET totp_secret = $2
+WHERE
+  username = $1 AND
+  is_totp_enabled = false
+RETURNING *;
+
+-- name: EnableTOTP :one
+-- EnableTOTP returns no rows when the user has two-factor authentication enabled already.
+UPDATE users
+SET
+  is_totp_enabled = true,
+  totp_last_step = $2
+WHERE
+  username = $1 AND
+  is_totp_enabled = false
+RETURNING *;
+
+-- name: UpdateTOTPLastStep :one
+-- UpdateTOTPLastStep returns no rows when a code of the step, or a later one, was accepted already.
+UPDATE users
+SET totp_last_step = $2
+WHERE
+  username = $1 AND
+  totp_last_step < $2
+RETURNING *;
+
+-- name: RecordFailedSecondFactor :one
+-- RecordFailedSecondFactor counts an incorrect code of the user's second factor. The max_attempts-th
+-- one in a row locks the second factor until locked_until, and starts the count over.
+UPDATE users
+SET
+  failed_second_factor_attempts = CASE
+    WHEN failed_second_factor_attempts + 1 >= sqlc.arg(max_attempts)::int THEN 0
+    ELSE failed_second_factor_attempts + 1
+  END,
+  second_factor_locked_until = CASE
+    WHEN failed_second_factor_attempts + 1 >= sqlc.arg(max_attempts)::int THEN sqlc.arg(locked_until)::timestamptz
+    ELSE second_factor_locked_until
+  END
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: ResetFailedSecondFactor :exec
+UPDATE users
+SET failed_second_factor_attempts = 0
+WHERE username = $1;
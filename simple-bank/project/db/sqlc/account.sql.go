@@ -7,13 +7,14 @@ package db
 
 import (
 	"context"
+	"database/sql"
 )
 
 const addAccountBalance = `-- name: AddAccountBalance :one
 UPDATE accounts
 SET balance = balance + $1
 WHERE id = $2
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, transfer_limit, daily_transfer_limit, daily_transferred, daily_transferred_on
 `
 
 type AddAccountBalanceParams struct {
@@ -30,6 +31,10 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.TransferLimit,
+		&i.DailyTransferLimit,
+		&i.DailyTransferred,
+		&i.DailyTransferredOn,
 	)
 	return i, err
 }
@@ -41,7 +46,7 @@ INSERT INTO accounts (
   currency
 ) VALUES (
   $1, $2, $3
-) RETURNING id, owner, balance, currency, created_at
+) RETURNING id, owner, balance, currency, created_at, transfer_limit, daily_transfer_limit, daily_transferred, daily_transferred_on
 `
 
 type CreateAccountParams struct {
@@ -59,6 +64,47 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.TransferLimit,
+		&i.DailyTransferLimit,
+		&i.DailyTransferred,
+		&i.DailyTransferredOn,
+	)
+	return i, err
+}
+
+const debitAccount = `-- name: DebitAccount :one
+UPDATE accounts
+SET
+  balance = balance - $1,
+  daily_transferred = CASE
+    WHEN daily_transferred_on = CURRENT_DATE THEN daily_transferred
+    ELSE 0
+  END + $1,
+  daily_transferred_on = CURRENT_DATE
+WHERE id = $2
+RETURNING id, owner, balance, currency, created_at, transfer_limit, daily_transfer_limit, daily_transferred, daily_transferred_on
+`
+
+type DebitAccountParams struct {
+	Amount int64 `json:"amount"`
+	ID     int64 `json:"id"`
+}
+
+// DebitAccount takes the amount out of the account, and adds it to the amount transferred
+// out of the account today. The caller checks the balance and the limits on the result.
+func (q *Queries) DebitAccount(ctx context.Context, arg DebitAccountParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, debitAccount, arg.Amount, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.TransferLimit,
+		&i.DailyTransferLimit,
+		&i.DailyTransferred,
+		&i.DailyTransferredOn,
 	)
 	return i, err
 }
@@ -74,7 +120,7 @@ func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
 }
 
 const getAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, transfer_limit, daily_transfer_limit, daily_transferred, daily_transferred_on FROM accounts
 WHERE id = $1 LIMIT 1
 `
 
@@ -87,12 +133,16 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.TransferLimit,
+		&i.DailyTransferLimit,
+		&i.DailyTransferred,
+		&i.DailyTransferredOn,
 	)
 	return i, err
 }
 
 const getAccountForUpdate = `-- name: GetAccountForUpdate :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, transfer_limit, daily_transfer_limit, daily_transferred, daily_transferred_on FROM accounts
 WHERE id = $1 LIMIT 1
 FOR NO KEY UPDATE
 `
@@ -106,12 +156,16 @@ func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, e
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.TransferLimit,
+		&i.DailyTransferLimit,
+		&i.DailyTransferred,
+		&i.DailyTransferredOn,
 	)
 	return i, err
 }
 
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, transfer_limit, daily_transfer_limit, daily_transferred, daily_transferred_on FROM accounts
 WHERE owner = $1
 ORDER BY id
 LIMIT $2
@@ -139,6 +193,55 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.TransferLimit,
+			&i.DailyTransferLimit,
+			&i.DailyTransferred,
+			&i.DailyTransferredOn,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllAccounts = `-- name: ListAllAccounts :many
+SELECT id, owner, balance, currency, created_at, transfer_limit, daily_transfer_limit, daily_transferred, daily_transferred_on FROM accounts
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type ListAllAccountsParams struct {
+	AfterID int64 `json:"after_id"`
+	Limit   int32 `json:"limit"`
+}
+
+func (q *Queries) ListAllAccounts(ctx context.Context, arg ListAllAccountsParams) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, listAllAccounts, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.TransferLimit,
+			&i.DailyTransferLimit,
+			&i.DailyTransferred,
+			&i.DailyTransferredOn,
 		); err != nil {
 			return nil, err
 		}
@@ -157,7 +260,7 @@ const updateAccount = `-- name: UpdateAccount :one
 UPDATE accounts
 SET balance = $2
 WHERE id = $1
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, transfer_limit, daily_transfer_limit, daily_transferred, daily_transferred_on
 `
 
 type UpdateAccountParams struct {
@@ -174,6 +277,42 @@ func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.TransferLimit,
+		&i.DailyTransferLimit,
+		&i.DailyTransferred,
+		&i.DailyTransferredOn,
+	)
+	return i, err
+}
+
+const updateAccountLimits = `-- name: UpdateAccountLimits :one
+UPDATE accounts
+SET
+  transfer_limit = COALESCE($1, transfer_limit),
+  daily_transfer_limit = COALESCE($2, daily_transfer_limit)
+WHERE id = $3
+RETURNING id, owner, balance, currency, created_at, transfer_limit, daily_transfer_limit, daily_transferred, daily_transferred_on
+`
+
+type UpdateAccountLimitsParams struct {
+	TransferLimit      sql.NullInt64 `json:"transfer_limit"`
+	DailyTransferLimit sql.NullInt64 `json:"daily_transfer_limit"`
+	ID                 int64         `json:"id"`
+}
+
+func (q *Queries) UpdateAccountLimits(ctx context.Context, arg UpdateAccountLimitsParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountLimits, arg.TransferLimit, arg.DailyTransferLimit, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.TransferLimit,
+		&i.DailyTransferLimit,
+		&i.DailyTransferred,
+		&i.DailyTransferredOn,
 	)
 	return i, err
 }
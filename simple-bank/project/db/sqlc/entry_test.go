@@ -21,6 +21,7 @@ func createRandomEntry(t *testing.T, account Account) Entry {
 
 	require.Equal(t, arg.AccountID, entry.AccountID)
 	require.Equal(t, arg.Amount, entry.Amount)
+	require.False(t, entry.TransferID.Valid)
 
 	require.NotZero(t, entry.ID)
 	require.NotZero(t, entry.CreatedAt)
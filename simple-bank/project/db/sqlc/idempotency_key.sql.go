@@ -0,0 +1,153 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: idempotency_key.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const claimIdempotencyKey = `-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (
+  username,
+  scope,
+  key,
+  from_account_id,
+  to_account_id,
+  amount,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (username, scope, key) DO UPDATE
+SET
+  from_account_id = EXCLUDED.from_account_id,
+  to_account_id = EXCLUDED.to_account_id,
+  amount = EXCLUDED.amount,
+  transfer_id = NULL,
+  result = '{}',
+  created_at = now(),
+  expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at <= now()
+RETURNING username, key, from_account_id, to_account_id, amount, transfer_id, result, created_at, expires_at, scope
+`
+
+type ClaimIdempotencyKeyParams struct {
+	Username      string    `json:"username"`
+	Scope         string    `json:"scope"`
+	Key           string    `json:"key"`
+	FromAccountID int64     `json:"from_account_id"`
+	ToAccountID   int64     `json:"to_account_id"`
+	Amount        int64     `json:"amount"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// ClaimIdempotencyKey adds the key, or takes over the key when it has expired. It returns no
+// row when the key is in use. A concurrent claim of the same key waits for the transaction
+// holding it to end.
+func (q *Queries) ClaimIdempotencyKey(ctx context.Context, arg ClaimIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, claimIdempotencyKey,
+		arg.Username,
+		arg.Scope,
+		arg.Key,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.ExpiresAt,
+	)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Username,
+		&i.Key,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.TransferID,
+		&i.Result,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.Scope,
+	)
+	return i, err
+}
+
+const deleteExpiredIdempotencyKeys = `-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE expires_at <= now() AND (username, scope, key) IN (
+  SELECT username, scope, key FROM idempotency_keys
+  WHERE expires_at <= now()
+  LIMIT $1
+)
+`
+
+// DeleteExpiredIdempotencyKeys deletes up to limit of the keys that expired, a claim of an
+// expired key takes it over anyway. The expiry is checked again on the rows we delete, a key
+// claimed while we waited for it isn't expired anymore.
+func (q *Queries) DeleteExpiredIdempotencyKeys(ctx context.Context, limit int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredIdempotencyKeys, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT username, key, from_account_id, to_account_id, amount, transfer_id, result, created_at, expires_at, scope FROM idempotency_keys
+WHERE username = $1 AND scope = $2 AND key = $3 LIMIT 1
+`
+
+type GetIdempotencyKeyParams struct {
+	Username string `json:"username"`
+	Scope    string `json:"scope"`
+	Key      string `json:"key"`
+}
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, getIdempotencyKey, arg.Username, arg.Scope, arg.Key)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Username,
+		&i.Key,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.TransferID,
+		&i.Result,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.Scope,
+	)
+	return i, err
+}
+
+const setIdempotencyKeyResult = `-- name: SetIdempotencyKeyResult :exec
+UPDATE idempotency_keys
+SET
+  transfer_id = $4,
+  result = $5
+WHERE username = $1 AND scope = $2 AND key = $3
+`
+
+type SetIdempotencyKeyResultParams struct {
+	Username   string          `json:"username"`
+	Scope      string          `json:"scope"`
+	Key        string          `json:"key"`
+	TransferID sql.NullInt64   `json:"transfer_id"`
+	Result     json.RawMessage `json:"result"`
+}
+
+func (q *Queries) SetIdempotencyKeyResult(ctx context.Context, arg SetIdempotencyKeyResultParams) error {
+	_, err := q.db.ExecContext(ctx, setIdempotencyKeyResult,
+		arg.Username,
+		arg.Scope,
+		arg.Key,
+		arg.TransferID,
+		arg.Result,
+	)
+	return err
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteExpiredIdempotencyKeys(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+
+	claim := func(expiresAt time.Time) IdempotencyKey {
+		key, err := testQueries.ClaimIdempotencyKey(context.Background(), ClaimIdempotencyKeyParams{
+			Username:      account1.Owner,
+			Scope:         IdempotencyScopeClient,
+			Key:           util.RandomString(16),
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        10,
+			ExpiresAt:     expiresAt,
+		})
+		require.NoError(t, err)
+		return key
+	}
+	expired := claim(time.Now().Add(-time.Minute))
+	live := claim(time.Now().Add(time.Hour))
+
+	// the other tests leave expired keys too, delete them all
+	for {
+		n, err := testQueries.DeleteExpiredIdempotencyKeys(context.Background(), 100)
+		require.NoError(t, err)
+		if n < 100 {
+			break
+		}
+	}
+
+	get := func(key IdempotencyKey) error {
+		_, err := testQueries.GetIdempotencyKey(context.Background(), GetIdempotencyKeyParams{
+			Username: key.Username,
+			Scope:    key.Scope,
+			Key:      key.Key,
+		})
+		return err
+	}
+	require.ErrorIs(t, get(expired), sql.ErrNoRows)
+	require.NoError(t, get(live))
+}
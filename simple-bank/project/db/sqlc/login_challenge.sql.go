@@ -0,0 +1,177 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: login_challenge.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const attemptLoginChallenge = `-- name: AttemptLoginChallenge :one
+UPDATE login_challenges
+SET attempts = attempts + 1
+WHERE
+  id = $1 AND
+  is_used = false AND
+  expires_at > now() AND
+  attempts < $2 AND
+  NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE
+      users.username = login_challenges.username AND
+      users.second_factor_locked_until > now()
+  )
+RETURNING id, username, hashed_email_code, email_codes_sent, attempts, is_used, expires_at, created_at
+`
+
+type AttemptLoginChallengeParams struct {
+	ID          uuid.UUID `json:"id"`
+	MaxAttempts int32     `json:"max_attempts"`
+}
+
+// AttemptLoginChallenge counts an attempt at a code. It returns no rows when the challenge was used,
+// expired, or had its attempts already, or when the second factor of its user is locked.
+func (q *Queries) AttemptLoginChallenge(ctx context.Context, arg AttemptLoginChallengeParams) (LoginChallenge, error) {
+	row := q.db.QueryRowContext(ctx, attemptLoginChallenge, arg.ID, arg.MaxAttempts)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedEmailCode,
+		&i.EmailCodesSent,
+		&i.Attempts,
+		&i.IsUsed,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const countLoginCodeSent = `-- name: CountLoginCodeSent :one
+UPDATE login_challenges
+SET email_codes_sent = email_codes_sent + 1
+WHERE
+  id = $1 AND
+  is_used = false AND
+  expires_at > now() AND
+  email_codes_sent < $2
+RETURNING id, username, hashed_email_code, email_codes_sent, attempts, is_used, expires_at, created_at
+`
+
+type CountLoginCodeSentParams struct {
+	ID      uuid.UUID `json:"id"`
+	MaxSent int32     `json:"max_sent"`
+}
+
+// CountLoginCodeSent counts an email code sent for the challenge. It returns no rows when the challenge
+// was used, expired, or had its codes sent already.
+func (q *Queries) CountLoginCodeSent(ctx context.Context, arg CountLoginCodeSentParams) (LoginChallenge, error) {
+	row := q.db.QueryRowContext(ctx, countLoginCodeSent, arg.ID, arg.MaxSent)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedEmailCode,
+		&i.EmailCodesSent,
+		&i.Attempts,
+		&i.IsUsed,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createLoginChallenge = `-- name: CreateLoginChallenge :one
+INSERT INTO login_challenges (
+  id,
+  username,
+  expires_at
+) VALUES (
+  $1, $2, $3
+) RETURNING id, username, hashed_email_code, email_codes_sent, attempts, is_used, expires_at, created_at
+`
+
+type CreateLoginChallengeParams struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateLoginChallenge(ctx context.Context, arg CreateLoginChallengeParams) (LoginChallenge, error) {
+	row := q.db.QueryRowContext(ctx, createLoginChallenge, arg.ID, arg.Username, arg.ExpiresAt)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedEmailCode,
+		&i.EmailCodesSent,
+		&i.Attempts,
+		&i.IsUsed,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const setLoginChallengeEmailCode = `-- name: SetLoginChallengeEmailCode :one
+UPDATE login_challenges
+SET hashed_email_code = $2
+WHERE
+  id = $1 AND
+  is_used = false AND
+  expires_at > now()
+RETURNING id, username, hashed_email_code, email_codes_sent, attempts, is_used, expires_at, created_at
+`
+
+type SetLoginChallengeEmailCodeParams struct {
+	ID              uuid.UUID `json:"id"`
+	HashedEmailCode string    `json:"hashed_email_code"`
+}
+
+// SetLoginChallengeEmailCode returns no rows when the challenge was used or expired.
+func (q *Queries) SetLoginChallengeEmailCode(ctx context.Context, arg SetLoginChallengeEmailCodeParams) (LoginChallenge, error) {
+	row := q.db.QueryRowContext(ctx, setLoginChallengeEmailCode, arg.ID, arg.HashedEmailCode)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedEmailCode,
+		&i.EmailCodesSent,
+		&i.Attempts,
+		&i.IsUsed,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const useLoginChallenge = `-- name: UseLoginChallenge :one
+UPDATE login_challenges
+SET is_used = true
+WHERE
+  id = $1 AND
+  is_used = false
+RETURNING id, username, hashed_email_code, email_codes_sent, attempts, is_used, expires_at, created_at
+`
+
+// UseLoginChallenge returns no rows when the challenge was used already.
+func (q *Queries) UseLoginChallenge(ctx context.Context, id uuid.UUID) (LoginChallenge, error) {
+	row := q.db.QueryRowContext(ctx, useLoginChallenge, id)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedEmailCode,
+		&i.EmailCodesSent,
+		&i.Attempts,
+		&i.IsUsed,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -5,16 +5,28 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Account struct {
-	ID        int64     `json:"id"`
-	Owner     string    `json:"owner"`
-	Balance   int64     `json:"balance"`
-	Currency  string    `json:"currency"`
+	ID                 int64     `json:"id"`
+	Owner              string    `json:"owner"`
+	Balance            int64     `json:"balance"`
+	Currency           string    `json:"currency"`
+	CreatedAt          time.Time `json:"created_at"`
+	TransferLimit      int64     `json:"transfer_limit"`
+	DailyTransferLimit int64     `json:"daily_transfer_limit"`
+	DailyTransferred   int64     `json:"daily_transferred"`
+	DailyTransferredOn time.Time `json:"daily_transferred_on"`
+}
+
+type Currency struct {
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -22,7 +34,90 @@ type Entry struct {
 	ID        int64 `json:"id"`
 	AccountID int64 `json:"account_id"`
 	// can be negative or positive
-	Amount    int64     `json:"amount"`
+	Amount     int64         `json:"amount"`
+	CreatedAt  time.Time     `json:"created_at"`
+	TransferID sql.NullInt64 `json:"transfer_id"`
+}
+
+type IdempotencyKey struct {
+	Username      string        `json:"username"`
+	Key           string        `json:"key"`
+	FromAccountID int64         `json:"from_account_id"`
+	ToAccountID   int64         `json:"to_account_id"`
+	Amount        int64         `json:"amount"`
+	TransferID    sql.NullInt64 `json:"transfer_id"`
+	// the TransferTxResult returned for the key
+	Result    json.RawMessage `json:"result"`
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Scope     string          `json:"scope"`
+}
+
+type LedgerDiscrepancy struct {
+	ID    int64 `json:"id"`
+	RunID int64 `json:"run_id"`
+	// account_balance, transfer_entries or transfer_amounts
+	Kind       string        `json:"kind"`
+	AccountID  sql.NullInt64 `json:"account_id"`
+	TransferID sql.NullInt64 `json:"transfer_id"`
+	Expected   int64         `json:"expected"`
+	Actual     int64         `json:"actual"`
+	Details    string        `json:"details"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+type LoginChallenge struct {
+	ID              uuid.UUID `json:"id"`
+	Username        string    `json:"username"`
+	HashedEmailCode string    `json:"hashed_email_code"`
+	EmailCodesSent  int32     `json:"email_codes_sent"`
+	Attempts        int32     `json:"attempts"`
+	IsUsed          bool      `json:"is_used"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type ReconciliationRun struct {
+	ID               int64     `json:"id"`
+	AccountsChecked  int64     `json:"accounts_checked"`
+	TransfersChecked int64     `json:"transfers_checked"`
+	Discrepancies    int64     `json:"discrepancies"`
+	StartedAt        time.Time `json:"started_at"`
+	// null while the run is in progress, or when it failed
+	FinishedAt sql.NullTime `json:"finished_at"`
+}
+
+type RecoveryCode struct {
+	ID         int64        `json:"id"`
+	Username   string       `json:"username"`
+	HashedCode string       `json:"hashed_code"`
+	UsedAt     sql.NullTime `json:"used_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+type ScheduledTransfer struct {
+	ID            int64  `json:"id"`
+	Owner         string `json:"owner"`
+	FromAccountID int64  `json:"from_account_id"`
+	ToAccountID   int64  `json:"to_account_id"`
+	// must be positive
+	Amount int64 `json:"amount"`
+	// cron expression of a recurring transfer, empty for a one-off transfer
+	Schedule string `json:"schedule"`
+	// active, completed or cancelled
+	Status string `json:"status"`
+	// null once the transfer is completed or cancelled
+	NextRunAt sql.NullTime `json:"next_run_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type ScheduledTransferRun struct {
+	ID                  int64         `json:"id"`
+	ScheduledTransferID int64         `json:"scheduled_transfer_id"`
+	ScheduledFor        time.Time     `json:"scheduled_for"`
+	TransferID          sql.NullInt64 `json:"transfer_id"`
+	// empty when the transfer went through
+	Error     string    `json:"error"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -35,6 +130,8 @@ type Session struct {
 	IsBlocked    bool      `json:"is_blocked"`
 	ExpiresAt    time.Time `json:"expires_at"`
 	CreatedAt    time.Time `json:"created_at"`
+	FamilyID     uuid.UUID `json:"family_id"`
+	IsRotated    bool      `json:"is_rotated"`
 }
 
 type Transfer struct {
@@ -44,16 +141,25 @@ type Transfer struct {
 	// must be positive
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// must be positive
+	ToAmount     int64  `json:"to_amount"`
+	ExchangeRate string `json:"exchange_rate"`
 }
 
 type User struct {
-	Username          string    `json:"username"`
-	HashedPassword    string    `json:"hashed_password"`
-	FullName          string    `json:"full_name"`
-	Email             string    `json:"email"`
-	PasswordChangedAt time.Time `json:"password_changed_at"`
-	CreatedAt         time.Time `json:"created_at"`
-	IsEmailVerified   bool      `json:"is_email_verified"`
+	Username                   string    `json:"username"`
+	HashedPassword             string    `json:"hashed_password"`
+	FullName                   string    `json:"full_name"`
+	Email                      string    `json:"email"`
+	PasswordChangedAt          time.Time `json:"password_changed_at"`
+	CreatedAt                  time.Time `json:"created_at"`
+	IsEmailVerified            bool      `json:"is_email_verified"`
+	Role                       string    `json:"role"`
+	TotpSecret                 string    `json:"totp_secret"`
+	IsTotpEnabled              bool      `json:"is_totp_enabled"`
+	TotpLastStep               int64     `json:"totp_last_step"`
+	FailedSecondFactorAttempts int32     `json:"failed_second_factor_attempts"`
+	SecondFactorLockedUntil    time.Time `json:"second_factor_locked_until"`
 }
 
 type VerifyEmail struct {
@@ -12,25 +12,98 @@ import (
 
 type Querier interface {
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	// moves the transfer on to its next run, or completes it when there's none. It only updates the
+	// transfer if it's still waiting for the run it's advanced from.
+	AdvanceScheduledTransfer(ctx context.Context, arg AdvanceScheduledTransferParams) (ScheduledTransfer, error)
+	// AttemptLoginChallenge counts an attempt at a code. It returns no rows when the challenge was used,
+	// expired, or had its attempts already, or when the second factor of its user is locked.
+	AttemptLoginChallenge(ctx context.Context, arg AttemptLoginChallengeParams) (LoginChallenge, error)
+	BlockSessionFamily(ctx context.Context, familyID uuid.UUID) error
+	BlockUserSessions(ctx context.Context, username string) error
+	CancelScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error)
+	// ClaimIdempotencyKey adds the key, or takes over the key when it has expired. It returns no
+	// row when the key is in use. A concurrent claim of the same key waits for the transaction
+	// holding it to end.
+	ClaimIdempotencyKey(ctx context.Context, arg ClaimIdempotencyKeyParams) (IdempotencyKey, error)
+	// CountLoginCodeSent counts an email code sent for the challenge. It returns no rows when the challenge
+	// was used, expired, or had its codes sent already.
+	CountLoginCodeSent(ctx context.Context, arg CountLoginCodeSentParams) (LoginChallenge, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	CreateLedgerDiscrepancy(ctx context.Context, arg CreateLedgerDiscrepancyParams) (LedgerDiscrepancy, error)
+	CreateLoginChallenge(ctx context.Context, arg CreateLoginChallengeParams) (LoginChallenge, error)
+	CreateReconciliationRun(ctx context.Context) (ReconciliationRun, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (RecoveryCode, error)
+	CreateScheduledTransfer(ctx context.Context, arg CreateScheduledTransferParams) (ScheduledTransfer, error)
+	CreateScheduledTransferRun(ctx context.Context, arg CreateScheduledTransferRunParams) (ScheduledTransferRun, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
 	CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error)
+	// DebitAccount takes the amount out of the account, and adds it to the amount transferred
+	// out of the account today. The caller checks the balance and the limits on the result.
+	DebitAccount(ctx context.Context, arg DebitAccountParams) (Account, error)
 	DeleteAccount(ctx context.Context, id int64) error
+	// DeleteExpiredIdempotencyKeys deletes up to limit of the keys that expired, a claim of an
+	// expired key takes it over anyway. The expiry is checked again on the rows we delete, a key
+	// claimed while we waited for it isn't expired anymore.
+	DeleteExpiredIdempotencyKeys(ctx context.Context, limit int32) (int64, error)
+	DeleteRecoveryCodes(ctx context.Context, username string) error
+	// EnableTOTP returns no rows when the user has two-factor authentication enabled already.
+	EnableTOTP(ctx context.Context, arg EnableTOTPParams) (User, error)
+	FinishReconciliationRun(ctx context.Context, arg FinishReconciliationRunParams) (ReconciliationRun, error)
 	GetAccount(ctx context.Context, id int64) (Account, error)
+	// the balance now minus the entries made since, in a single statement so both come from the same snapshot
+	GetAccountBalanceAt(ctx context.Context, arg GetAccountBalanceAtParams) (int64, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	GetCurrency(ctx context.Context, code string) (Currency, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
+	GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error)
+	GetScheduledTransferRun(ctx context.Context, id int64) (ScheduledTransferRun, error)
 	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	// ListAccountBalanceChecks returns the balance of a page of accounts next to the sum of their entries,
+	// both from the same snapshot, so transfers made meanwhile don't show up as discrepancies.
+	ListAccountBalanceChecks(ctx context.Context, arg ListAccountBalanceChecksParams) ([]ListAccountBalanceChecksRow, error)
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	// ListActiveSessions returns the latest session of every family of the user that can still be refreshed.
+	ListActiveSessions(ctx context.Context, username string) ([]Session, error)
+	ListAllAccounts(ctx context.Context, arg ListAllAccountsParams) ([]Account, error)
+	ListCurrencies(ctx context.Context) ([]Currency, error)
+	ListDueScheduledTransfers(ctx context.Context, arg ListDueScheduledTransfersParams) ([]ScheduledTransfer, error)
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
+	ListEntriesBetween(ctx context.Context, arg ListEntriesBetweenParams) ([]Entry, error)
+	ListLedgerDiscrepancies(ctx context.Context, runID int64) ([]LedgerDiscrepancy, error)
+	ListScheduledTransferRuns(ctx context.Context, arg ListScheduledTransferRunsParams) ([]ScheduledTransferRun, error)
+	ListScheduledTransfers(ctx context.Context, arg ListScheduledTransfersParams) ([]ScheduledTransfer, error)
+	// ListTransferEntryChecks returns a page of transfers with the currencies of their accounts, the number
+	// of their entries, and the sum of the entries made to the from-account and the to-account.
+	ListTransferEntryChecks(ctx context.Context, arg ListTransferEntryChecksParams) ([]ListTransferEntryChecksRow, error)
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
+	ListUnusedRecoveryCodes(ctx context.Context, username string) ([]RecoveryCode, error)
+	// RecordFailedSecondFactor counts an incorrect code of the user's second factor. The max_attempts-th
+	// one in a row locks the second factor until locked_until, and starts the count over.
+	RecordFailedSecondFactor(ctx context.Context, arg RecordFailedSecondFactorParams) (User, error)
+	ResetFailedSecondFactor(ctx context.Context, username string) error
+	// RotateSession returns no rows when the session was rotated or blocked already.
+	RotateSession(ctx context.Context, id uuid.UUID) (Session, error)
+	SetIdempotencyKeyResult(ctx context.Context, arg SetIdempotencyKeyResultParams) error
+	// SetLoginChallengeEmailCode returns no rows when the challenge was used or expired.
+	SetLoginChallengeEmailCode(ctx context.Context, arg SetLoginChallengeEmailCodeParams) (LoginChallenge, error)
+	// SetTOTPSecret returns no rows when the user has two-factor authentication enabled already.
+	SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) (User, error)
 	UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error)
+	UpdateAccountLimits(ctx context.Context, arg UpdateAccountLimitsParams) (Account, error)
+	// UpdateTOTPLastStep returns no rows when a code of the step, or a later one, was accepted already.
+	UpdateTOTPLastStep(ctx context.Context, arg UpdateTOTPLastStepParams) (User, error)
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
 	UpdateVerifyEmail(ctx context.Context, arg UpdateVerifyEmailParams) (VerifyEmail, error)
+	// UseLoginChallenge returns no rows when the challenge was used already.
+	UseLoginChallenge(ctx context.Context, id uuid.UUID) (LoginChallenge, error)
+	// UseRecoveryCode returns no rows when the code was used already.
+	UseRecoveryCode(ctx context.Context, id int64) (RecoveryCode, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,277 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.15.0
+// source: reconciliation.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+const createLedgerDiscrepancy = `-- name: CreateLedgerDiscrepancy :one
+INSERT INTO ledger_discrepancies (
+  run_id,
+  kind,
+  account_id,
+  transfer_id,
+  expected,
+  actual,
+  details
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+) RETURNING id, run_id, kind, account_id, transfer_id, expected, actual, details, created_at
+`
+
+type CreateLedgerDiscrepancyParams struct {
+	RunID      int64         `json:"run_id"`
+	Kind       string        `json:"kind"`
+	AccountID  sql.NullInt64 `json:"account_id"`
+	TransferID sql.NullInt64 `json:"transfer_id"`
+	Expected   int64         `json:"expected"`
+	Actual     int64         `json:"actual"`
+	Details    string        `json:"details"`
+}
+
+func (q *Queries) CreateLedgerDiscrepancy(ctx context.Context, arg CreateLedgerDiscrepancyParams) (LedgerDiscrepancy, error) {
+	row := q.db.QueryRowContext(ctx, createLedgerDiscrepancy,
+		arg.RunID,
+		arg.Kind,
+		arg.AccountID,
+		arg.TransferID,
+		arg.Expected,
+		arg.Actual,
+		arg.Details,
+	)
+	var i LedgerDiscrepancy
+	err := row.Scan(
+		&i.ID,
+		&i.RunID,
+		&i.Kind,
+		&i.AccountID,
+		&i.TransferID,
+		&i.Expected,
+		&i.Actual,
+		&i.Details,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createReconciliationRun = `-- name: CreateReconciliationRun :one
+INSERT INTO reconciliation_runs DEFAULT VALUES
+RETURNING id, accounts_checked, transfers_checked, discrepancies, started_at, finished_at
+`
+
+func (q *Queries) CreateReconciliationRun(ctx context.Context) (ReconciliationRun, error) {
+	row := q.db.QueryRowContext(ctx, createReconciliationRun)
+	var i ReconciliationRun
+	err := row.Scan(
+		&i.ID,
+		&i.AccountsChecked,
+		&i.TransfersChecked,
+		&i.Discrepancies,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const finishReconciliationRun = `-- name: FinishReconciliationRun :one
+UPDATE reconciliation_runs
+SET
+  accounts_checked = $1,
+  transfers_checked = $2,
+  discrepancies = $3,
+  finished_at = now()
+WHERE id = $4
+RETURNING id, accounts_checked, transfers_checked, discrepancies, started_at, finished_at
+`
+
+type FinishReconciliationRunParams struct {
+	AccountsChecked  int64 `json:"accounts_checked"`
+	TransfersChecked int64 `json:"transfers_checked"`
+	Discrepancies    int64 `json:"discrepancies"`
+	ID               int64 `json:"id"`
+}
+
+func (q *Queries) FinishReconciliationRun(ctx context.Context, arg FinishReconciliationRunParams) (ReconciliationRun, error) {
+	row := q.db.QueryRowContext(ctx, finishReconciliationRun,
+		arg.AccountsChecked,
+		arg.TransfersChecked,
+		arg.Discrepancies,
+		arg.ID,
+	)
+	var i ReconciliationRun
+	err := row.Scan(
+		&i.ID,
+		&i.AccountsChecked,
+		&i.TransfersChecked,
+		&i.Discrepancies,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const listAccountBalanceChecks = `-- name: ListAccountBalanceChecks :many
+SELECT
+  a.id,
+  a.balance,
+  COALESCE(SUM(e.amount), 0)::bigint AS entries_total
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+WHERE a.id > $1
+GROUP BY a.id
+ORDER BY a.id
+LIMIT $2
+`
+
+type ListAccountBalanceChecksParams struct {
+	AfterID int64 `json:"after_id"`
+	Limit   int32 `json:"limit"`
+}
+
+type ListAccountBalanceChecksRow struct {
+	ID           int64 `json:"id"`
+	Balance      int64 `json:"balance"`
+	EntriesTotal int64 `json:"entries_total"`
+}
+
+// ListAccountBalanceChecks returns the balance of a page of accounts next to the sum of their entries,
+// both from the same snapshot, so transfers made meanwhile don't show up as discrepancies.
+func (q *Queries) ListAccountBalanceChecks(ctx context.Context, arg ListAccountBalanceChecksParams) ([]ListAccountBalanceChecksRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountBalanceChecks, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAccountBalanceChecksRow{}
+	for rows.Next() {
+		var i ListAccountBalanceChecksRow
+		if err := rows.Scan(&i.ID, &i.Balance, &i.EntriesTotal); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLedgerDiscrepancies = `-- name: ListLedgerDiscrepancies :many
+SELECT id, run_id, kind, account_id, transfer_id, expected, actual, details, created_at FROM ledger_discrepancies
+WHERE run_id = $1
+ORDER BY id
+`
+
+func (q *Queries) ListLedgerDiscrepancies(ctx context.Context, runID int64) ([]LedgerDiscrepancy, error) {
+	rows, err := q.db.QueryContext(ctx, listLedgerDiscrepancies, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []LedgerDiscrepancy{}
+	for rows.Next() {
+		var i LedgerDiscrepancy
+		if err := rows.Scan(
+			&i.ID,
+			&i.RunID,
+			&i.Kind,
+			&i.AccountID,
+			&i.TransferID,
+			&i.Expected,
+			&i.Actual,
+			&i.Details,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransferEntryChecks = `-- name: ListTransferEntryChecks :many
+SELECT
+  t.id,
+  t.amount,
+  t.to_amount,
+  t.exchange_rate,
+  fa.currency AS from_currency,
+  ta.currency AS to_currency,
+  COUNT(e.id)::bigint AS entry_count,
+  COALESCE(SUM(e.amount) FILTER (WHERE e.account_id = t.from_account_id), 0)::bigint AS from_total,
+  COALESCE(SUM(e.amount) FILTER (WHERE e.account_id = t.to_account_id), 0)::bigint AS to_total
+FROM transfers t
+JOIN accounts fa ON fa.id = t.from_account_id
+JOIN accounts ta ON ta.id = t.to_account_id
+LEFT JOIN entries e ON e.transfer_id = t.id
+WHERE t.id > $1
+GROUP BY t.id, fa.currency, ta.currency
+ORDER BY t.id
+LIMIT $2
+`
+
+type ListTransferEntryChecksParams struct {
+	AfterID int64 `json:"after_id"`
+	Limit   int32 `json:"limit"`
+}
+
+type ListTransferEntryChecksRow struct {
+	ID           int64  `json:"id"`
+	Amount       int64  `json:"amount"`
+	ToAmount     int64  `json:"to_amount"`
+	ExchangeRate string `json:"exchange_rate"`
+	FromCurrency string `json:"from_currency"`
+	ToCurrency   string `json:"to_currency"`
+	EntryCount   int64  `json:"entry_count"`
+	FromTotal    int64  `json:"from_total"`
+	ToTotal      int64  `json:"to_total"`
+}
+
+// ListTransferEntryChecks returns a page of transfers with the currencies of their accounts, the number
+// of their entries, and the sum of the entries made to the from-account and the to-account.
+func (q *Queries) ListTransferEntryChecks(ctx context.Context, arg ListTransferEntryChecksParams) ([]ListTransferEntryChecksRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTransferEntryChecks, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTransferEntryChecksRow{}
+	for rows.Next() {
+		var i ListTransferEntryChecksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Amount,
+			&i.ToAmount,
+			&i.ExchangeRate,
+			&i.FromCurrency,
+			&i.ToCurrency,
+			&i.EntryCount,
+			&i.FromTotal,
+			&i.ToTotal,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListTransferEntryChecks(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+	amount := int64(10)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        amount,
+	})
+	require.NoError(t, err)
+
+	checks, err := testQueries.ListTransferEntryChecks(context.Background(), ListTransferEntryChecksParams{
+		AfterID: result.Transfer.ID - 1,
+		Limit:   1,
+	})
+	require.NoError(t, err)
+	require.Len(t, checks, 1)
+
+	check := checks[0]
+	require.Equal(t, result.Transfer.ID, check.ID)
+	require.Equal(t, account1.Currency, check.FromCurrency)
+	require.Equal(t, account2.Currency, check.ToCurrency)
+	require.Equal(t, int64(2), check.EntryCount)
+	require.Equal(t, -amount, check.FromTotal)
+	require.Equal(t, amount, check.ToTotal)
+}
+
+func TestListAccountBalanceChecks(t *testing.T) {
+	account := createRandomAccount(t)
+	entry1 := createRandomEntry(t, account)
+	entry2 := createRandomEntry(t, account)
+
+	checks, err := testQueries.ListAccountBalanceChecks(context.Background(), ListAccountBalanceChecksParams{
+		AfterID: account.ID - 1,
+		Limit:   1,
+	})
+	require.NoError(t, err)
+	require.Len(t, checks, 1)
+
+	require.Equal(t, account.ID, checks[0].ID)
+	require.Equal(t, account.Balance, checks[0].Balance)
+	require.Equal(t, entry1.Amount+entry2.Amount, checks[0].EntriesTotal)
+}
+
+func TestRecordLedgerDiscrepancies(t *testing.T) {
+	account := createRandomAccount(t)
+
+	run, err := testQueries.CreateReconciliationRun(context.Background())
+	require.NoError(t, err)
+	require.False(t, run.FinishedAt.Valid)
+
+	discrepancy, err := testQueries.CreateLedgerDiscrepancy(context.Background(), CreateLedgerDiscrepancyParams{
+		RunID:     run.ID,
+		Kind:      "account_balance",
+		AccountID: sql.NullInt64{Int64: account.ID, Valid: true},
+		Expected:  0,
+		Actual:    account.Balance,
+	})
+	require.NoError(t, err)
+
+	discrepancies, err := testQueries.ListLedgerDiscrepancies(context.Background(), run.ID)
+	require.NoError(t, err)
+	require.Len(t, discrepancies, 1)
+	require.Equal(t, discrepancy.ID, discrepancies[0].ID)
+
+	run, err = testQueries.FinishReconciliationRun(context.Background(), FinishReconciliationRunParams{
+		ID:              run.ID,
+		AccountsChecked: 1,
+		Discrepancies:   1,
+	})
+	require.NoError(t, err)
+	require.True(t, run.FinishedAt.Valid)
+	require.Equal(t, int64(1), run.Discrepancies)
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomScheduledTransfer(t *testing.T, schedule string, nextRunAt time.Time) ScheduledTransfer {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+
+	arg := CreateScheduledTransferParams{
+		Owner:         account1.Owner,
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        util.RandomMoney(),
+		Schedule:      schedule,
+		NextRunAt:     sql.NullTime{Time: nextRunAt, Valid: true},
+	}
+
+	scheduledTransfer, err := testQueries.CreateScheduledTransfer(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, scheduledTransfer)
+
+	require.Equal(t, arg.Owner, scheduledTransfer.Owner)
+	require.Equal(t, arg.FromAccountID, scheduledTransfer.FromAccountID)
+	require.Equal(t, arg.ToAccountID, scheduledTransfer.ToAccountID)
+	require.Equal(t, arg.Amount, scheduledTransfer.Amount)
+	require.Equal(t, arg.Schedule, scheduledTransfer.Schedule)
+	require.Equal(t, ScheduledTransferActive, scheduledTransfer.Status)
+	require.WithinDuration(t, nextRunAt, scheduledTransfer.NextRunAt.Time, time.Second)
+
+	require.NotZero(t, scheduledTransfer.ID)
+	require.NotZero(t, scheduledTransfer.CreatedAt)
+
+	return scheduledTransfer
+}
+
+func TestCreateScheduledTransfer(t *testing.T) {
+	createRandomScheduledTransfer(t, "", time.Now().Add(time.Hour))
+}
+
+func TestCancelScheduledTransfer(t *testing.T) {
+	scheduledTransfer := createRandomScheduledTransfer(t, "@daily", time.Now().Add(time.Hour))
+
+	cancelled, err := testQueries.CancelScheduledTransfer(context.Background(), scheduledTransfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferCancelled, cancelled.Status)
+	require.False(t, cancelled.NextRunAt.Valid)
+
+	// only active transfers can be cancelled
+	_, err = testQueries.CancelScheduledTransfer(context.Background(), scheduledTransfer.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestListDueScheduledTransfers(t *testing.T) {
+	due := createRandomScheduledTransfer(t, "", time.Now().Add(-time.Minute))
+	notDue := createRandomScheduledTransfer(t, "", time.Now().Add(time.Hour))
+
+	scheduledTransfers, err := testQueries.ListDueScheduledTransfers(context.Background(), ListDueScheduledTransfersParams{
+		Now:   time.Now(),
+		Limit: 1000,
+	})
+	require.NoError(t, err)
+
+	ids := make(map[int64]bool)
+	for _, scheduledTransfer := range scheduledTransfers {
+		require.Equal(t, ScheduledTransferActive, scheduledTransfer.Status)
+		ids[scheduledTransfer.ID] = true
+	}
+	require.True(t, ids[due.ID])
+	require.False(t, ids[notDue.ID])
+}
+
+func TestRecordScheduledTransferRunTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	scheduledTransfer := createRandomScheduledTransfer(t, "@daily", time.Now().Add(-time.Minute))
+	scheduledFor := scheduledTransfer.NextRunAt.Time
+	nextRunAt := scheduledFor.Add(24 * time.Hour)
+
+	result, err := store.RecordScheduledTransferRunTx(context.Background(), RecordScheduledTransferRunTxParams{
+		ScheduledTransferID: scheduledTransfer.ID,
+		ScheduledFor:        scheduledFor,
+		Error:               ErrInsufficientFunds.Error(),
+		NextRunAt:           sql.NullTime{Time: nextRunAt, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferActive, result.ScheduledTransfer.Status)
+	require.WithinDuration(t, nextRunAt, result.ScheduledTransfer.NextRunAt.Time, time.Second)
+	require.Equal(t, scheduledTransfer.ID, result.ScheduledTransferRun.ScheduledTransferID)
+	require.Equal(t, ErrInsufficientFunds.Error(), result.ScheduledTransferRun.Error)
+	require.False(t, result.ScheduledTransferRun.TransferID.Valid)
+
+	// the run is recorded once
+	_, err = store.RecordScheduledTransferRunTx(context.Background(), RecordScheduledTransferRunTxParams{
+		ScheduledTransferID: scheduledTransfer.ID,
+		ScheduledFor:        scheduledFor,
+		NextRunAt:           sql.NullTime{Time: nextRunAt, Valid: true},
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// without a next run, the transfer is completed
+	result, err = store.RecordScheduledTransferRunTx(context.Background(), RecordScheduledTransferRunTxParams{
+		ScheduledTransferID: scheduledTransfer.ID,
+		ScheduledFor:        result.ScheduledTransfer.NextRunAt.Time,
+	})
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferCompleted, result.ScheduledTransfer.Status)
+	require.False(t, result.ScheduledTransfer.NextRunAt.Valid)
+
+	runs, err := testQueries.ListScheduledTransferRuns(context.Background(), ListScheduledTransferRunsParams{
+		ScheduledTransferID: scheduledTransfer.ID,
+		Limit:               5,
+	})
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func randomSessionParams(username string) CreateSessionParams {
+	id := uuid.New()
+	return CreateSessionParams{
+		ID:           id,
+		FamilyID:     id,
+		Username:     username,
+		RefreshToken: util.RandomString(32),
+		UserAgent:    util.RandomString(10),
+		ClientIp:     "127.0.0.1",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+}
+
+func createRandomSession(t *testing.T, username string) Session {
+	arg := randomSessionParams(username)
+
+	session, err := testQueries.CreateSession(context.Background(), arg)
+	require.NoError(t, err)
+
+	require.Equal(t, arg.ID, session.ID)
+	require.Equal(t, arg.FamilyID, session.FamilyID)
+	require.Equal(t, arg.Username, session.Username)
+	require.False(t, session.IsBlocked)
+	require.False(t, session.IsRotated)
+
+	return session
+}
+
+func TestRotateSessionTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	user := createRandomUser(t)
+	session := createRandomSession(t, user.Username)
+
+	result, err := store.RotateSessionTx(context.Background(), RotateSessionTxParams{
+		ID:         session.ID,
+		NewSession: randomSessionParams(user.Username),
+	})
+	require.NoError(t, err)
+	require.True(t, result.RotatedSession.IsRotated)
+	require.Equal(t, session.FamilyID, result.Session.FamilyID)
+	require.NotEqual(t, session.ID, result.Session.ID)
+
+	sessions, err := testQueries.ListActiveSessions(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, result.Session.ID, sessions[0].ID)
+
+	// rotating the old session again means its refresh token was reused
+	_, err = store.RotateSessionTx(context.Background(), RotateSessionTxParams{
+		ID:         session.ID,
+		NewSession: randomSessionParams(user.Username),
+	})
+	require.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	latest, err := testQueries.GetSession(context.Background(), result.Session.ID)
+	require.NoError(t, err)
+	require.True(t, latest.IsBlocked)
+
+	sessions, err = testQueries.ListActiveSessions(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+}
+
+func TestBlockUserSessions(t *testing.T) {
+	user := createRandomUser(t)
+	createRandomSession(t, user.Username)
+	createRandomSession(t, user.Username)
+
+	sessions, err := testQueries.ListActiveSessions(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	err = testQueries.BlockUserSessions(context.Background(), user.Username)
+	require.NoError(t, err)
+
+	sessions, err = testQueries.ListActiveSessions(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+}
@@ -2,8 +2,12 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"testing"
+	"time"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
 
 	"github.com/stretchr/testify/require"
 )
@@ -11,13 +15,13 @@ import (
 func TestTransferTx(t *testing.T) {
 	store := NewStore(testDB)
 
-	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
-	fmt.Println(">> before:", account1.Balance, account2.Balance)
-
 	n := 5
 	amount := int64(10)
 
+	account1 := createFundedAccount(t, int64(n)*amount)
+	account2 := createRandomAccount(t)
+	fmt.Println(">> before:", account1.Balance, account2.Balance)
+
 	errs := make(chan error)
 	results := make(chan TransferTxResult)
 
@@ -62,6 +66,7 @@ func TestTransferTx(t *testing.T) {
 		require.NotEmpty(t, fromEntry)
 		require.Equal(t, account1.ID, fromEntry.AccountID)
 		require.Equal(t, -amount, fromEntry.Amount)
+		require.Equal(t, transfer.ID, fromEntry.TransferID.Int64)
 		require.NotZero(t, fromEntry.ID)
 		require.NotZero(t, fromEntry.CreatedAt)
 
@@ -72,6 +77,7 @@ func TestTransferTx(t *testing.T) {
 		require.NotEmpty(t, toEntry)
 		require.Equal(t, account2.ID, toEntry.AccountID)
 		require.Equal(t, amount, toEntry.Amount)
+		require.Equal(t, transfer.ID, toEntry.TransferID.Int64)
 		require.NotZero(t, toEntry.ID)
 		require.NotZero(t, toEntry.CreatedAt)
 
@@ -120,12 +126,13 @@ func TestTransferTx(t *testing.T) {
 func TestTransferTxDeadlock(t *testing.T) {
 	store := NewStore(testDB)
 
-	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
-	fmt.Println(">> before:", account1.Balance, account2.Balance)
-
 	n := 10
 	amount := int64(10)
+
+	// each account must cover its n/2 transfers, whatever order they run in
+	account1 := createFundedAccount(t, int64(n/2)*amount)
+	account2 := createFundedAccount(t, int64(n/2)*amount)
+	fmt.Println(">> before:", account1.Balance, account2.Balance)
 	errs := make(chan error)
 
 	for i := 0; i < n; i++ {
@@ -164,3 +171,297 @@ func TestTransferTxDeadlock(t *testing.T) {
 	require.Equal(t, account1.Balance, updatedAccount1.Balance)
 	require.Equal(t, account2.Balance, updatedAccount2.Balance)
 }
+
+func TestTransferTxInsufficientFunds(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        account1.Balance + 1,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// nothing of the transfer is left
+	requireBalance(t, account1.ID, account1.Balance)
+	requireBalance(t, account2.ID, account2.Balance)
+
+	transfers, err := store.ListTransfers(context.Background(), ListTransfersParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account1.ID,
+		Limit:         5,
+	})
+	require.NoError(t, err)
+	require.Empty(t, transfers)
+}
+
+func TestTransferTxExchangeRate(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 100)
+	account2 := createRandomAccount(t)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+		ToAmount:      92,
+		ExchangeRate:  "0.92000000",
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, int64(100), result.Transfer.Amount)
+	require.Equal(t, int64(92), result.Transfer.ToAmount)
+	require.Equal(t, "0.92000000", result.Transfer.ExchangeRate)
+	require.Equal(t, int64(-100), result.FromEntry.Amount)
+	require.Equal(t, int64(92), result.ToEntry.Amount)
+
+	requireBalance(t, account1.ID, account1.Balance-100)
+	requireBalance(t, account2.ID, account2.Balance+92)
+}
+
+// The idea is to have more concurrent transfers than the balance can cover, and check that only
+// the ones it covers go through.
+func TestTransferTxConcurrentOverdraft(t *testing.T) {
+	store := NewStore(testDB)
+
+	n := 10
+	covered := 4
+	amount := int64(10)
+
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+	account1 = setBalance(t, account1, int64(covered)*amount)
+
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.TransferTx(context.Background(), TransferTxParams{
+				FromAccountID: account1.ID,
+				ToAccountID:   account2.ID,
+				Amount:        amount,
+			})
+			errs <- err
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.ErrorIs(t, err, ErrInsufficientFunds)
+	}
+
+	require.Equal(t, covered, succeeded)
+	requireBalance(t, account1.ID, 0)
+	requireBalance(t, account2.ID, account2.Balance+int64(covered)*amount)
+}
+
+func TestTransferTxLimits(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 1000)
+	account2 := createRandomAccount(t)
+
+	_, err := store.UpdateAccountLimits(context.Background(), UpdateAccountLimitsParams{
+		ID:                 account1.ID,
+		TransferLimit:      sql.NullInt64{Int64: 50, Valid: true},
+		DailyTransferLimit: sql.NullInt64{Int64: 100, Valid: true},
+	})
+	require.NoError(t, err)
+
+	transfer := func(amount int64) error {
+		_, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		})
+		return err
+	}
+
+	require.ErrorIs(t, transfer(51), ErrTransferLimitExceeded)
+	require.NoError(t, transfer(50))
+	require.NoError(t, transfer(50))
+	require.ErrorIs(t, transfer(1), ErrDailyLimitExceeded)
+
+	account, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), account.DailyTransferred)
+	require.Equal(t, account1.Balance-100, account.Balance)
+}
+
+func TestTransferTxIdempotencyKey(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 100)
+	account2 := createRandomAccount(t)
+
+	arg := TransferTxParams{
+		FromAccountID:          account1.ID,
+		ToAccountID:            account2.ID,
+		Amount:                 10,
+		Username:               account1.Owner,
+		IdempotencyKey:         util.RandomString(16),
+		IdempotencyKeyDuration: time.Minute,
+	}
+
+	// run the same transfer concurrently, as a client retrying it would
+	n := 5
+	errs := make(chan error)
+	results := make(chan TransferTxResult)
+	for i := 0; i < n; i++ {
+		go func() {
+			result, err := store.TransferTx(context.Background(), arg)
+			errs <- err
+			results <- result
+		}()
+	}
+
+	var first TransferTxResult
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+		result := <-results
+		if i == 0 {
+			first = result
+		}
+		require.Equal(t, first.Transfer.ID, result.Transfer.ID)
+		require.Equal(t, first.FromAccount.Balance, result.FromAccount.Balance)
+	}
+
+	// the money moved once
+	requireBalance(t, account1.ID, account1.Balance-arg.Amount)
+	requireBalance(t, account2.ID, account2.Balance+arg.Amount)
+
+	conflicting := arg
+	conflicting.Amount++
+	_, err := store.TransferTx(context.Background(), conflicting)
+	require.ErrorIs(t, err, ErrIdempotencyKeyConflict)
+
+	// another user can use the same key
+	other := createFundedAccount(t, 100)
+	otherArg := arg
+	otherArg.FromAccountID = other.ID
+	otherArg.Username = other.Owner
+	result, err := store.TransferTx(context.Background(), otherArg)
+	require.NoError(t, err)
+	require.NotEqual(t, first.Transfer.ID, result.Transfer.ID)
+
+	// the same key in another scope is another key
+	scopedArg := conflicting
+	scopedArg.IdempotencyScope = IdempotencyScopeScheduledTransfer
+	result, err = store.TransferTx(context.Background(), scopedArg)
+	require.NoError(t, err)
+	require.NotEqual(t, first.Transfer.ID, result.Transfer.ID)
+}
+
+func TestTransferTxExpiredIdempotencyKey(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 100)
+	account2 := createRandomAccount(t)
+
+	arg := TransferTxParams{
+		FromAccountID:          account1.ID,
+		ToAccountID:            account2.ID,
+		Amount:                 10,
+		Username:               account1.Owner,
+		IdempotencyKey:         util.RandomString(16),
+		IdempotencyKeyDuration: -time.Minute,
+	}
+
+	result1, err := store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+
+	result2, err := store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEqual(t, result1.Transfer.ID, result2.Transfer.ID)
+
+	requireBalance(t, account1.ID, account1.Balance-2*arg.Amount)
+}
+
+// createFundedAccount creates a random account with at least the given balance.
+func createFundedAccount(t *testing.T, balance int64) Account {
+	account := createRandomAccount(t)
+	if account.Balance < balance {
+		account = setBalance(t, account, balance)
+	}
+	return account
+}
+
+func setBalance(t *testing.T, account Account, balance int64) Account {
+	account, err := testQueries.UpdateAccount(context.Background(), UpdateAccountParams{
+		ID:      account.ID,
+		Balance: balance,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func requireBalance(t *testing.T, accountID int64, balance int64) {
+	account, err := testQueries.GetAccount(context.Background(), accountID)
+	require.NoError(t, err)
+	require.Equal(t, balance, account.Balance)
+}
+
+func TestAccountStatementTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t, 100)
+	account2 := createRandomAccount(t)
+
+	// a transfer before the statement starts counts in the opening balance only
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+	fromTime := time.Now()
+
+	amounts := []int64{20, 30}
+	for _, amount := range amounts {
+		_, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		})
+		require.NoError(t, err)
+	}
+
+	result, err := store.AccountStatementTx(context.Background(), AccountStatementTxParams{
+		AccountID: account1.ID,
+		FromTime:  fromTime,
+		ToTime:    time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, account1.ID, result.AccountID)
+	require.Equal(t, account1.Balance-10, result.OpeningBalance)
+	require.Equal(t, account1.Balance-60, result.ClosingBalance)
+	require.Len(t, result.Entries, len(amounts))
+
+	balance := result.OpeningBalance
+	for i, entry := range result.Entries {
+		require.Equal(t, -amounts[i], entry.Amount)
+		balance -= amounts[i]
+		require.Equal(t, balance, entry.Balance)
+	}
+
+	// nothing happened before the account was opened
+	result, err = store.AccountStatementTx(context.Background(), AccountStatementTxParams{
+		AccountID: account1.ID,
+		FromTime:  account1.CreatedAt.Add(-time.Hour),
+		ToTime:    account1.CreatedAt.Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	require.Empty(t, result.Entries)
+	require.Equal(t, result.OpeningBalance, result.ClosingBalance)
+}
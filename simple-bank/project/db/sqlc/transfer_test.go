@@ -15,6 +15,8 @@ func createRandomTransfer(t *testing.T, account1, account2 Account) Transfer {
 		ToAccountID:   account2.ID,
 		Amount:        util.RandomMoney(),
 	}
+	arg.ToAmount = arg.Amount * 2
+	arg.ExchangeRate = "2.00000000"
 
 	transfer, err := testQueries.CreateTransfer(context.Background(), arg)
 	require.NoError(t, err)
@@ -23,6 +25,8 @@ func createRandomTransfer(t *testing.T, account1, account2 Account) Transfer {
 	require.Equal(t, arg.FromAccountID, transfer.FromAccountID)
 	require.Equal(t, arg.ToAccountID, transfer.ToAccountID)
 	require.Equal(t, arg.Amount, transfer.Amount)
+	require.Equal(t, arg.ToAmount, transfer.ToAmount)
+	require.Equal(t, arg.ExchangeRate, transfer.ExchangeRate)
 
 	require.NotZero(t, transfer.ID)
 	require.NotZero(t, transfer.CreatedAt)
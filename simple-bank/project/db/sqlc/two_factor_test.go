@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivateTOTPTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	updatedUser, err := testQueries.SetTOTPSecret(context.Background(), SetTOTPSecretParams{
+		Username:   user.Username,
+		TotpSecret: util.RandomString(32),
+	})
+	require.NoError(t, err)
+	require.False(t, updatedUser.IsTotpEnabled)
+
+	arg := ActivateTOTPTxParams{
+		Username:            user.Username,
+		Step:                100,
+		HashedRecoveryCodes: []string{util.RandomString(60), util.RandomString(60)},
+	}
+	result, err := store.ActivateTOTPTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, result.User.IsTotpEnabled)
+	require.Equal(t, updatedUser.TotpSecret, result.User.TotpSecret)
+	require.Equal(t, int64(100), result.User.TotpLastStep)
+	require.Len(t, result.RecoveryCodes, 2)
+
+	// the secret can't be replaced, nor two-factor authentication activated again
+	_, err = testQueries.SetTOTPSecret(context.Background(), SetTOTPSecretParams{
+		Username:   user.Username,
+		TotpSecret: util.RandomString(32),
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = store.ActivateTOTPTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrTOTPAlreadyEnabled)
+
+	// a code of a step accepted already is refused
+	_, err = testQueries.UpdateTOTPLastStep(context.Background(), UpdateTOTPLastStepParams{
+		Username:     user.Username,
+		TotpLastStep: 100,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = testQueries.UpdateTOTPLastStep(context.Background(), UpdateTOTPLastStepParams{
+		Username:     user.Username,
+		TotpLastStep: 101,
+	})
+	require.NoError(t, err)
+
+	codes, err := testQueries.ListUnusedRecoveryCodes(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, codes, 2)
+
+	_, err = testQueries.UseRecoveryCode(context.Background(), codes[0].ID)
+	require.NoError(t, err)
+	_, err = testQueries.UseRecoveryCode(context.Background(), codes[0].ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	codes, err = testQueries.ListUnusedRecoveryCodes(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, codes, 1)
+}
+
+func TestLoginChallenge(t *testing.T) {
+	user := createRandomUser(t)
+
+	challenge, err := testQueries.CreateLoginChallenge(context.Background(), CreateLoginChallengeParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Empty(t, challenge.HashedEmailCode)
+
+	for i := 1; i <= 2; i++ {
+		challenge, err = testQueries.AttemptLoginChallenge(context.Background(), AttemptLoginChallengeParams{
+			ID:          challenge.ID,
+			MaxAttempts: 2,
+		})
+		require.NoError(t, err)
+		require.Equal(t, int32(i), challenge.Attempts)
+	}
+
+	_, err = testQueries.AttemptLoginChallenge(context.Background(), AttemptLoginChallengeParams{
+		ID:          challenge.ID,
+		MaxAttempts: 2,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	hashedCode := util.RandomString(60)
+	challenge, err = testQueries.SetLoginChallengeEmailCode(context.Background(), SetLoginChallengeEmailCodeParams{
+		ID:              challenge.ID,
+		HashedEmailCode: hashedCode,
+	})
+	require.NoError(t, err)
+	require.Equal(t, hashedCode, challenge.HashedEmailCode)
+
+	_, err = testQueries.UseLoginChallenge(context.Background(), challenge.ID)
+	require.NoError(t, err)
+	_, err = testQueries.UseLoginChallenge(context.Background(), challenge.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = testQueries.CountLoginCodeSent(context.Background(), CountLoginCodeSentParams{
+		ID:      challenge.ID,
+		MaxSent: 3,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestSecondFactorLockout(t *testing.T) {
+	user := createRandomUser(t)
+	lockedUntil := time.Now().Add(time.Minute)
+
+	challenge, err := testQueries.CreateLoginChallenge(context.Background(), CreateLoginChallengeParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	for i := 1; i <= 2; i++ {
+		user, err = testQueries.RecordFailedSecondFactor(context.Background(), RecordFailedSecondFactorParams{
+			Username:    user.Username,
+			MaxAttempts: 3,
+			LockedUntil: lockedUntil,
+		})
+		require.NoError(t, err)
+		require.Equal(t, int32(i), user.FailedSecondFactorAttempts)
+		require.True(t, user.SecondFactorLockedUntil.Before(time.Now()))
+	}
+
+	err = testQueries.ResetFailedSecondFactor(context.Background(), user.Username)
+	require.NoError(t, err)
+
+	for i := 1; i <= 3; i++ {
+		user, err = testQueries.RecordFailedSecondFactor(context.Background(), RecordFailedSecondFactorParams{
+			Username:    user.Username,
+			MaxAttempts: 3,
+			LockedUntil: lockedUntil,
+		})
+		require.NoError(t, err)
+	}
+	require.Zero(t, user.FailedSecondFactorAttempts)
+	require.WithinDuration(t, lockedUntil, user.SecondFactorLockedUntil, time.Second)
+
+	// the pre-auth tokens of the user can't be tried while it's locked
+	_, err = testQueries.AttemptLoginChallenge(context.Background(), AttemptLoginChallengeParams{
+		ID:          challenge.ID,
+		MaxAttempts: 5,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// AccountStatementTxParams contains the input parameters of the account statement transaction
+type AccountStatementTxParams struct {
+	AccountID int64     `json:"account_id"`
+	FromTime  time.Time `json:"from_time"`
+	ToTime    time.Time `json:"to_time"`
+}
+
+// StatementEntry is an entry of the statement with the balance of the account right after it
+type StatementEntry struct {
+	Entry
+	Balance int64 `json:"balance"`
+}
+
+// AccountStatementTxResult is the result of the account statement transaction
+type AccountStatementTxResult struct {
+	AccountID      int64            `json:"account_id"`
+	FromTime       time.Time        `json:"from_time"`
+	ToTime         time.Time        `json:"to_time"`
+	OpeningBalance int64            `json:"opening_balance"`
+	ClosingBalance int64            `json:"closing_balance"`
+	Entries        []StatementEntry `json:"entries"`
+}
+
+// AccountStatementTx returns the entries of the account made from FromTime up to, but not including,
+// ToTime, along with the balance of the account before, after and in between them.
+// The opening balance is worked back from the current one, so it holds for accounts opened with a balance.
+// Both queries read the same snapshot, or a transfer committed in between would make the balances
+// disagree with the entries.
+func (store *SQLStore) AccountStatementTx(ctx context.Context, arg AccountStatementTxParams) (AccountStatementTxResult, error) {
+	result := AccountStatementTxResult{
+		AccountID: arg.AccountID,
+		FromTime:  arg.FromTime,
+		ToTime:    arg.ToTime,
+		Entries:   []StatementEntry{},
+	}
+
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	err := store.execTxWithOptions(ctx, opts, func(q *Queries) error {
+		var err error
+
+		result.OpeningBalance, err = q.GetAccountBalanceAt(ctx, GetAccountBalanceAtParams{
+			At:        arg.FromTime,
+			AccountID: arg.AccountID,
+		})
+		if err != nil {
+			return err
+		}
+
+		entries, err := q.ListEntriesBetween(ctx, ListEntriesBetweenParams{
+			AccountID: arg.AccountID,
+			FromTime:  arg.FromTime,
+			ToTime:    arg.ToTime,
+		})
+		if err != nil {
+			return err
+		}
+
+		balance := result.OpeningBalance
+		for _, entry := range entries {
+			balance += entry.Amount
+			result.Entries = append(result.Entries, StatementEntry{Entry: entry, Balance: balance})
+		}
+		result.ClosingBalance = balance
+
+		return nil
+	})
+
+	return result, err
+}
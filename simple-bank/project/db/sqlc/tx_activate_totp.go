@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrTOTPAlreadyEnabled is returned by ActivateTOTPTx when the user has two-factor authentication enabled already.
+var ErrTOTPAlreadyEnabled = errors.New("two-factor authentication already enabled")
+
+// ActivateTOTPTxParams contains the input parameters of the activate TOTP transaction
+type ActivateTOTPTxParams struct {
+	Username string `json:"username"`
+	// Step is the step of the code that activated it, so that the code can't log in again
+	Step                int64    `json:"step"`
+	HashedRecoveryCodes []string `json:"hashed_recovery_codes"`
+}
+
+// ActivateTOTPTxResult is the result of the activate TOTP transaction
+type ActivateTOTPTxResult struct {
+	User          User           `json:"user"`
+	RecoveryCodes []RecoveryCode `json:"recovery_codes"`
+}
+
+// ActivateTOTPTx enables two-factor authentication for the user, and replaces their recovery codes.
+func (store *SQLStore) ActivateTOTPTx(ctx context.Context, arg ActivateTOTPTxParams) (ActivateTOTPTxResult, error) {
+	var result ActivateTOTPTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.EnableTOTP(ctx, EnableTOTPParams{
+			Username:     arg.Username,
+			TotpLastStep: arg.Step,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrTOTPAlreadyEnabled
+			}
+			return err
+		}
+
+		err = q.DeleteRecoveryCodes(ctx, arg.Username)
+		if err != nil {
+			return err
+		}
+
+		for _, hashedCode := range arg.HashedRecoveryCodes {
+			recoveryCode, err := q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+				Username:   arg.Username,
+				HashedCode: hashedCode,
+			})
+			if err != nil {
+				return err
+			}
+			result.RecoveryCodes = append(result.RecoveryCodes, recoveryCode)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ErrRefreshTokenReused is returned by RotateSessionTx when the session was rotated or blocked already,
+// so its refresh token has been used before, by its owner or by someone who stole it.
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// RotateSessionTxParams contains the input parameters of the rotate session transaction
+type RotateSessionTxParams struct {
+	ID uuid.UUID `json:"id"`
+	// NewSession is the session that replaces it, its FamilyID is set to the rotated session's
+	NewSession CreateSessionParams `json:"new_session"`
+}
+
+// RotateSessionTxResult is the result of the rotate session transaction
+type RotateSessionTxResult struct {
+	RotatedSession Session `json:"rotated_session"`
+	Session        Session `json:"session"`
+}
+
+// RotateSessionTx replaces the session with a new one of the same family. When the session can't be
+// rotated because its refresh token was used already, it blocks the whole family, so that neither the
+// owner nor an attacker can refresh it anymore, and returns ErrRefreshTokenReused.
+func (store *SQLStore) RotateSessionTx(ctx context.Context, arg RotateSessionTxParams) (RotateSessionTxResult, error) {
+	var result RotateSessionTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.RotatedSession, err = q.RotateSession(ctx, arg.ID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrRefreshTokenReused
+			}
+			return err
+		}
+
+		newSession := arg.NewSession
+		newSession.FamilyID = result.RotatedSession.FamilyID
+		result.Session, err = q.CreateSession(ctx, newSession)
+		return err
+	})
+
+	if err == ErrRefreshTokenReused {
+		session, getErr := store.GetSession(ctx, arg.ID)
+		if getErr != nil {
+			return result, getErr
+		}
+
+		if blockErr := store.BlockSessionFamily(ctx, session.FamilyID); blockErr != nil {
+			return result, blockErr
+		}
+	}
+
+	return result, err
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// The statuses of a scheduled transfer
+const (
+	ScheduledTransferActive    = "active"
+	ScheduledTransferCompleted = "completed"
+	ScheduledTransferCancelled = "cancelled"
+)
+
+// RecordScheduledTransferRunTxParams contains the input parameters of the record scheduled transfer run transaction
+type RecordScheduledTransferRunTxParams struct {
+	ScheduledTransferID int64         `json:"scheduled_transfer_id"`
+	ScheduledFor        time.Time     `json:"scheduled_for"`
+	TransferID          sql.NullInt64 `json:"transfer_id"`
+	Error               string        `json:"error"`
+	// NextRunAt is the run after this one, not valid when there's none
+	NextRunAt sql.NullTime `json:"next_run_at"`
+}
+
+// RecordScheduledTransferRunTxResult is the result of the record scheduled transfer run transaction
+type RecordScheduledTransferRunTxResult struct {
+	ScheduledTransfer    ScheduledTransfer    `json:"scheduled_transfer"`
+	ScheduledTransferRun ScheduledTransferRun `json:"scheduled_transfer_run"`
+}
+
+// RecordScheduledTransferRunTx records the outcome of a run of the scheduled transfer, and moves it on
+// to its next run, or completes it. It fails with sql.ErrNoRows, and records nothing, when the run was
+// recorded already or the transfer was cancelled meanwhile.
+func (store *SQLStore) RecordScheduledTransferRunTx(ctx context.Context, arg RecordScheduledTransferRunTxParams) (RecordScheduledTransferRunTxResult, error) {
+	var result RecordScheduledTransferRunTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.ScheduledTransfer, err = q.AdvanceScheduledTransfer(ctx, AdvanceScheduledTransferParams{
+			ID:           arg.ScheduledTransferID,
+			ScheduledFor: arg.ScheduledFor,
+			NextRunAt:    arg.NextRunAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.ScheduledTransferRun, err = q.CreateScheduledTransferRun(ctx, CreateScheduledTransferRunParams{
+			ScheduledTransferID: arg.ScheduledTransferID,
+			ScheduledFor:        arg.ScheduledFor,
+			TransferID:          arg.TransferID,
+			Error:               arg.Error,
+		})
+		return err
+	})
+
+	return result, err
+}
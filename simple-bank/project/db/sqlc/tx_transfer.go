@@ -1,12 +1,47 @@
 package db
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// The errors TransferTx rejects a transfer with. The transaction is rolled back, so nothing of
+// the transfer is left in the database.
+var (
+	ErrInsufficientFunds     = errors.New("insufficient funds")
+	ErrTransferLimitExceeded = errors.New("amount exceeds the transfer limit of the account")
+	ErrDailyLimitExceeded    = errors.New("amount exceeds the daily transfer limit of the account")
+	// ErrIdempotencyKeyConflict is returned when the idempotency key was used for another transfer.
+	ErrIdempotencyKeyConflict = errors.New("idempotency key already used for a different transfer")
+)
+
+// The scopes of idempotency keys. The keys of a scope never clash with the ones of another, so the
+// keys the bank makes for its own transfers can't be claimed by a client sending the same key.
+const (
+	IdempotencyScopeClient            = "client"
+	IdempotencyScopeScheduledTransfer = "scheduled_transfer"
+)
 
 // TransferTxParams contains the input parameters of the transfer transaction
 type TransferTxParams struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
 	Amount        int64 `json:"amount"`
+	// ToAmount is the amount credited to the to-account, converted from Amount at ExchangeRate when
+	// the accounts have different currencies. When ExchangeRate is empty, the accounts have the same
+	// currency and the to-account is credited with Amount.
+	ToAmount     int64  `json:"to_amount"`
+	ExchangeRate string `json:"exchange_rate"`
+	// IdempotencyKey, when set, makes the transfer happen only once for the calls of the user with the
+	// same key: the later calls return the result of the first one, until the key expires after
+	// IdempotencyKeyDuration. The key is looked up within IdempotencyScope, IdempotencyScopeClient when empty.
+	Username               string        `json:"username"`
+	IdempotencyScope       string        `json:"idempotency_scope"`
+	IdempotencyKey         string        `json:"idempotency_key"`
+	IdempotencyKeyDuration time.Duration `json:"-"`
 }
 
 // TransferTxResult is the result of the transfer transaction
@@ -19,33 +54,68 @@ type TransferTxResult struct {
 }
 
 // TransferTx performs a money transfer from one account to the other.
-// It creates the transfer, add account entries, and update accounts' balance within a database transaction
+// It creates the transfer, add account entries, and update accounts' balance within a database transaction.
+// The from-account is debited with Amount in its currency, and the to-account is credited with ToAmount in its own.
+// It fails with ErrInsufficientFunds, ErrTransferLimitExceeded or ErrDailyLimitExceeded when the from-account
+// can't cover the amount.
 func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
 	var result TransferTxResult
 
+	if arg.ExchangeRate == "" {
+		arg.ToAmount = arg.Amount
+		arg.ExchangeRate = "1"
+	}
+	if arg.IdempotencyScope == "" {
+		arg.IdempotencyScope = IdempotencyScopeClient
+	}
+
 	err := store.execTx(ctx, func(q *Queries) error {
 		var err error
 
+		if arg.IdempotencyKey != "" {
+			_, err = q.ClaimIdempotencyKey(ctx, ClaimIdempotencyKeyParams{
+				Username:      arg.Username,
+				Scope:         arg.IdempotencyScope,
+				Key:           arg.IdempotencyKey,
+				FromAccountID: arg.FromAccountID,
+				ToAccountID:   arg.ToAccountID,
+				Amount:        arg.Amount,
+				ExpiresAt:     time.Now().Add(arg.IdempotencyKeyDuration),
+			})
+			if err == sql.ErrNoRows {
+				return previousTransfer(ctx, q, arg, &result)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
 		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
 			FromAccountID: arg.FromAccountID,
 			ToAccountID:   arg.ToAccountID,
 			Amount:        arg.Amount,
+			ToAmount:      arg.ToAmount,
+			ExchangeRate:  arg.ExchangeRate,
 		})
 		if err != nil {
 			return err
 		}
 
+		transferID := sql.NullInt64{Int64: result.Transfer.ID, Valid: true}
+
 		result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.FromAccountID,
-			Amount:    -arg.Amount,
+			AccountID:  arg.FromAccountID,
+			Amount:     -arg.Amount,
+			TransferID: transferID,
 		})
 		if err != nil {
 			return err
 		}
 
 		result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.ToAccountID,
-			Amount:    arg.Amount,
+			AccountID:  arg.ToAccountID,
+			Amount:     arg.ToAmount,
+			TransferID: transferID,
 		})
 		if err != nil {
 			return err
@@ -57,37 +127,89 @@ func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (Tr
 		// than arg.ToAccountID then the from-account should be updated before the to-account.
 		// Else, the to-account should be updated before the from-account.
 		if arg.FromAccountID < arg.ToAccountID {
-			result.FromAccount, result.ToAccount, err = addMoney(ctx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount)
+			result.FromAccount, err = debitMoney(ctx, q, arg.FromAccountID, arg.Amount)
+			if err != nil {
+				return err
+			}
+			result.ToAccount, err = creditMoney(ctx, q, arg.ToAccountID, arg.ToAmount)
 		} else {
-			result.ToAccount, result.FromAccount, err = addMoney(ctx, q, arg.ToAccountID, arg.Amount, arg.FromAccountID, -arg.Amount)
+			result.ToAccount, err = creditMoney(ctx, q, arg.ToAccountID, arg.ToAmount)
+			if err != nil {
+				return err
+			}
+			result.FromAccount, err = debitMoney(ctx, q, arg.FromAccountID, arg.Amount)
+		}
+		if err != nil {
+			return err
 		}
 
-		return err
+		if arg.IdempotencyKey != "" {
+			js, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+
+			return q.SetIdempotencyKeyResult(ctx, SetIdempotencyKeyResultParams{
+				Username:   arg.Username,
+				Scope:      arg.IdempotencyScope,
+				Key:        arg.IdempotencyKey,
+				TransferID: sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+				Result:     js,
+			})
+		}
+
+		return nil
 	})
 
 	return result, err
 }
 
-func addMoney(
-	ctx context.Context,
-	q *Queries,
-	accountID1 int64,
-	amount1 int64,
-	accountID2 int64,
-	amount2 int64,
-) (account1 Account, account2 Account, err error) {
-	account1, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-		ID:     accountID1,
-		Amount: amount1,
+// previousTransfer reads the result of the transfer made with the idempotency key of arg. The key
+// is committed along with its result, as the claim of the key waits for the transaction holding it.
+func previousTransfer(ctx context.Context, q *Queries, arg TransferTxParams, result *TransferTxResult) error {
+	key, err := q.GetIdempotencyKey(ctx, GetIdempotencyKeyParams{
+		Username: arg.Username,
+		Scope:    arg.IdempotencyScope,
+		Key:      arg.IdempotencyKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	if key.FromAccountID != arg.FromAccountID || key.ToAccountID != arg.ToAccountID || key.Amount != arg.Amount {
+		return ErrIdempotencyKeyConflict
+	}
+
+	return json.Unmarshal(key.Result, result)
+}
+
+// debitMoney takes the amount out of the account and checks the result against its balance and
+// limits. The update locks the account row, so concurrent transfers from the same account are
+// checked one after the other, each one seeing the balance and the daily total left by the others.
+func debitMoney(ctx context.Context, q *Queries, accountID int64, amount int64) (Account, error) {
+	account, err := q.DebitAccount(ctx, DebitAccountParams{
+		ID:     accountID,
+		Amount: amount,
 	})
 	if err != nil {
-		// NOE: this syntax is like returning:  return account1, account2, err
-		return
+		return account, err
 	}
 
-	account2, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-		ID:     accountID2,
-		Amount: amount2,
+	switch {
+	case account.Balance < 0:
+		return account, ErrInsufficientFunds
+	case account.TransferLimit > 0 && amount > account.TransferLimit:
+		return account, ErrTransferLimitExceeded
+	case account.DailyTransferLimit > 0 && account.DailyTransferred > account.DailyTransferLimit:
+		return account, ErrDailyLimitExceeded
+	}
+
+	return account, nil
+}
+
+func creditMoney(ctx context.Context, q *Queries, accountID int64, amount int64) (Account, error) {
+	return q.AddAccountBalance(ctx, AddAccountBalanceParams{
+		ID:     accountID,
+		Amount: amount,
 	})
-	return
 }
@@ -8,6 +8,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -18,7 +19,7 @@ INSERT INTO users (
   email
 ) VALUES (
   $1, $2, $3, $4
-) RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, is_email_verified
+) RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, is_email_verified, role, totp_secret, is_totp_enabled, totp_last_step, failed_second_factor_attempts, second_factor_locked_until
 `
 
 type CreateUserParams struct {
@@ -44,12 +45,56 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.IsEmailVerified,
+		&i.Role,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		&i.FailedSecondFactorAttempts,
+		&i.SecondFactorLockedUntil,
+	)
+	return i, err
+}
+
+const enableTOTP = `-- name: EnableTOTP :one
+UPDATE users
+SET
+  is_totp_enabled = true,
+  totp_last_step = $2
+WHERE
+  username = $1 AND
+  is_totp_enabled = false
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, is_email_verified, role, totp_secret, is_totp_enabled, totp_last_step, failed_second_factor_attempts, second_factor_locked_until
+`
+
+type EnableTOTPParams struct {
+	Username     string `json:"username"`
+	TotpLastStep int64  `json:"totp_last_step"`
+}
+
+// EnableTOTP returns no rows when the user has two-factor authentication enabled already.
+func (q *Queries) EnableTOTP(ctx context.Context, arg EnableTOTPParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, enableTOTP, arg.Username, arg.TotpLastStep)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		&i.FailedSecondFactorAttempts,
+		&i.SecondFactorLockedUntil,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT username, hashed_password, full_name, email, password_changed_at, created_at, is_email_verified FROM users
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, is_email_verified, role, totp_secret, is_totp_enabled, totp_last_step, failed_second_factor_attempts, second_factor_locked_until FROM users
 WHERE username = $1 LIMIT 1
 `
 
@@ -64,6 +109,139 @@ func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.IsEmailVerified,
+		&i.Role,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		&i.FailedSecondFactorAttempts,
+		&i.SecondFactorLockedUntil,
+	)
+	return i, err
+}
+
+const recordFailedSecondFactor = `-- name: RecordFailedSecondFactor :one
+UPDATE users
+SET
+  failed_second_factor_attempts = CASE
+    WHEN failed_second_factor_attempts + 1 >= $1::int THEN 0
+    ELSE failed_second_factor_attempts + 1
+  END,
+  second_factor_locked_until = CASE
+    WHEN failed_second_factor_attempts + 1 >= $1::int THEN $2::timestamptz
+    ELSE second_factor_locked_until
+  END
+WHERE username = $3
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, is_email_verified, role, totp_secret, is_totp_enabled, totp_last_step, failed_second_factor_attempts, second_factor_locked_until
+`
+
+type RecordFailedSecondFactorParams struct {
+	MaxAttempts int32     `json:"max_attempts"`
+	LockedUntil time.Time `json:"locked_until"`
+	Username    string    `json:"username"`
+}
+
+// RecordFailedSecondFactor counts an incorrect code of the user's second factor. The max_attempts-th
+// one in a row locks the second factor until locked_until, and starts the count over.
+func (q *Queries) RecordFailedSecondFactor(ctx context.Context, arg RecordFailedSecondFactorParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, recordFailedSecondFactor, arg.MaxAttempts, arg.LockedUntil, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		&i.FailedSecondFactorAttempts,
+		&i.SecondFactorLockedUntil,
+	)
+	return i, err
+}
+
+const resetFailedSecondFactor = `-- name: ResetFailedSecondFactor :exec
+UPDATE users
+SET failed_second_factor_attempts = 0
+WHERE username = $1
+`
+
+func (q *Queries) ResetFailedSecondFactor(ctx context.Context, username string) error {
+	_, err := q.db.ExecContext(ctx, resetFailedSecondFactor, username)
+	return err
+}
+
+const setTOTPSecret = `-- name: SetTOTPSecret :one
+UPDATE users
+SET totp_secret = $2
+WHERE
+  username = $1 AND
+  is_totp_enabled = false
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, is_email_verified, role, totp_secret, is_totp_enabled, totp_last_step, failed_second_factor_attempts, second_factor_locked_until
+`
+
+type SetTOTPSecretParams struct {
+	Username   string `json:"username"`
+	TotpSecret string `json:"totp_secret"`
+}
+
+// SetTOTPSecret returns no rows when the user has two-factor authentication enabled already.
+func (q *Queries) SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setTOTPSecret, arg.Username, arg.TotpSecret)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		&i.FailedSecondFactorAttempts,
+		&i.SecondFactorLockedUntil,
+	)
+	return i, err
+}
+
+const updateTOTPLastStep = `-- name: UpdateTOTPLastStep :one
+UPDATE users
+SET totp_last_step = $2
+WHERE
+  username = $1 AND
+  totp_last_step < $2
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, is_email_verified, role, totp_secret, is_totp_enabled, totp_last_step, failed_second_factor_attempts, second_factor_locked_until
+`
+
+type UpdateTOTPLastStepParams struct {
+	Username     string `json:"username"`
+	TotpLastStep int64  `json:"totp_last_step"`
+}
+
+// UpdateTOTPLastStep returns no rows when a code of the step, or a later one, was accepted already.
+func (q *Queries) UpdateTOTPLastStep(ctx context.Context, arg UpdateTOTPLastStepParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateTOTPLastStep, arg.Username, arg.TotpLastStep)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		&i.FailedSecondFactorAttempts,
+		&i.SecondFactorLockedUntil,
 	)
 	return i, err
 }
@@ -78,7 +256,7 @@ SET
   is_email_verified = COALESCE($5, is_email_verified)
 WHERE
   username = $6
-RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, is_email_verified
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, is_email_verified, role, totp_secret, is_totp_enabled, totp_last_step, failed_second_factor_attempts, second_factor_locked_until
 `
 
 type UpdateUserParams struct {
@@ -108,6 +286,12 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.IsEmailVerified,
+		&i.Role,
+		&i.TotpSecret,
+		&i.IsTotpEnabled,
+		&i.TotpLastStep,
+		&i.FailedSecondFactorAttempts,
+		&i.SecondFactorLockedUntil,
 	)
 	return i, err
 }
@@ -9,7 +9,13 @@ CREATE TABLE "users" (
   "email" varchar UNIQUE NOT NULL,
   "is_email_verified" bool NOT NULL DEFAULT false,
   "password_changed_at" timestamptz NOT NULL DEFAULT '0001-01-01',
-  "created_at" timestamptz NOT NULL DEFAULT (now())
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "role" varchar NOT NULL DEFAULT 'depositor',
+  "totp_secret" varchar NOT NULL DEFAULT '',
+  "is_totp_enabled" bool NOT NULL DEFAULT false,
+  "totp_last_step" bigint NOT NULL DEFAULT 0,
+  "failed_second_factor_attempts" int NOT NULL DEFAULT 0,
+  "second_factor_locked_until" timestamptz NOT NULL DEFAULT '0001-01-01'
 );
 
 CREATE TABLE "verify_emails" (
@@ -22,19 +28,30 @@ CREATE TABLE "verify_emails" (
   "expired_at" timestamptz NOT NULL DEFAULT (now() + interval '15 minutes')
 );
 
+CREATE TABLE "currencies" (
+  "code" varchar PRIMARY KEY,
+  "name" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
 CREATE TABLE "accounts" (
   "id" bigserial PRIMARY KEY,
   "owner" varchar NOT NULL,
   "balance" bigint NOT NULL,
   "currency" varchar NOT NULL,
-  "created_at" timestamptz NOT NULL DEFAULT (now())
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "transfer_limit" bigint NOT NULL DEFAULT 0,
+  "daily_transfer_limit" bigint NOT NULL DEFAULT 0,
+  "daily_transferred" bigint NOT NULL DEFAULT 0,
+  "daily_transferred_on" date NOT NULL DEFAULT (CURRENT_DATE)
 );
 
 CREATE TABLE "entries" (
   "id" bigserial PRIMARY KEY,
   "account_id" bigint NOT NULL,
   "amount" bigint NOT NULL,
-  "created_at" timestamptz NOT NULL DEFAULT (now())
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "transfer_id" bigint
 );
 
 CREATE TABLE "transfers" (
@@ -42,7 +59,9 @@ CREATE TABLE "transfers" (
   "from_account_id" bigint NOT NULL,
   "to_account_id" bigint NOT NULL,
   "amount" bigint NOT NULL,
-  "created_at" timestamptz NOT NULL DEFAULT (now())
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "to_amount" bigint NOT NULL,
+  "exchange_rate" numeric(20,8) NOT NULL DEFAULT 1
 );
 
 CREATE TABLE "sessions" (
@@ -53,6 +72,83 @@ CREATE TABLE "sessions" (
   "client_ip" varchar NOT NULL,
   "is_blocked" boolean NOT NULL DEFAULT false,
   "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "family_id" uuid NOT NULL,
+  "is_rotated" boolean NOT NULL DEFAULT false
+);
+
+CREATE TABLE "login_challenges" (
+  "id" uuid PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "hashed_email_code" varchar NOT NULL DEFAULT '',
+  "email_codes_sent" int NOT NULL DEFAULT 0,
+  "attempts" int NOT NULL DEFAULT 0,
+  "is_used" bool NOT NULL DEFAULT false,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "hashed_code" varchar NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "idempotency_keys" (
+  "username" varchar NOT NULL,
+  "scope" varchar NOT NULL DEFAULT 'client',
+  "key" varchar NOT NULL,
+  "from_account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "transfer_id" bigint,
+  "result" jsonb NOT NULL DEFAULT '{}',
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "expires_at" timestamptz NOT NULL,
+  PRIMARY KEY ("username", "scope", "key")
+);
+
+CREATE TABLE "scheduled_transfers" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "from_account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "schedule" varchar NOT NULL DEFAULT '',
+  "status" varchar NOT NULL DEFAULT 'active',
+  "next_run_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "scheduled_transfer_runs" (
+  "id" bigserial PRIMARY KEY,
+  "scheduled_transfer_id" bigint NOT NULL,
+  "scheduled_for" timestamptz NOT NULL,
+  "transfer_id" bigint,
+  "error" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "reconciliation_runs" (
+  "id" bigserial PRIMARY KEY,
+  "accounts_checked" bigint NOT NULL DEFAULT 0,
+  "transfers_checked" bigint NOT NULL DEFAULT 0,
+  "discrepancies" bigint NOT NULL DEFAULT 0,
+  "started_at" timestamptz NOT NULL DEFAULT (now()),
+  "finished_at" timestamptz
+);
+
+CREATE TABLE "ledger_discrepancies" (
+  "id" bigserial PRIMARY KEY,
+  "run_id" bigint NOT NULL,
+  "kind" varchar NOT NULL,
+  "account_id" bigint,
+  "transfer_id" bigint,
+  "expected" bigint NOT NULL,
+  "actual" bigint NOT NULL,
+  "details" varchar NOT NULL DEFAULT '',
   "created_at" timestamptz NOT NULL DEFAULT (now())
 );
 
@@ -62,20 +158,64 @@ CREATE UNIQUE INDEX ON "accounts" ("owner", "currency");
 
 CREATE INDEX ON "entries" ("account_id");
 
+CREATE INDEX ON "entries" ("transfer_id");
+
 CREATE INDEX ON "transfers" ("from_account_id");
 
 CREATE INDEX ON "transfers" ("to_account_id");
 
 CREATE INDEX ON "transfers" ("from_account_id", "to_account_id");
 
+CREATE INDEX ON "sessions" ("family_id");
+
+CREATE INDEX ON "sessions" ("username");
+
+CREATE INDEX ON "recovery_codes" ("username");
+
+CREATE INDEX ON "idempotency_keys" ("expires_at");
+
+CREATE INDEX ON "scheduled_transfers" ("owner");
+
+CREATE INDEX ON "scheduled_transfers" ("status", "next_run_at");
+
+CREATE UNIQUE INDEX ON "scheduled_transfer_runs" ("scheduled_transfer_id", "scheduled_for");
+
+CREATE INDEX ON "ledger_discrepancies" ("run_id");
+
+COMMENT ON COLUMN "accounts"."transfer_limit" IS '0 means no limit';
+
+COMMENT ON COLUMN "accounts"."daily_transfer_limit" IS '0 means no limit';
+
 COMMENT ON COLUMN "entries"."amount" IS 'can be negative or positive';
 
 COMMENT ON COLUMN "transfers"."amount" IS 'must be positive';
 
+COMMENT ON COLUMN "transfers"."to_amount" IS 'must be positive';
+
+COMMENT ON COLUMN "idempotency_keys"."scope" IS 'who made the key: the client, or the bank for its own transfers';
+
+COMMENT ON COLUMN "idempotency_keys"."result" IS 'the TransferTxResult returned for the key';
+
+COMMENT ON COLUMN "scheduled_transfers"."amount" IS 'must be positive';
+
+COMMENT ON COLUMN "scheduled_transfers"."schedule" IS 'cron expression of a recurring transfer, empty for a one-off transfer';
+
+COMMENT ON COLUMN "scheduled_transfers"."status" IS 'active, completed or cancelled';
+
+COMMENT ON COLUMN "scheduled_transfers"."next_run_at" IS 'null once the transfer is completed or cancelled';
+
+COMMENT ON COLUMN "scheduled_transfer_runs"."error" IS 'empty when the transfer went through';
+
+COMMENT ON COLUMN "reconciliation_runs"."finished_at" IS 'null while the run is in progress, or when it failed';
+
+COMMENT ON COLUMN "ledger_discrepancies"."kind" IS 'account_balance, transfer_entries or transfer_amounts';
+
 ALTER TABLE "verify_emails" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
 
 ALTER TABLE "accounts" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
 
+ALTER TABLE "accounts" ADD FOREIGN KEY ("currency") REFERENCES "currencies" ("code");
+
 ALTER TABLE "entries" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
 
 ALTER TABLE "transfers" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
@@ -83,3 +223,29 @@ ALTER TABLE "transfers" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts
 ALTER TABLE "transfers" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
 
 ALTER TABLE "sessions" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "login_challenges" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "idempotency_keys" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "idempotency_keys" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfer_runs" ADD FOREIGN KEY ("scheduled_transfer_id") REFERENCES "scheduled_transfers" ("id");
+
+ALTER TABLE "scheduled_transfer_runs" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "entries" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "ledger_discrepancies" ADD FOREIGN KEY ("run_id") REFERENCES "reconciliation_runs" ("id");
+
+ALTER TABLE "ledger_discrepancies" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "ledger_discrepancies" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
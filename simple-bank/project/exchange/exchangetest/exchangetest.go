@@ -0,0 +1,21 @@
+// Package exchangetest provides the exchange rates the tests of the servers use.
+package exchangetest
+
+import (
+	"testing"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/stretchr/testify/require"
+)
+
+// NewRateProvider returns rates where 1 USD is 2 EUR and 4 CAD, so conversions are exact.
+func NewRateProvider(t *testing.T) exchange.ExchangeRateProvider {
+	rates, err := exchange.NewStaticRateProvider(util.USD, map[string]string{
+		util.EUR: "2",
+		util.CAD: "4",
+	})
+	require.NoError(t, err)
+
+	return rates
+}
@@ -0,0 +1,70 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// StaticRateProvider serves fixed rates, given against a base currency. It stands in for a real
+// rates service.
+type StaticRateProvider struct {
+	base  string
+	rates map[string]*big.Rat
+}
+
+// NewStaticRateProvider creates a provider from the price of one unit of the base currency in
+// each of the other currencies.
+func NewStaticRateProvider(base string, rates map[string]string) (*StaticRateProvider, error) {
+	provider := &StaticRateProvider{
+		base:  base,
+		rates: map[string]*big.Rat{base: big.NewRat(1, 1)},
+	}
+
+	for currency, value := range rates {
+		v, ok := new(big.Rat).SetString(value)
+		if !ok || v.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate from %s to %s: %q", base, currency, value)
+		}
+		provider.rates[currency] = v
+	}
+
+	return provider, nil
+}
+
+// NewFileRateProvider loads the rates from a JSON file like:
+//
+//	{"base": "USD", "rates": {"EUR": "0.92", "CAD": "1.36"}}
+func NewFileRateProvider(path string) (*StaticRateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read exchange rates: %w", err)
+	}
+
+	var file struct {
+		Base  string            `json:"base"`
+		Rates map[string]string `json:"rates"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cannot parse exchange rates: %w", err)
+	}
+
+	return NewStaticRateProvider(file.Base, file.Rates)
+}
+
+// Rate returns the rate between two currencies, crossing through the base currency.
+func (provider *StaticRateProvider) Rate(ctx context.Context, from string, to string) (Rate, error) {
+	fromRate, ok := provider.rates[from]
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: from %s to %s", ErrRateNotFound, from, to)
+	}
+
+	toRate, ok := provider.rates[to]
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: from %s to %s", ErrRateNotFound, from, to)
+	}
+
+	return newRate(from, to, new(big.Rat).Quo(toRate, fromRate))
+}
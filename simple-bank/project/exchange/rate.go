@@ -0,0 +1,103 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// RatePrecision is the number of decimals rates are recorded with.
+const RatePrecision = 8
+
+// The errors converting money between currencies fails with
+var (
+	// ErrRateNotFound is returned when there's no rate between two currencies.
+	ErrRateNotFound = errors.New("exchange rate not found")
+	// ErrAmountTooSmall is returned when the amount converts to nothing.
+	ErrAmountTooSmall = errors.New("amount is too small to convert")
+	// ErrAmountTooLarge is returned when the converted amount doesn't fit in an int64.
+	ErrAmountTooLarge = errors.New("amount is too large to convert")
+)
+
+// ExchangeRateProvider gives the rates to convert money between currencies.
+type ExchangeRateProvider interface {
+	// Rate returns the rate to convert money from one currency to another.
+	Rate(ctx context.Context, from string, to string) (Rate, error)
+}
+
+// Convert returns the amount converted from one currency to another with the rate of the provider,
+// along with the rate.
+func Convert(ctx context.Context, provider ExchangeRateProvider, from string, to string, amount int64) (int64, Rate, error) {
+	rate, err := provider.Rate(ctx, from, to)
+	if err != nil {
+		return 0, rate, err
+	}
+
+	converted, err := rate.Convert(amount)
+	if err != nil {
+		return 0, rate, err
+	}
+	if converted <= 0 {
+		return 0, rate, fmt.Errorf("%w from %s to %s", ErrAmountTooSmall, from, to)
+	}
+
+	return converted, rate, nil
+}
+
+// Rate is the price of one unit of the From currency in the To currency.
+type Rate struct {
+	From  string
+	To    string
+	value *big.Rat
+}
+
+// NewRate creates a rate from its decimal value, like "1.08". The value is rounded to RatePrecision
+// decimals, so the rate used to convert money is the one recorded.
+func NewRate(from string, to string, value string) (Rate, error) {
+	v, ok := new(big.Rat).SetString(value)
+	if !ok || v.Sign() <= 0 {
+		return Rate{}, fmt.Errorf("invalid exchange rate from %s to %s: %q", from, to, value)
+	}
+
+	return newRate(from, to, v)
+}
+
+func newRate(from string, to string, value *big.Rat) (Rate, error) {
+	rounded, _ := new(big.Rat).SetString(value.FloatString(RatePrecision))
+	if rounded.Sign() <= 0 {
+		return Rate{}, fmt.Errorf("exchange rate from %s to %s is too small", from, to)
+	}
+
+	return Rate{From: from, To: to, value: rounded}, nil
+}
+
+// String returns the decimal value of the rate.
+func (rate Rate) String() string {
+	return rate.value.FloatString(RatePrecision)
+}
+
+// IsIdentity returns true if the rate is 1, like between accounts of the same currency.
+func (rate Rate) IsIdentity() bool {
+	return rate.value.Cmp(big.NewRat(1, 1)) == 0
+}
+
+// Convert returns the amount in the To currency, rounded to the nearest unit. It fails with
+// ErrAmountTooLarge when the converted amount doesn't fit in an int64.
+func (rate Rate) Convert(amount int64) (int64, error) {
+	converted := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), rate.value)
+
+	// round half away from zero: add or subtract 1/2 then truncate
+	half := big.NewRat(1, 2)
+	if converted.Sign() < 0 {
+		converted.Sub(converted, half)
+	} else {
+		converted.Add(converted, half)
+	}
+
+	rounded := new(big.Int).Quo(converted.Num(), converted.Denom())
+	if !rounded.IsInt64() {
+		return 0, fmt.Errorf("%w from %s to %s", ErrAmountTooLarge, rate.From, rate.To)
+	}
+	return rounded.Int64(), nil
+}
@@ -0,0 +1,127 @@
+package exchange
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	rate, err := NewRate("USD", "EUR", "0.925")
+	require.NoError(t, err)
+	require.Equal(t, "0.92500000", rate.String())
+
+	testCases := []struct {
+		amount    int64
+		converted int64
+	}{
+		{1000, 925},
+		{1, 1}, // 0.925 rounds up
+		{0, 0},
+		{-1000, -925},
+	}
+	for _, tc := range testCases {
+		converted, err := rate.Convert(tc.amount)
+		require.NoError(t, err)
+		require.Equal(t, tc.converted, converted)
+	}
+	require.False(t, rate.IsIdentity())
+
+	identity, err := NewRate("USD", "USD", "1")
+	require.NoError(t, err)
+	require.True(t, identity.IsIdentity())
+
+	_, err = NewRate("USD", "EUR", "0")
+	require.Error(t, err)
+
+	_, err = NewRate("USD", "EUR", "abc")
+	require.Error(t, err)
+}
+
+func TestConvertOverflow(t *testing.T) {
+	rate, err := NewRate("USD", "JPY", "150")
+	require.NoError(t, err)
+
+	_, err = rate.Convert(math.MaxInt64)
+	require.ErrorIs(t, err, ErrAmountTooLarge)
+	_, err = rate.Convert(math.MinInt64)
+	require.ErrorIs(t, err, ErrAmountTooLarge)
+
+	// the largest amount that converts still fits
+	converted, err := rate.Convert(math.MaxInt64 / 150)
+	require.NoError(t, err)
+	require.Equal(t, int64(math.MaxInt64/150*150), converted)
+
+	provider, err := NewStaticRateProvider("USD", map[string]string{"JPY": "150"})
+	require.NoError(t, err)
+	_, _, err = Convert(context.Background(), provider, "USD", "JPY", math.MaxInt64)
+	require.ErrorIs(t, err, ErrAmountTooLarge)
+}
+
+func TestConvertWithProvider(t *testing.T) {
+	provider, err := NewStaticRateProvider("USD", map[string]string{"EUR": "0.5"})
+	require.NoError(t, err)
+
+	converted, rate, err := Convert(context.Background(), provider, "USD", "EUR", 100)
+	require.NoError(t, err)
+	require.Equal(t, int64(50), converted)
+	require.Equal(t, "0.50000000", rate.String())
+
+	_, _, err = Convert(context.Background(), provider, "USD", "EUR", 0)
+	require.ErrorIs(t, err, ErrAmountTooSmall)
+
+	_, _, err = Convert(context.Background(), provider, "USD", "JPY", 100)
+	require.ErrorIs(t, err, ErrRateNotFound)
+}
+
+func TestStaticRateProvider(t *testing.T) {
+	provider, err := NewStaticRateProvider("USD", map[string]string{
+		"EUR": "0.8",
+		"CAD": "1.2",
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		from string
+		to   string
+		rate string
+	}{
+		{"USD", "EUR", "0.80000000"},
+		{"EUR", "USD", "1.25000000"},
+		{"EUR", "CAD", "1.50000000"},
+		{"CAD", "CAD", "1.00000000"},
+	}
+
+	for _, tc := range testCases {
+		rate, err := provider.Rate(context.Background(), tc.from, tc.to)
+		require.NoError(t, err)
+		require.Equal(t, tc.from, rate.From)
+		require.Equal(t, tc.to, rate.To)
+		require.Equal(t, tc.rate, rate.String())
+	}
+
+	_, err = provider.Rate(context.Background(), "USD", "JPY")
+	require.ErrorIs(t, err, ErrRateNotFound)
+}
+
+func TestFileRateProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	err := os.WriteFile(path, []byte(`{"base": "USD", "rates": {"EUR": "0.5"}}`), 0o600)
+	require.NoError(t, err)
+
+	provider, err := NewFileRateProvider(path)
+	require.NoError(t, err)
+
+	rate, err := provider.Rate(context.Background(), "EUR", "USD")
+	require.NoError(t, err)
+	converted, err := rate.Convert(100)
+	require.NoError(t, err)
+	require.Equal(t, int64(200), converted)
+
+	_, err = NewFileRateProvider(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
@@ -13,5 +13,82 @@ func convertUser(user db.User) *pb.User {
 		Email:             user.Email,
 		PasswordChangedAt: timestamppb.New(user.PasswordChangedAt),
 		CreatedAt:         timestamppb.New(user.CreatedAt),
+		Role:              user.Role,
+		IsTotpEnabled:     user.IsTotpEnabled,
+	}
+}
+
+func convertAccount(account db.Account) *pb.Account {
+	return &pb.Account{
+		Id:        account.ID,
+		Owner:     account.Owner,
+		Balance:   account.Balance,
+		Currency:  account.Currency,
+		CreatedAt: timestamppb.New(account.CreatedAt),
+
+		TransferLimit:      account.TransferLimit,
+		DailyTransferLimit: account.DailyTransferLimit,
+	}
+}
+
+func convertTransfer(transfer db.Transfer) *pb.Transfer {
+	return &pb.Transfer{
+		Id:            transfer.ID,
+		FromAccountId: transfer.FromAccountID,
+		ToAccountId:   transfer.ToAccountID,
+		Amount:        transfer.Amount,
+		CreatedAt:     timestamppb.New(transfer.CreatedAt),
+		ToAmount:      transfer.ToAmount,
+		ExchangeRate:  transfer.ExchangeRate,
+	}
+}
+
+func convertStatementEntry(entry db.StatementEntry) *pb.StatementEntry {
+	return &pb.StatementEntry{
+		Entry:   convertEntry(entry.Entry),
+		Balance: entry.Balance,
+	}
+}
+
+func convertScheduledTransfer(scheduledTransfer db.ScheduledTransfer) *pb.ScheduledTransfer {
+	rsp := &pb.ScheduledTransfer{
+		Id:            scheduledTransfer.ID,
+		Owner:         scheduledTransfer.Owner,
+		FromAccountId: scheduledTransfer.FromAccountID,
+		ToAccountId:   scheduledTransfer.ToAccountID,
+		Amount:        scheduledTransfer.Amount,
+		Schedule:      scheduledTransfer.Schedule,
+		Status:        scheduledTransfer.Status,
+		CreatedAt:     timestamppb.New(scheduledTransfer.CreatedAt),
+	}
+	if scheduledTransfer.NextRunAt.Valid {
+		rsp.NextRunAt = timestamppb.New(scheduledTransfer.NextRunAt.Time)
+	}
+	return rsp
+}
+
+func convertCurrency(currency db.Currency) *pb.Currency {
+	return &pb.Currency{
+		Code: currency.Code,
+		Name: currency.Name,
+	}
+}
+
+func convertEntry(entry db.Entry) *pb.Entry {
+	return &pb.Entry{
+		Id:        entry.ID,
+		AccountId: entry.AccountID,
+		Amount:    entry.Amount,
+		CreatedAt: timestamppb.New(entry.CreatedAt),
+	}
+}
+
+func convertSession(session db.Session) *pb.Session {
+	return &pb.Session{
+		Id:        session.ID.String(),
+		UserAgent: session.UserAgent,
+		ClientIp:  session.ClientIp,
+		ExpiresAt: timestamppb.New(session.ExpiresAt),
+		CreatedAt: timestamppb.New(session.CreatedAt),
 	}
 }
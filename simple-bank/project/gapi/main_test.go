@@ -7,6 +7,7 @@ import (
 	"time"
 
 	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange/exchangetest"
 	"github.com/ahmad-khatib0/go/simple-bank/project/token"
 	"github.com/ahmad-khatib0/go/simple-bank/project/util"
 	"github.com/ahmad-khatib0/go/simple-bank/project/worker"
@@ -16,18 +17,19 @@ import (
 
 func newTestServer(t *testing.T, store db.Store, taskDistributor worker.TaskDistributor) *Server {
 	config := util.Config{
-		TokenSymmetricKey:   util.RandomString(32),
-		AccessTokenDuration: time.Minute,
+		TokenSymmetricKey:    util.RandomString(32),
+		AccessTokenDuration:  time.Minute,
+		PreAuthTokenDuration: time.Minute,
 	}
 
-	server, err := NewServer(config, store, taskDistributor)
+	server, err := NewServer(config, store, taskDistributor, exchangetest.NewRateProvider(t))
 	require.NoError(t, err)
 
 	return server
 }
 
-func newContextWithBearerToken(t *testing.T, tokenMaker token.Maker, username string, duration time.Duration) context.Context {
-	accessToken, _, err := tokenMaker.CreateToken(username, duration)
+func newContextWithBearerToken(t *testing.T, tokenMaker token.Maker, username string, role string, duration time.Duration) context.Context {
+	accessToken, _, err := tokenMaker.CreateToken(username, role, duration)
 	require.NoError(t, err)
 
 	bearerToken := fmt.Sprintf("%s %s", authorizationBearer, accessToken)
@@ -11,6 +11,9 @@ const (
 	grpcGatewayUserAgentHeader = "grpcgateway-user-agent"
 	userAgentHeader            = "user-agent"
 	xForwardedForHeader        = "x-forwarded-for"
+	// IdempotencyKeyHeader is the metadata key of the key that makes retrying a transfer safe.
+	// The HTTP gateway forwards the Idempotency-Key header under it.
+	IdempotencyKeyHeader = "idempotency-key"
 )
 
 type Metadata struct {
@@ -41,3 +44,13 @@ func (server *Server) extractMetadata(ctx context.Context) *Metadata {
 
 	return mtdt
 }
+
+// extractIdempotencyKey returns the idempotency key of the request, if it has one.
+func extractIdempotencyKey(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if keys := md.Get(IdempotencyKeyHeader); len(keys) > 0 {
+			return keys[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/otp"
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/ahmad-khatib0/go/simple-bank/project/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ActivateTOTP enables two-factor authentication for the authenticated user, once they confirmed it with
+// their password and proved with a code that their authenticator app enrolled the secret. It returns their recovery codes, which are
+// only stored hashed, so they can't be shown again.
+func (server *Server) ActivateTOTP(ctx context.Context, req *pb.ActivateTOTPRequest) (*pb.ActivateTOTPResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	violations := validateActivateTOTPRequest(req)
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Errorf(codes.NotFound, "user not found")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to find user: %s", err)
+	}
+
+	err = util.CheckPassword(req.GetPassword(), user.HashedPassword)
+	if err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, "incorrect password")
+	}
+
+	if user.IsTotpEnabled {
+		return nil, status.Errorf(codes.FailedPrecondition, "two-factor authentication is enabled already")
+	}
+	if user.TotpSecret == "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "TOTP isn't enrolled")
+	}
+
+	step, ok := otp.ValidateCode(user.TotpSecret, req.GetCode(), time.Now())
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "incorrect code")
+	}
+
+	recoveryCodes, err := otp.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%s", err)
+	}
+
+	hashedRecoveryCodes := make([]string, len(recoveryCodes))
+	for i, recoveryCode := range recoveryCodes {
+		hashedRecoveryCodes[i], err = util.HashPassword(recoveryCode)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to hash recovery code: %s", err)
+		}
+	}
+
+	result, err := server.store.ActivateTOTPTx(ctx, db.ActivateTOTPTxParams{
+		Username:            user.Username,
+		Step:                step,
+		HashedRecoveryCodes: hashedRecoveryCodes,
+	})
+	if err != nil {
+		if err == db.ErrTOTPAlreadyEnabled {
+			return nil, status.Errorf(codes.FailedPrecondition, "two-factor authentication is enabled already")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to activate TOTP: %s", err)
+	}
+
+	rsp := &pb.ActivateTOTPResponse{
+		User:          convertUser(result.User),
+		RecoveryCodes: recoveryCodes,
+	}
+	return rsp, nil
+}
+
+func validateActivateTOTPRequest(req *pb.ActivateTOTPRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateTOTPCode(req.GetCode()); err != nil {
+		violations = append(violations, fieldViolation("code", err))
+	}
+
+	if err := val.ValidatePassword(req.GetPassword()); err != nil {
+		violations = append(violations, fieldViolation("password", err))
+	}
+
+	return violations
+}
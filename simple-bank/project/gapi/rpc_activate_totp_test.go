@@ -0,0 +1,204 @@
+package gapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mockdb "github.com/ahmad-khatib0/go/simple-bank/project/db/mock"
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/otp"
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/token"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestActivateTOTPAPI(t *testing.T) {
+	user, password := randomUser(t)
+
+	secret, err := otp.GenerateSecret()
+	require.NoError(t, err)
+	user.TotpSecret = secret
+
+	code, err := otp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+
+	requireCode := func(t *testing.T, err error, code codes.Code) {
+		require.Error(t, err)
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, code, st.Code())
+	}
+
+	testCases := []struct {
+		name          string
+		req           *pb.ActivateTOTPRequest
+		buildStubs    func(store *mockdb.MockStore)
+		buildContext  func(t *testing.T, tokenMaker token.Maker) context.Context
+		checkResponse func(t *testing.T, res *pb.ActivateTOTPResponse, err error)
+	}{
+		{
+			name: "OK",
+			req:  &pb.ActivateTOTPRequest{Code: code, Password: password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ActivateTOTPTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.ActivateTOTPTxParams) (db.ActivateTOTPTxResult, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.InDelta(t, otp.Step(time.Now()), arg.Step, otp.Skew)
+						require.Len(t, arg.HashedRecoveryCodes, otp.RecoveryCodeCount)
+
+						enabledUser := user
+						enabledUser.IsTotpEnabled = true
+						return db.ActivateTOTPTxResult{User: enabledUser}, nil
+					})
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, user.Username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.ActivateTOTPResponse, err error) {
+				require.NoError(t, err)
+				require.True(t, res.GetUser().GetIsTotpEnabled())
+				require.Len(t, res.GetRecoveryCodes(), otp.RecoveryCodeCount)
+			},
+		},
+		{
+			name: "IncorrectCode",
+			req:  &pb.ActivateTOTPRequest{Code: "000000", Password: password},
+			buildStubs: func(store *mockdb.MockStore) {
+				wrongSecret := user
+				wrongSecret.TotpSecret, _ = otp.GenerateSecret()
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(wrongSecret, nil)
+				store.EXPECT().
+					ActivateTOTPTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, user.Username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.ActivateTOTPResponse, err error) {
+				requireCode(t, err, codes.InvalidArgument)
+			},
+		},
+		{
+			name: "IncorrectPassword",
+			req:  &pb.ActivateTOTPRequest{Code: code, Password: "incorrect"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ActivateTOTPTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, user.Username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.ActivateTOTPResponse, err error) {
+				requireCode(t, err, codes.PermissionDenied)
+			},
+		},
+		{
+			name: "NotEnrolled",
+			req:  &pb.ActivateTOTPRequest{Code: code, Password: password},
+			buildStubs: func(store *mockdb.MockStore) {
+				notEnrolled := user
+				notEnrolled.TotpSecret = ""
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(notEnrolled, nil)
+				store.EXPECT().
+					ActivateTOTPTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, user.Username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.ActivateTOTPResponse, err error) {
+				requireCode(t, err, codes.FailedPrecondition)
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			req:  &pb.ActivateTOTPRequest{Code: code, Password: password},
+			buildStubs: func(store *mockdb.MockStore) {
+				enabledUser := user
+				enabledUser.IsTotpEnabled = true
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(enabledUser, nil)
+				store.EXPECT().
+					ActivateTOTPTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, user.Username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.ActivateTOTPResponse, err error) {
+				requireCode(t, err, codes.FailedPrecondition)
+			},
+		},
+		{
+			name: "InvalidCode",
+			req:  &pb.ActivateTOTPRequest{Code: "abc", Password: password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, user.Username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.ActivateTOTPResponse, err error) {
+				requireCode(t, err, codes.InvalidArgument)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			req:  &pb.ActivateTOTPRequest{Code: code, Password: password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return context.Background()
+			},
+			checkResponse: func(t *testing.T, res *pb.ActivateTOTPResponse, err error) {
+				requireCode(t, err, codes.Unauthenticated)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			tc.buildStubs(store)
+			server := newTestServer(t, store, nil)
+
+			ctx := tc.buildContext(t, server.tokenMaker)
+			res, err := server.ActivateTOTP(ctx, tc.req)
+			tc.checkResponse(t, res, err)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (server *Server) CancelScheduledTransfer(ctx context.Context, req *pb.CancelScheduledTransferRequest) (*pb.CancelScheduledTransferResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	violations := validateCancelScheduledTransferRequest(req)
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	scheduledTransfer, err := server.store.GetScheduledTransfer(ctx, req.GetId())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Errorf(codes.NotFound, "scheduled transfer [%d] not found", req.GetId())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get scheduled transfer: %s", err)
+	}
+
+	if !authPayload.CanAccess(scheduledTransfer.Owner) {
+		return nil, status.Errorf(codes.PermissionDenied, "scheduled transfer doesn't belong to the authenticated user")
+	}
+
+	scheduledTransfer, err = server.store.CancelScheduledTransfer(ctx, req.GetId())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Errorf(codes.FailedPrecondition, "scheduled transfer [%d] is not active", req.GetId())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to cancel scheduled transfer: %s", err)
+	}
+
+	rsp := &pb.CancelScheduledTransferResponse{
+		ScheduledTransfer: convertScheduledTransfer(scheduledTransfer),
+	}
+	return rsp, nil
+}
+
+func validateCancelScheduledTransferRequest(req *pb.CancelScheduledTransferRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateID(req.GetId()); err != nil {
+		violations = append(violations, fieldViolation("id", err))
+	}
+
+	return violations
+}
@@ -0,0 +1,118 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange"
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/ahmad-khatib0/go/simple-bank/project/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (server *Server) CreateScheduledTransfer(ctx context.Context, req *pb.CreateScheduledTransferRequest) (*pb.CreateScheduledTransferResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	violations := validateCreateScheduledTransferRequest(req)
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	fromAccount, err := server.validAccount(ctx, req.GetFromAccountId(), req.GetCurrency())
+	if err != nil {
+		return nil, err
+	}
+
+	if fromAccount.Owner != authPayload.Username {
+		return nil, status.Errorf(codes.PermissionDenied, "from account doesn't belong to the authenticated user")
+	}
+
+	toAccount, err := server.getAccount(ctx, req.GetToAccountId())
+	if err != nil {
+		return nil, err
+	}
+
+	// the transfer is converted when it runs, but there has to be a rate for it now
+	if toAccount.Currency != fromAccount.Currency {
+		_, _, err := exchange.Convert(ctx, server.rates, fromAccount.Currency, toAccount.Currency, req.GetAmount())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "cannot convert %s to %s: %s", fromAccount.Currency, toAccount.Currency, err)
+		}
+	}
+
+	nextRunAt := req.GetRunAt().AsTime()
+	if req.GetSchedule() != "" {
+		start := time.Now()
+		if req.GetRunAt() != nil {
+			start = req.GetRunAt().AsTime()
+		}
+
+		// the schedule is validated already
+		nextRunAt, _ = util.NextRun(req.GetSchedule(), start)
+	}
+
+	arg := db.CreateScheduledTransferParams{
+		Owner:         authPayload.Username,
+		FromAccountID: req.GetFromAccountId(),
+		ToAccountID:   req.GetToAccountId(),
+		Amount:        req.GetAmount(),
+		Schedule:      req.GetSchedule(),
+		NextRunAt:     sql.NullTime{Time: nextRunAt, Valid: true},
+	}
+
+	scheduledTransfer, err := server.store.CreateScheduledTransfer(ctx, arg)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create scheduled transfer: %s", err)
+	}
+
+	rsp := &pb.CreateScheduledTransferResponse{
+		ScheduledTransfer: convertScheduledTransfer(scheduledTransfer),
+	}
+	return rsp, nil
+}
+
+func validateCreateScheduledTransferRequest(req *pb.CreateScheduledTransferRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateID(req.GetFromAccountId()); err != nil {
+		violations = append(violations, fieldViolation("from_account_id", err))
+	}
+
+	if err := val.ValidateID(req.GetToAccountId()); err != nil {
+		violations = append(violations, fieldViolation("to_account_id", err))
+	} else if req.GetToAccountId() == req.GetFromAccountId() {
+		violations = append(violations, fieldViolation("to_account_id", fmt.Errorf("must be different from from_account_id")))
+	}
+
+	if err := val.ValidateAmount(req.GetAmount()); err != nil {
+		violations = append(violations, fieldViolation("amount", err))
+	}
+
+	if err := val.ValidateCurrency(req.GetCurrency()); err != nil {
+		violations = append(violations, fieldViolation("currency", err))
+	}
+
+	// a one-off transfer needs the time to run at, a recurring one may have it to start from
+	if req.GetSchedule() == "" || req.GetRunAt() != nil {
+		if err := val.ValidateTimestamp(req.GetRunAt()); err != nil {
+			violations = append(violations, fieldViolation("run_at", err))
+		} else if !req.GetRunAt().AsTime().After(time.Now()) {
+			violations = append(violations, fieldViolation("run_at", fmt.Errorf("must be in the future")))
+		}
+	}
+
+	if req.GetSchedule() != "" {
+		if err := val.ValidateSchedule(req.GetSchedule()); err != nil {
+			violations = append(violations, fieldViolation("schedule", err))
+		}
+	}
+
+	return violations
+}
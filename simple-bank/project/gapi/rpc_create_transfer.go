@@ -0,0 +1,120 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange"
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (server *Server) CreateTransfer(ctx context.Context, req *pb.CreateTransferRequest) (*pb.CreateTransferResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	idempotencyKey := extractIdempotencyKey(ctx)
+
+	violations := validateCreateTransferRequest(req)
+	if idempotencyKey != "" {
+		if err := val.ValidateIdempotencyKey(idempotencyKey); err != nil {
+			violations = append(violations, fieldViolation(IdempotencyKeyHeader, err))
+		}
+	}
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	fromAccount, err := server.validAccount(ctx, req.GetFromAccountId(), req.GetCurrency())
+	if err != nil {
+		return nil, err
+	}
+
+	if fromAccount.Owner != authPayload.Username {
+		return nil, status.Errorf(codes.PermissionDenied, "from account doesn't belong to the authenticated user")
+	}
+
+	toAccount, err := server.getAccount(ctx, req.GetToAccountId())
+	if err != nil {
+		return nil, err
+	}
+
+	arg := db.TransferTxParams{
+		FromAccountID: req.GetFromAccountId(),
+		ToAccountID:   req.GetToAccountId(),
+		Amount:        req.GetAmount(),
+	}
+
+	// the amount is in the currency of the from-account, the to-account gets it converted to its own
+	if toAccount.Currency != fromAccount.Currency {
+		toAmount, rate, err := exchange.Convert(ctx, server.rates, fromAccount.Currency, toAccount.Currency, req.GetAmount())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "cannot convert %s to %s: %s", fromAccount.Currency, toAccount.Currency, err)
+		}
+
+		arg.ToAmount = toAmount
+		arg.ExchangeRate = rate.String()
+	}
+
+	if idempotencyKey != "" {
+		arg.Username = authPayload.Username
+		arg.IdempotencyKey = idempotencyKey
+		arg.IdempotencyKeyDuration = server.config.IdempotencyKeyDuration
+	}
+
+	result, err := server.store.TransferTx(ctx, arg)
+	if err != nil {
+		if transferRejected(err) {
+			return nil, status.Errorf(codes.FailedPrecondition, "transfer rejected: %s", err)
+		}
+		if errors.Is(err, db.ErrIdempotencyKeyConflict) {
+			return nil, status.Errorf(codes.AlreadyExists, "%s", err)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to create transfer: %s", err)
+	}
+
+	rsp := &pb.CreateTransferResponse{
+		Transfer:    convertTransfer(result.Transfer),
+		FromAccount: convertAccount(result.FromAccount),
+		ToAccount:   convertAccount(result.ToAccount),
+		FromEntry:   convertEntry(result.FromEntry),
+		ToEntry:     convertEntry(result.ToEntry),
+	}
+	return rsp, nil
+}
+
+// transferRejected reports whether TransferTx rejected the transfer because the from-account can't cover it.
+func transferRejected(err error) bool {
+	return errors.Is(err, db.ErrInsufficientFunds) ||
+		errors.Is(err, db.ErrTransferLimitExceeded) ||
+		errors.Is(err, db.ErrDailyLimitExceeded)
+}
+
+func validateCreateTransferRequest(req *pb.CreateTransferRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateID(req.GetFromAccountId()); err != nil {
+		violations = append(violations, fieldViolation("from_account_id", err))
+	}
+
+	if err := val.ValidateID(req.GetToAccountId()); err != nil {
+		violations = append(violations, fieldViolation("to_account_id", err))
+	} else if req.GetToAccountId() == req.GetFromAccountId() {
+		violations = append(violations, fieldViolation("to_account_id", fmt.Errorf("must be different from from_account_id")))
+	}
+
+	if err := val.ValidateAmount(req.GetAmount()); err != nil {
+		violations = append(violations, fieldViolation("amount", err))
+	}
+
+	if err := val.ValidateCurrency(req.GetCurrency()); err != nil {
+		violations = append(violations, fieldViolation("currency", err))
+	}
+
+	return violations
+}
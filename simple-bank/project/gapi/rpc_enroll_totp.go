@@ -0,0 +1,76 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/otp"
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/ahmad-khatib0/go/simple-bank/project/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// totpIssuer names the bank in authenticator apps.
+const totpIssuer = "Simple Bank"
+
+// EnrollTOTP generates a new TOTP secret for the authenticated user, who confirms it with their password.
+// It's only used for logins once ActivateTOTP checked a code of it, so enrolling again replaces a secret
+// that wasn't activated.
+func (server *Server) EnrollTOTP(ctx context.Context, req *pb.EnrollTOTPRequest) (*pb.EnrollTOTPResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	violations := validateEnrollTOTPRequest(req)
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Errorf(codes.NotFound, "user not found")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to find user: %s", err)
+	}
+
+	err = util.CheckPassword(req.GetPassword(), user.HashedPassword)
+	if err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, "incorrect password")
+	}
+
+	secret, err := otp.GenerateSecret()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%s", err)
+	}
+
+	user, err = server.store.SetTOTPSecret(ctx, db.SetTOTPSecretParams{
+		Username:   authPayload.Username,
+		TotpSecret: secret,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Errorf(codes.FailedPrecondition, "two-factor authentication is enabled already")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to set TOTP secret: %s", err)
+	}
+
+	rsp := &pb.EnrollTOTPResponse{
+		Secret:          user.TotpSecret,
+		ProvisioningUri: otp.ProvisioningURI(totpIssuer, user.Username, user.TotpSecret),
+	}
+	return rsp, nil
+}
+
+func validateEnrollTOTPRequest(req *pb.EnrollTOTPRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidatePassword(req.GetPassword()); err != nil {
+		violations = append(violations, fieldViolation("password", err))
+	}
+
+	return violations
+}
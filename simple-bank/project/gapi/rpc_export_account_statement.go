@@ -0,0 +1,46 @@
+package gapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/statement"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ExportAccountStatement returns the statement as a file, which the gateway serves as the body of the response.
+func (server *Server) ExportAccountStatement(ctx context.Context, req *pb.ExportAccountStatementRequest) (*httpbody.HttpBody, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	violations := validateStatementRequest(req.GetAccountId(), req.GetFromTime(), req.GetToTime())
+	if !statement.IsSupportedFormat(req.GetFormat()) {
+		err := fmt.Errorf("must be one of %s, %s or %s", statement.FormatCSV, statement.FormatText, statement.FormatJSON)
+		violations = append(violations, fieldViolation("format", err))
+	}
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	account, result, err := server.getAccountStatement(ctx, authPayload, req.GetAccountId(), req.GetFromTime(), req.GetToTime())
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := statement.Write(&buf, req.GetFormat(), account, result); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to export account statement: %s", err)
+	}
+
+	rsp := &httpbody.HttpBody{
+		ContentType: statement.ContentType(req.GetFormat()),
+		Data:        buf.Bytes(),
+	}
+	return rsp, nil
+}
@@ -0,0 +1,38 @@
+package gapi
+
+import (
+	"context"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (server *Server) GetAccountStatement(ctx context.Context, req *pb.GetAccountStatementRequest) (*pb.GetAccountStatementResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	violations := validateStatementRequest(req.GetAccountId(), req.GetFromTime(), req.GetToTime())
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	account, result, err := server.getAccountStatement(ctx, authPayload, req.GetAccountId(), req.GetFromTime(), req.GetToTime())
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := &pb.GetAccountStatementResponse{
+		Account:        convertAccount(account),
+		FromTime:       timestamppb.New(result.FromTime),
+		ToTime:         timestamppb.New(result.ToTime),
+		OpeningBalance: result.OpeningBalance,
+		ClosingBalance: result.ClosingBalance,
+		Entries:        make([]*pb.StatementEntry, len(result.Entries)),
+	}
+	for i, entry := range result.Entries {
+		rsp.Entries[i] = convertStatementEntry(entry)
+	}
+	return rsp, nil
+}
@@ -0,0 +1,242 @@
+package gapi
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/ahmad-khatib0/go/simple-bank/project/db/mock"
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/token"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestGetAccountStatementAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	fromTime := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	toTime := fromTime.AddDate(0, 1, 0)
+	result := randomStatement(account, fromTime, toTime)
+
+	requireCode := func(t *testing.T, err error, code codes.Code) {
+		require.Error(t, err)
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, code, st.Code())
+	}
+
+	testCases := []struct {
+		name          string
+		req           *pb.GetAccountStatementRequest
+		buildStubs    func(store *mockdb.MockStore)
+		buildContext  func(t *testing.T, tokenMaker token.Maker) context.Context
+		checkResponse func(t *testing.T, res *pb.GetAccountStatementResponse, err error)
+	}{
+		{
+			name: "OK",
+			req: &pb.GetAccountStatementRequest{
+				AccountId: account.ID,
+				FromTime:  timestamppb.New(fromTime),
+				ToTime:    timestamppb.New(toTime),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+
+				arg := db.AccountStatementTxParams{
+					AccountID: account.ID,
+					FromTime:  fromTime,
+					ToTime:    toTime,
+				}
+				store.EXPECT().AccountStatementTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(result, nil)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, user.Username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.GetAccountStatementResponse, err error) {
+				require.NoError(t, err)
+				require.Equal(t, account.ID, res.GetAccount().GetId())
+				require.Equal(t, result.OpeningBalance, res.GetOpeningBalance())
+				require.Equal(t, result.ClosingBalance, res.GetClosingBalance())
+				require.Len(t, res.GetEntries(), len(result.Entries))
+				for i, entry := range res.GetEntries() {
+					require.Equal(t, result.Entries[i].ID, entry.GetEntry().GetId())
+					require.Equal(t, result.Entries[i].Balance, entry.GetBalance())
+				}
+			},
+		},
+		{
+			name: "OtherUsersAccount",
+			req: &pb.GetAccountStatementRequest{
+				AccountId: account.ID,
+				FromTime:  timestamppb.New(fromTime),
+				ToTime:    timestamppb.New(toTime),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().AccountStatementTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, "unauthorized_user", util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.GetAccountStatementResponse, err error) {
+				requireCode(t, err, codes.PermissionDenied)
+			},
+		},
+		{
+			name: "MissingFromTime",
+			req: &pb.GetAccountStatementRequest{
+				AccountId: account.ID,
+				ToTime:    timestamppb.New(toTime),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AccountStatementTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, user.Username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.GetAccountStatementResponse, err error) {
+				requireCode(t, err, codes.InvalidArgument)
+			},
+		},
+		{
+			name: "ToTimeBeforeFromTime",
+			req: &pb.GetAccountStatementRequest{
+				AccountId: account.ID,
+				FromTime:  timestamppb.New(toTime),
+				ToTime:    timestamppb.New(fromTime),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AccountStatementTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, user.Username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.GetAccountStatementResponse, err error) {
+				requireCode(t, err, codes.InvalidArgument)
+			},
+		},
+		{
+			name: "PeriodTooLong",
+			req: &pb.GetAccountStatementRequest{
+				AccountId: account.ID,
+				FromTime:  timestamppb.New(fromTime),
+				ToTime:    timestamppb.New(fromTime.AddDate(2, 0, 0)),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AccountStatementTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, user.Username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.GetAccountStatementResponse, err error) {
+				requireCode(t, err, codes.InvalidArgument)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			req: &pb.GetAccountStatementRequest{
+				AccountId: account.ID,
+				FromTime:  timestamppb.New(fromTime),
+				ToTime:    timestamppb.New(toTime),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().AccountStatementTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return context.Background()
+			},
+			checkResponse: func(t *testing.T, res *pb.GetAccountStatementResponse, err error) {
+				requireCode(t, err, codes.Unauthenticated)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			storeCtrl := gomock.NewController(t)
+			defer storeCtrl.Finish()
+			store := mockdb.NewMockStore(storeCtrl)
+
+			tc.buildStubs(store)
+			server := newTestServer(t, store, nil)
+
+			ctx := tc.buildContext(t, server.tokenMaker)
+			res, err := server.GetAccountStatement(ctx, tc.req)
+			tc.checkResponse(t, res, err)
+		})
+	}
+}
+
+func TestExportAccountStatementAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	fromTime := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	toTime := fromTime.AddDate(0, 1, 0)
+	result := randomStatement(account, fromTime, toTime)
+
+	storeCtrl := gomock.NewController(t)
+	defer storeCtrl.Finish()
+	store := mockdb.NewMockStore(storeCtrl)
+
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+	store.EXPECT().AccountStatementTx(gomock.Any(), gomock.Any()).Times(1).Return(result, nil)
+
+	server := newTestServer(t, store, nil)
+	ctx := newContextWithBearerToken(t, server.tokenMaker, user.Username, util.DepositorRole, time.Minute)
+
+	res, err := server.ExportAccountStatement(ctx, &pb.ExportAccountStatementRequest{
+		AccountId: account.ID,
+		FromTime:  timestamppb.New(fromTime),
+		ToTime:    timestamppb.New(toTime),
+		Format:    "csv",
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(res.GetContentType(), "text/csv"))
+
+	lines := strings.Split(strings.TrimSpace(string(res.GetData())), "\n")
+	require.Len(t, lines, len(result.Entries)+3) // header, opening and closing
+
+	_, err = server.ExportAccountStatement(ctx, &pb.ExportAccountStatementRequest{
+		AccountId: account.ID,
+		FromTime:  timestamppb.New(fromTime),
+		ToTime:    timestamppb.New(toTime),
+		Format:    "pdf",
+	})
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+}
+
+func randomStatement(account db.Account, fromTime time.Time, toTime time.Time) db.AccountStatementTxResult {
+	result := db.AccountStatementTxResult{
+		AccountID:      account.ID,
+		FromTime:       fromTime,
+		ToTime:         toTime,
+		OpeningBalance: account.Balance,
+	}
+
+	balance := account.Balance
+	for i := int64(1); i <= 3; i++ {
+		entry := db.Entry{ID: i, AccountID: account.ID, Amount: -i, CreatedAt: fromTime.Add(time.Duration(i) * time.Hour)}
+		balance += entry.Amount
+		result.Entries = append(result.Entries, db.StatementEntry{Entry: entry, Balance: balance})
+	}
+	result.ClosingBalance = balance
+
+	return result
+}
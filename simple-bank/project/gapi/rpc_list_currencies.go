@@ -0,0 +1,26 @@
+package gapi
+
+import (
+	"context"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListCurrencies lists the currencies accounts can be opened in. It needs no login, so clients can
+// show them before the user signs up.
+func (server *Server) ListCurrencies(ctx context.Context, req *pb.ListCurrenciesRequest) (*pb.ListCurrenciesResponse, error) {
+	currencies, err := server.store.ListCurrencies(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list currencies: %s", err)
+	}
+
+	rsp := &pb.ListCurrenciesResponse{
+		Currencies: make([]*pb.Currency, len(currencies)),
+	}
+	for i, currency := range currencies {
+		rsp.Currencies[i] = convertCurrency(currency)
+	}
+	return rsp, nil
+}
@@ -3,11 +3,13 @@ package gapi
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
 	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
 	"github.com/ahmad-khatib0/go/simple-bank/project/util"
 	"github.com/ahmad-khatib0/go/simple-bank/project/val"
+	"github.com/google/uuid"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -33,8 +35,42 @@ func (server *Server) LoginUser(ctx context.Context, req *pb.LoginUserRequest) (
 		return nil, status.Errorf(codes.NotFound, "incorrect password")
 	}
 
+	if user.IsTotpEnabled {
+		if user.SecondFactorLockedUntil.After(time.Now()) {
+			return nil, status.Errorf(codes.ResourceExhausted, "too many incorrect codes, try again after %s",
+				user.SecondFactorLockedUntil.UTC().Format(time.RFC3339))
+		}
+		return server.createLoginChallenge(ctx, user)
+	}
+
+	return server.createLoginSession(ctx, user)
+}
+
+// createLoginChallenge starts the second step of the login of a user with two-factor authentication.
+// The user isn't returned, as the password alone doesn't authenticate them.
+func (server *Server) createLoginChallenge(ctx context.Context, user db.User) (*pb.LoginUserResponse, error) {
+	challenge, err := server.store.CreateLoginChallenge(ctx, db.CreateLoginChallengeParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(server.config.PreAuthTokenDuration),
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create login challenge")
+	}
+
+	rsp := &pb.LoginUserResponse{
+		TwoFactorRequired:     true,
+		PreAuthToken:          challenge.ID.String(),
+		PreAuthTokenExpiresAt: timestamppb.New(challenge.ExpiresAt),
+	}
+	return rsp, nil
+}
+
+// createLoginSession issues the access and refresh tokens of a logged in user.
+func (server *Server) createLoginSession(ctx context.Context, user db.User) (*pb.LoginUserResponse, error) {
 	accessToken, accessPayload, err := server.tokenMaker.CreateToken(
 		user.Username,
+		user.Role,
 		server.config.AccessTokenDuration,
 	)
 	if err != nil {
@@ -43,6 +79,7 @@ func (server *Server) LoginUser(ctx context.Context, req *pb.LoginUserRequest) (
 
 	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(
 		user.Username,
+		user.Role,
 		server.config.RefreshTokenDuration,
 	)
 	if err != nil {
@@ -52,6 +89,7 @@ func (server *Server) LoginUser(ctx context.Context, req *pb.LoginUserRequest) (
 	mtdt := server.extractMetadata(ctx)
 	session, err := server.store.CreateSession(ctx, db.CreateSessionParams{
 		ID:           refreshPayload.ID,
+		FamilyID:     refreshPayload.ID,
 		Username:     user.Username,
 		RefreshToken: refreshToken,
 		UserAgent:    mtdt.UserAgent,
@@ -0,0 +1,205 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	mockdb "github.com/ahmad-khatib0/go/simple-bank/project/db/mock"
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/token"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRenewAccessTokenAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	requireCode := func(t *testing.T, err error, code codes.Code) {
+		require.Error(t, err)
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, code, st.Code())
+	}
+
+	// newSession logs the user in, returning the refresh token and its session
+	newSession := func(t *testing.T, tokenMaker token.Maker) (string, db.Session) {
+		refreshToken, payload, err := tokenMaker.CreateToken(user.Username, user.Role, time.Hour)
+		require.NoError(t, err)
+
+		return refreshToken, db.Session{
+			ID:           payload.ID,
+			FamilyID:     payload.ID,
+			Username:     user.Username,
+			RefreshToken: refreshToken,
+			ExpiresAt:    payload.ExpiredAt,
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore, session db.Session)
+		checkResponse func(t *testing.T, tokenMaker token.Maker, session db.Session, res *pb.RenewAccessTokenResponse, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.RotateSessionTxParams) (db.RotateSessionTxResult, error) {
+						require.Equal(t, session.ID, arg.ID)
+						require.NotEqual(t, session.ID, arg.NewSession.ID)
+						require.NotEqual(t, session.RefreshToken, arg.NewSession.RefreshToken)
+						require.WithinDuration(t, session.ExpiresAt, arg.NewSession.ExpiresAt, time.Second)
+
+						newSession := db.Session{
+							ID:           arg.NewSession.ID,
+							FamilyID:     session.FamilyID,
+							Username:     arg.NewSession.Username,
+							RefreshToken: arg.NewSession.RefreshToken,
+							ExpiresAt:    arg.NewSession.ExpiresAt,
+						}
+						return db.RotateSessionTxResult{RotatedSession: session, Session: newSession}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, session db.Session, res *pb.RenewAccessTokenResponse, err error) {
+				require.NoError(t, err)
+				require.NotEmpty(t, res.GetAccessToken())
+				require.NotEmpty(t, res.GetRefreshToken())
+				require.NotEqual(t, session.ID.String(), res.GetSessionId())
+			},
+		},
+		{
+			name: "RefreshTokenReused",
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				session.IsRotated = true
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RotateSessionTxResult{}, db.ErrRefreshTokenReused)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, session db.Session, res *pb.RenewAccessTokenResponse, err error) {
+				requireCode(t, err, codes.Unauthenticated)
+			},
+		},
+		{
+			name: "RoleChanged",
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				promoted := user
+				promoted.Role = util.BankerRole
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(promoted, nil)
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.RotateSessionTxParams) (db.RotateSessionTxResult, error) {
+						return db.RotateSessionTxResult{RotatedSession: session, Session: db.Session{ID: arg.NewSession.ID}}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, session db.Session, res *pb.RenewAccessTokenResponse, err error) {
+				require.NoError(t, err)
+
+				payload, err := tokenMaker.VerifyToken(res.GetAccessToken())
+				require.NoError(t, err)
+				require.Equal(t, util.BankerRole, payload.Role)
+			},
+		},
+		{
+			name: "UserNotFound",
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, session db.Session, res *pb.RenewAccessTokenResponse, err error) {
+				requireCode(t, err, codes.Unauthenticated)
+			},
+		},
+		{
+			name: "BlockedSession",
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				session.IsBlocked = true
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, session db.Session, res *pb.RenewAccessTokenResponse, err error) {
+				requireCode(t, err, codes.Unauthenticated)
+			},
+		},
+		{
+			name: "MismatchedToken",
+			buildStubs: func(store *mockdb.MockStore, session db.Session) {
+				session.RefreshToken = util.RandomString(32)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, tokenMaker token.Maker, session db.Session, res *pb.RenewAccessTokenResponse, err error) {
+				requireCode(t, err, codes.Unauthenticated)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			storeCtrl := gomock.NewController(t)
+			defer storeCtrl.Finish()
+			store := mockdb.NewMockStore(storeCtrl)
+
+			server := newTestServer(t, store, nil)
+			refreshToken, session := newSession(t, server.tokenMaker)
+			tc.buildStubs(store, session)
+
+			req := &pb.RenewAccessTokenRequest{RefreshToken: refreshToken}
+			res, err := server.RenewAccessToken(context.Background(), req)
+			tc.checkResponse(t, server.tokenMaker, session, res, err)
+		})
+	}
+}
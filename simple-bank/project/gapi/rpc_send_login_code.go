@@ -0,0 +1,62 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/val"
+	"github.com/ahmad-khatib0/go/simple-bank/project/worker"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxLoginCodesSent is the number of login codes that can be emailed for a pre-auth token.
+const maxLoginCodesSent = 3
+
+// SendLoginCode emails a one-time code for the pre-auth token, for users who can't use their
+// authenticator app. Each code sent replaces the one before it.
+func (server *Server) SendLoginCode(ctx context.Context, req *pb.SendLoginCodeRequest) (*pb.SendLoginCodeResponse, error) {
+	violations := validateSendLoginCodeRequest(req)
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	challenge, err := server.store.CountLoginCodeSent(ctx, db.CountLoginCodeSentParams{
+		ID:      uuid.MustParse(req.GetPreAuthToken()),
+		MaxSent: maxLoginCodesSent,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid or expired pre-auth token, or too many codes sent")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to count login code sent: %s", err)
+	}
+
+	taskPayload := &worker.PayloadSendLoginCode{
+		ChallengeID: challenge.ID,
+	}
+	opts := []asynq.Option{
+		asynq.MaxRetry(3),
+		asynq.Queue(worker.QueueCritical),
+	}
+
+	err = server.taskDistributor.DistributeTaskSendLoginCode(ctx, taskPayload, opts...)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to distribute task to send login code: %s", err)
+	}
+
+	return &pb.SendLoginCodeResponse{}, nil
+}
+
+func validateSendLoginCodeRequest(req *pb.SendLoginCodeRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidatePreAuthToken(req.GetPreAuthToken()); err != nil {
+		violations = append(violations, fieldViolation("pre_auth_token", err))
+	}
+
+	return violations
+}
@@ -0,0 +1,72 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (server *Server) UpdateAccountLimits(ctx context.Context, req *pb.UpdateAccountLimitsRequest) (*pb.UpdateAccountLimitsResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	violations := validateUpdateAccountLimitsRequest(req)
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	_, err = server.getAuthorizedAccount(ctx, authPayload, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	arg := db.UpdateAccountLimitsParams{
+		ID: req.GetId(),
+		TransferLimit: sql.NullInt64{
+			Int64: req.GetTransferLimit(),
+			Valid: req.TransferLimit != nil,
+		},
+		DailyTransferLimit: sql.NullInt64{
+			Int64: req.GetDailyTransferLimit(),
+			Valid: req.DailyTransferLimit != nil,
+		},
+	}
+
+	account, err := server.store.UpdateAccountLimits(ctx, arg)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to update account limits: %s", err)
+	}
+
+	rsp := &pb.UpdateAccountLimitsResponse{
+		Account: convertAccount(account),
+	}
+	return rsp, nil
+}
+
+func validateUpdateAccountLimitsRequest(req *pb.UpdateAccountLimitsRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateID(req.GetId()); err != nil {
+		violations = append(violations, fieldViolation("id", err))
+	}
+
+	if req.TransferLimit != nil {
+		if err := val.ValidateLimit(req.GetTransferLimit()); err != nil {
+			violations = append(violations, fieldViolation("transfer_limit", err))
+		}
+	}
+
+	if req.DailyTransferLimit != nil {
+		if err := val.ValidateLimit(req.GetDailyTransferLimit()); err != nil {
+			violations = append(violations, fieldViolation("daily_transfer_limit", err))
+		}
+	}
+
+	return violations
+}
@@ -25,7 +25,7 @@ func (server *Server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest)
 		return nil, invalidArgumentError(violations)
 	}
 
-	if authPayload.Username != req.GetUsername() {
+	if !authPayload.CanAccess(req.GetUsername()) {
 		return nil, status.Errorf(codes.PermissionDenied, "cannot update other user's info")
 	}
 
@@ -0,0 +1,144 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/otp"
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/ahmad-khatib0/go/simple-bank/project/val"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxLoginAttempts is the number of codes a pre-auth token can be tried with, before the user has to log in again.
+const maxLoginAttempts = 5
+
+// maxSecondFactorFailures is the number of incorrect codes in a row, across pre-auth tokens, that lock the
+// second factor of the user for secondFactorLockDuration, so that logging in again doesn't give more guesses.
+const (
+	maxSecondFactorFailures  = 10
+	secondFactorLockDuration = 15 * time.Minute
+)
+
+// VerifyLogin exchanges the pre-auth token of a login with two-factor authentication, and a code of
+// the authenticator app, the code sent by email, or a recovery code, for the access and refresh tokens.
+func (server *Server) VerifyLogin(ctx context.Context, req *pb.VerifyLoginRequest) (*pb.LoginUserResponse, error) {
+	violations := validateVerifyLoginRequest(req)
+	if violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	challenge, err := server.store.AttemptLoginChallenge(ctx, db.AttemptLoginChallengeParams{
+		ID:          uuid.MustParse(req.GetPreAuthToken()),
+		MaxAttempts: maxLoginAttempts,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid or expired pre-auth token, or too many incorrect codes")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to attempt login challenge: %s", err)
+	}
+
+	user, err := server.store.GetUser(ctx, challenge.Username)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to find user: %s", err)
+	}
+
+	ok, err := server.checkLoginCode(ctx, user, challenge, req.GetCode())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to check code: %s", err)
+	}
+	if !ok {
+		_, err = server.store.RecordFailedSecondFactor(ctx, db.RecordFailedSecondFactorParams{
+			Username:    user.Username,
+			MaxAttempts: maxSecondFactorFailures,
+			LockedUntil: time.Now().Add(secondFactorLockDuration),
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to record failed second factor: %s", err)
+		}
+		return nil, status.Errorf(codes.Unauthenticated, "incorrect code")
+	}
+
+	_, err = server.store.UseLoginChallenge(ctx, challenge.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Errorf(codes.Unauthenticated, "pre-auth token used already")
+		}
+		return nil, status.Errorf(codes.Internal, "failed to use login challenge: %s", err)
+	}
+
+	if user.FailedSecondFactorAttempts > 0 {
+		err = server.store.ResetFailedSecondFactor(ctx, user.Username)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to reset failed second factor: %s", err)
+		}
+	}
+
+	return server.createLoginSession(ctx, user)
+}
+
+// checkLoginCode checks the code against the user's authenticator app, the code emailed for the
+// challenge, and the user's unused recovery codes. An accepted TOTP code or recovery code is
+// recorded, so that it can't log in again.
+func (server *Server) checkLoginCode(ctx context.Context, user db.User, challenge db.LoginChallenge, code string) (bool, error) {
+	if user.IsTotpEnabled {
+		if step, ok := otp.ValidateCode(user.TotpSecret, code, time.Now()); ok {
+			_, err := server.store.UpdateTOTPLastStep(ctx, db.UpdateTOTPLastStepParams{
+				Username:     user.Username,
+				TotpLastStep: step,
+			})
+			if err == sql.ErrNoRows {
+				return false, nil
+			}
+			return err == nil, err
+		}
+	}
+
+	if challenge.HashedEmailCode != "" && util.CheckPassword(code, challenge.HashedEmailCode) == nil {
+		return true, nil
+	}
+
+	if val.ValidateTOTPCode(code) == nil {
+		return false, nil
+	}
+
+	recoveryCodes, err := server.store.ListUnusedRecoveryCodes(ctx, user.Username)
+	if err != nil {
+		return false, err
+	}
+
+	code = strings.ToLower(code)
+	for _, recoveryCode := range recoveryCodes {
+		if util.CheckPassword(code, recoveryCode.HashedCode) != nil {
+			continue
+		}
+
+		_, err := server.store.UseRecoveryCode(ctx, recoveryCode.ID)
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	return false, nil
+}
+
+func validateVerifyLoginRequest(req *pb.VerifyLoginRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidatePreAuthToken(req.GetPreAuthToken()); err != nil {
+		violations = append(violations, fieldViolation("pre_auth_token", err))
+	}
+
+	if err := val.ValidateLoginCode(req.GetCode()); err != nil {
+		violations = append(violations, fieldViolation("code", err))
+	}
+
+	return violations
+}
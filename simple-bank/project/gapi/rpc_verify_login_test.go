@@ -0,0 +1,347 @@
+package gapi
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	mockdb "github.com/ahmad-khatib0/go/simple-bank/project/db/mock"
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/otp"
+	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func randomTOTPUser(t *testing.T) (user db.User, password string) {
+	user, password = randomUser(t)
+
+	secret, err := otp.GenerateSecret()
+	require.NoError(t, err)
+
+	user.TotpSecret = secret
+	user.IsTotpEnabled = true
+	return
+}
+
+func TestLoginUserTwoFactorAPI(t *testing.T) {
+	user, password := randomTOTPUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+
+	var challenge db.LoginChallenge
+	store.EXPECT().
+		CreateLoginChallenge(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateLoginChallengeParams) (db.LoginChallenge, error) {
+			require.Equal(t, user.Username, arg.Username)
+			require.WithinDuration(t, time.Now().Add(time.Minute), arg.ExpiresAt, time.Second)
+			challenge = db.LoginChallenge{ID: arg.ID, Username: arg.Username, ExpiresAt: arg.ExpiresAt}
+			return challenge, nil
+		})
+	store.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	server := newTestServer(t, store, nil)
+	res, err := server.LoginUser(context.Background(), &pb.LoginUserRequest{
+		Username: user.Username,
+		Password: password,
+	})
+	require.NoError(t, err)
+	require.True(t, res.GetTwoFactorRequired())
+	require.Equal(t, challenge.ID.String(), res.GetPreAuthToken())
+	require.Empty(t, res.GetAccessToken())
+	require.Empty(t, res.GetRefreshToken())
+	require.Nil(t, res.GetUser())
+}
+
+func TestLoginUserSecondFactorLockedAPI(t *testing.T) {
+	user, password := randomTOTPUser(t)
+	user.SecondFactorLockedUntil = time.Now().Add(time.Minute)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		CreateLoginChallenge(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	server := newTestServer(t, store, nil)
+	_, err := server.LoginUser(context.Background(), &pb.LoginUserRequest{
+		Username: user.Username,
+		Password: password,
+	})
+	require.Error(t, err)
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.ResourceExhausted, st.Code())
+}
+
+func TestVerifyLoginAPI(t *testing.T) {
+	user, _ := randomTOTPUser(t)
+
+	emailCode := "123456"
+	hashedEmailCode, err := util.HashPassword(emailCode)
+	require.NoError(t, err)
+	challenge := db.LoginChallenge{
+		ID:              uuid.New(),
+		Username:        user.Username,
+		HashedEmailCode: hashedEmailCode,
+		Attempts:        1,
+		ExpiresAt:       time.Now().Add(time.Minute),
+	}
+
+	totpCode, err := otp.GenerateCode(user.TotpSecret, time.Now())
+	require.NoError(t, err)
+
+	recoveryCode, err := otp.GenerateRecoveryCode()
+	require.NoError(t, err)
+	hashedRecoveryCode, err := util.HashPassword(recoveryCode)
+	require.NoError(t, err)
+	recoveryCodes := []db.RecoveryCode{
+		{ID: 1, Username: user.Username, HashedCode: hashedRecoveryCode},
+	}
+
+	requireCode := func(t *testing.T, err error, code codes.Code) {
+		require.Error(t, err)
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, code, st.Code())
+	}
+
+	requireLoggedIn := func(t *testing.T, res *pb.LoginUserResponse, err error) {
+		require.NoError(t, err)
+		require.False(t, res.GetTwoFactorRequired())
+		require.NotEmpty(t, res.GetAccessToken())
+		require.NotEmpty(t, res.GetRefreshToken())
+		require.Equal(t, user.Username, res.GetUser().GetUsername())
+	}
+
+	attempt := func(store *mockdb.MockStore) {
+		store.EXPECT().
+			AttemptLoginChallenge(gomock.Any(), gomock.Eq(db.AttemptLoginChallengeParams{
+				ID:          challenge.ID,
+				MaxAttempts: maxLoginAttempts,
+			})).
+			Times(1).
+			Return(challenge, nil)
+		store.EXPECT().
+			GetUser(gomock.Any(), gomock.Eq(user.Username)).
+			Times(1).
+			Return(user, nil)
+	}
+
+	fail := func(store *mockdb.MockStore) {
+		store.EXPECT().
+			RecordFailedSecondFactor(gomock.Any(), gomock.Any()).
+			Times(1).
+			DoAndReturn(func(_ context.Context, arg db.RecordFailedSecondFactorParams) (db.User, error) {
+				require.Equal(t, user.Username, arg.Username)
+				require.Equal(t, int32(maxSecondFactorFailures), arg.MaxAttempts)
+				require.WithinDuration(t, time.Now().Add(secondFactorLockDuration), arg.LockedUntil, time.Second)
+				return user, nil
+			})
+		store.EXPECT().
+			UseLoginChallenge(gomock.Any(), gomock.Any()).
+			Times(0)
+	}
+
+	login := func(store *mockdb.MockStore) {
+		store.EXPECT().
+			UseLoginChallenge(gomock.Any(), gomock.Eq(challenge.ID)).
+			Times(1).
+			Return(challenge, nil)
+		store.EXPECT().
+			CreateSession(gomock.Any(), gomock.Any()).
+			Times(1).
+			DoAndReturn(func(_ context.Context, arg db.CreateSessionParams) (db.Session, error) {
+				return db.Session{ID: arg.ID, FamilyID: arg.FamilyID, Username: arg.Username}, nil
+			})
+	}
+
+	testCases := []struct {
+		name          string
+		req           *pb.VerifyLoginRequest
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, res *pb.LoginUserResponse, err error)
+	}{
+		{
+			name: "TOTPCode",
+			req:  &pb.VerifyLoginRequest{PreAuthToken: challenge.ID.String(), Code: totpCode},
+			buildStubs: func(store *mockdb.MockStore) {
+				attempt(store)
+				store.EXPECT().
+					UpdateTOTPLastStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.UpdateTOTPLastStepParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.InDelta(t, otp.Step(time.Now()), arg.TotpLastStep, otp.Skew)
+						return user, nil
+					})
+				login(store)
+			},
+			checkResponse: requireLoggedIn,
+		},
+		{
+			name: "ReplayedTOTPCode",
+			req:  &pb.VerifyLoginRequest{PreAuthToken: challenge.ID.String(), Code: totpCode},
+			buildStubs: func(store *mockdb.MockStore) {
+				attempt(store)
+				store.EXPECT().
+					UpdateTOTPLastStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				fail(store)
+			},
+			checkResponse: func(t *testing.T, res *pb.LoginUserResponse, err error) {
+				requireCode(t, err, codes.Unauthenticated)
+			},
+		},
+		{
+			name: "EmailCode",
+			req:  &pb.VerifyLoginRequest{PreAuthToken: challenge.ID.String(), Code: emailCode},
+			buildStubs: func(store *mockdb.MockStore) {
+				attempt(store)
+				login(store)
+			},
+			checkResponse: requireLoggedIn,
+		},
+		{
+			name: "RecoveryCode",
+			req:  &pb.VerifyLoginRequest{PreAuthToken: challenge.ID.String(), Code: recoveryCode},
+			buildStubs: func(store *mockdb.MockStore) {
+				attempt(store)
+				store.EXPECT().
+					ListUnusedRecoveryCodes(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(recoveryCodes, nil)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Eq(recoveryCodes[0].ID)).
+					Times(1).
+					Return(recoveryCodes[0], nil)
+				login(store)
+			},
+			checkResponse: requireLoggedIn,
+		},
+		{
+			name: "IncorrectRecoveryCode",
+			req:  &pb.VerifyLoginRequest{PreAuthToken: challenge.ID.String(), Code: "aaaaa-aaaaa"},
+			buildStubs: func(store *mockdb.MockStore) {
+				attempt(store)
+				store.EXPECT().
+					ListUnusedRecoveryCodes(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(recoveryCodes, nil)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Any()).
+					Times(0)
+				fail(store)
+			},
+			checkResponse: func(t *testing.T, res *pb.LoginUserResponse, err error) {
+				requireCode(t, err, codes.Unauthenticated)
+			},
+		},
+		{
+			name: "ResetFailures",
+			req:  &pb.VerifyLoginRequest{PreAuthToken: challenge.ID.String(), Code: emailCode},
+			buildStubs: func(store *mockdb.MockStore) {
+				failedUser := user
+				failedUser.FailedSecondFactorAttempts = 3
+
+				store.EXPECT().
+					AttemptLoginChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(failedUser, nil)
+				store.EXPECT().
+					ResetFailedSecondFactor(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(nil)
+				login(store)
+			},
+			checkResponse: requireLoggedIn,
+		},
+		{
+			name: "ExpiredPreAuthToken",
+			req:  &pb.VerifyLoginRequest{PreAuthToken: challenge.ID.String(), Code: emailCode},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					AttemptLoginChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.LoginChallenge{}, sql.ErrNoRows)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, res *pb.LoginUserResponse, err error) {
+				requireCode(t, err, codes.Unauthenticated)
+			},
+		},
+		{
+			name: "PreAuthTokenUsed",
+			req:  &pb.VerifyLoginRequest{PreAuthToken: challenge.ID.String(), Code: emailCode},
+			buildStubs: func(store *mockdb.MockStore) {
+				attempt(store)
+				store.EXPECT().
+					UseLoginChallenge(gomock.Any(), gomock.Eq(challenge.ID)).
+					Times(1).
+					Return(db.LoginChallenge{}, sql.ErrNoRows)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, res *pb.LoginUserResponse, err error) {
+				requireCode(t, err, codes.Unauthenticated)
+			},
+		},
+		{
+			name: "InvalidArguments",
+			req:  &pb.VerifyLoginRequest{PreAuthToken: "invalid", Code: "12"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					AttemptLoginChallenge(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, res *pb.LoginUserResponse, err error) {
+				requireCode(t, err, codes.InvalidArgument)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			tc.buildStubs(store)
+			server := newTestServer(t, store, nil)
+
+			res, err := server.VerifyLogin(context.Background(), tc.req)
+			tc.checkResponse(t, res, err)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange"
 	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
 	"github.com/ahmad-khatib0/go/simple-bank/project/token"
 	"github.com/ahmad-khatib0/go/simple-bank/project/util"
@@ -20,10 +21,11 @@ type Server struct {
 	store           db.Store
 	tokenMaker      token.Maker
 	taskDistributor worker.TaskDistributor
+	rates           exchange.ExchangeRateProvider
 }
 
 // NewServer creates a new gRPC server.
-func NewServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor) (*Server, error) {
+func NewServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor, rates exchange.ExchangeRateProvider) (*Server, error) {
 	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token maker: %w", err)
@@ -34,6 +36,7 @@ func NewServer(config util.Config, store db.Store, taskDistributor worker.TaskDi
 		store:           store,
 		tokenMaker:      tokenMaker,
 		taskDistributor: taskDistributor,
+		rates:           rates,
 	}
 
 	return server, nil
@@ -0,0 +1,67 @@
+package gapi
+
+import (
+	"context"
+	"time"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/token"
+	"github.com/ahmad-khatib0/go/simple-bank/project/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxStatementPeriod is the longest date range a statement can cover.
+const maxStatementPeriod = 366 * 24 * time.Hour
+
+// getAccountStatement returns the account and its statement if it belongs to the authenticated user.
+func (server *Server) getAccountStatement(
+	ctx context.Context,
+	authPayload *token.Payload,
+	accountID int64,
+	fromTime *timestamppb.Timestamp,
+	toTime *timestamppb.Timestamp,
+) (db.Account, db.AccountStatementTxResult, error) {
+	account, err := server.getAuthorizedAccount(ctx, authPayload, accountID)
+	if err != nil {
+		return account, db.AccountStatementTxResult{}, err
+	}
+
+	result, err := server.store.AccountStatementTx(ctx, db.AccountStatementTxParams{
+		AccountID: accountID,
+		FromTime:  fromTime.AsTime(),
+		ToTime:    toTime.AsTime(),
+	})
+	if err != nil {
+		return account, result, status.Errorf(codes.Internal, "failed to get account statement: %s", err)
+	}
+
+	return account, result, nil
+}
+
+func validateStatementRequest(
+	accountID int64,
+	fromTime *timestamppb.Timestamp,
+	toTime *timestamppb.Timestamp,
+) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateID(accountID); err != nil {
+		violations = append(violations, fieldViolation("account_id", err))
+	}
+
+	fromErr := val.ValidateTimestamp(fromTime)
+	if fromErr != nil {
+		violations = append(violations, fieldViolation("from_time", fromErr))
+	}
+
+	if err := val.ValidateTimestamp(toTime); err != nil {
+		violations = append(violations, fieldViolation("to_time", err))
+	} else if fromErr == nil {
+		if err := val.ValidateTimeRange(fromTime.AsTime(), toTime.AsTime(), maxStatementPeriod); err != nil {
+			violations = append(violations, fieldViolation("to_time", err))
+		}
+	}
+
+	return violations
+}
@@ -3,16 +3,20 @@ package main
 import (
 	"context"
 	"database/sql"
+	"expvar"
 	"net"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/ahmad-khatib0/go/simple-bank/project/api"
 	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
 	_ "github.com/ahmad-khatib0/go/simple-bank/project/doc/statik"
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange"
 	"github.com/ahmad-khatib0/go/simple-bank/project/gapi"
 	"github.com/ahmad-khatib0/go/simple-bank/project/mail"
 	"github.com/ahmad-khatib0/go/simple-bank/project/pb"
+	"github.com/ahmad-khatib0/go/simple-bank/project/reconcile"
 	"github.com/ahmad-khatib0/go/simple-bank/project/util"
 	"github.com/ahmad-khatib0/go/simple-bank/project/worker"
 	"github.com/golang-migrate/migrate/v4"
@@ -48,17 +52,31 @@ func main() {
 
 	store := db.NewStore(conn)
 
+	// "main reconcile" checks the ledger once and exits, instead of starting the servers
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		runReconciliation(store)
+		return
+	}
+
 	redisOpt := asynq.RedisClientOpt{
 		Addr: config.RedisAddress,
 	}
 
 	taskDistributor := worker.NewRedisTaskDistributor(redisOpt)
+
+	rates, err := exchange.NewFileRateProvider(config.ExchangeRatesFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot load exchange rates")
+	}
+
 	// What we want is to be able to serve both gRPC and HTTP requests at the same time.  But we can’t just call
 	// both functions in the same go routine, Since the first server will block the second one.  So here if
 	// we run the gRPC server on the main go routine, Then we have to run the HTTP gateway server on another one.
-	go runTaskProcessor(config, redisOpt, store)
-	go runGatewayServer(config, store, taskDistributor)
-	runGrpcServer(config, store, taskDistributor)
+	go runTaskProcessor(config, redisOpt, store, rates)
+	go runTaskScheduler(redisOpt)
+	go runGatewayServer(config, store, taskDistributor, rates)
+	go runMetricsServer(config)
+	runGrpcServer(config, store, taskDistributor, rates)
 }
 
 func runDBMigration(migrationURL string, dbSource string) {
@@ -74,9 +92,32 @@ func runDBMigration(migrationURL string, dbSource string) {
 	log.Info().Msg("db migrated successfully")
 }
 
-func runTaskProcessor(config util.Config, redisOpt asynq.RedisClientOpt, store db.Store) {
+// runReconciliation reconciles the ledger, and exits with status 1 if there are discrepancies.
+func runReconciliation(store db.Store) {
+	result, err := reconcile.Run(context.Background(), store)
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot reconcile ledger")
+	}
+
+	for _, discrepancy := range result.Discrepancies {
+		log.Warn().Str("kind", discrepancy.Kind).Int64("account_id", discrepancy.AccountID.Int64).
+			Int64("transfer_id", discrepancy.TransferID.Int64).Int64("expected", discrepancy.Expected).
+			Int64("actual", discrepancy.Actual).Msg(discrepancy.Details)
+	}
+
+	log.Info().Int64("run_id", result.Run.ID).
+		Int64("accounts_checked", result.Run.AccountsChecked).
+		Int64("transfers_checked", result.Run.TransfersChecked).
+		Int64("discrepancies", result.Run.Discrepancies).Msg("ledger reconciled")
+
+	if result.Run.Discrepancies > 0 {
+		os.Exit(1)
+	}
+}
+
+func runTaskProcessor(config util.Config, redisOpt asynq.RedisClientOpt, store db.Store, rates exchange.ExchangeRateProvider) {
 	mailer := mail.NewGmailSender(config.EmailSenderName, config.EmailSenderAddress, config.EmailSenderPassword)
-	taskProcessor := worker.NewRedisTaskProcessor(redisOpt, store, mailer)
+	taskProcessor := worker.NewRedisTaskProcessor(redisOpt, store, mailer, rates)
 	log.Info().Msg("start task processor")
 	err := taskProcessor.Start()
 	if err != nil {
@@ -84,8 +125,17 @@ func runTaskProcessor(config util.Config, redisOpt asynq.RedisClientOpt, store d
 	}
 }
 
-func runGrpcServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor) {
-	server, err := gapi.NewServer(config, store, taskDistributor)
+func runTaskScheduler(redisOpt asynq.RedisClientOpt) {
+	taskScheduler := worker.NewRedisTaskScheduler(redisOpt)
+	log.Info().Msg("start task scheduler")
+	err := taskScheduler.Start()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to start task scheduler")
+	}
+}
+
+func runGrpcServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor, rates exchange.ExchangeRateProvider) {
+	server, err := gapi.NewServer(config, store, taskDistributor, rates)
 	if err != nil {
 		log.Fatal().Err(err).Msg("cannot create server")
 	}
@@ -109,24 +159,57 @@ func runGrpcServer(config util.Config, store db.Store, taskDistributor worker.Ta
 	}
 }
 
+// runMetricsServer serves the metrics, like the outcome of the last ledger reconciliation, on their
+// own address: they aren't meant for the clients of the gateway, so it should be an internal one.
+func runMetricsServer(config util.Config) {
+	if config.MetricsServerAddress == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	listener, err := net.Listen("tcp", config.MetricsServerAddress)
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot create listener")
+	}
+
+	log.Info().Msgf("start metrics server at %s", listener.Addr().String())
+	err = http.Serve(listener, mux)
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot start metrics server")
+	}
+}
+
 // runGatewayServer() start the grpc-gateway plugin
-func runGatewayServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor) {
-	server, err := gapi.NewServer(config, store, taskDistributor)
+func runGatewayServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor, rates exchange.ExchangeRateProvider) {
+	server, err := gapi.NewServer(config, store, taskDistributor, rates)
 	if err != nil {
 		log.Fatal().Err(err).Msg("cannot create server")
 	}
 
 	// these options added in order to keep the body response fields as snake_case
-	jsonOption := runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
-		MarshalOptions: protojson.MarshalOptions{
-			UseProtoNames: true,
-		},
-		UnmarshalOptions: protojson.UnmarshalOptions{
-			DiscardUnknown: true,
+	// the HTTPBodyMarshaler writes the google.api.HttpBody responses, like the exported statements, as they are
+	jsonOption := runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.HTTPBodyMarshaler{
+		Marshaler: &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
 		},
 	})
 
-	grpcMux := runtime.NewServeMux(jsonOption)
+	// forward the Idempotency-Key header to the RPCs, the default matcher drops it
+	headerMatcher := runtime.WithIncomingHeaderMatcher(func(key string) (string, bool) {
+		if strings.EqualFold(key, gapi.IdempotencyKeyHeader) {
+			return gapi.IdempotencyKeyHeader, true
+		}
+		return runtime.DefaultHeaderMatcher(key)
+	})
+
+	grpcMux := runtime.NewServeMux(jsonOption, headerMatcher)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -163,8 +246,8 @@ func runGatewayServer(config util.Config, store db.Store, taskDistributor worker
 	}
 }
 
-func runGinServer(config util.Config, store db.Store) {
-	server, err := api.NewServer(config, store)
+func runGinServer(config util.Config, store db.Store, rates exchange.ExchangeRateProvider) {
+	server, err := api.NewServer(config, store, rates)
 	if err != nil {
 		log.Fatal().Err(err).Msg("cannot create server")
 	}
@@ -0,0 +1,43 @@
+package otp
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// RecoveryCodeCount is the number of recovery codes a user gets when enabling two-factor authentication.
+const RecoveryCodeCount = 10
+
+const recoveryAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+
+// GenerateRecoveryCode returns a random code like "k3f9q-x7m2p", which logs the user in once when
+// they can't get a code otherwise. The alphabet leaves out the characters that are easily confused.
+func GenerateRecoveryCode() (string, error) {
+	code := make([]byte, 0, 11)
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			code = append(code, '-')
+		}
+
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code = append(code, recoveryAlphabet[n.Int64()])
+	}
+	return string(code), nil
+}
+
+// GenerateRecoveryCodes returns RecoveryCodeCount new recovery codes.
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+	return codes, nil
+}
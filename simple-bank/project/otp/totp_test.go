@@ -0,0 +1,75 @@
+package otp
+
+import (
+	"encoding/base32"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// the SHA1 test vectors of RFC 6238, truncated to 6 digits
+func TestGenerateCodeRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		code, err := GenerateCode(secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		require.Equal(t, want, code, unix)
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	code, err := GenerateCode(secret, now)
+	require.NoError(t, err)
+
+	step, ok := ValidateCode(secret, code, now)
+	require.True(t, ok)
+	require.Equal(t, Step(now), step)
+
+	// a code is still accepted a period later, not two
+	_, ok = ValidateCode(secret, code, now.Add(Period))
+	require.True(t, ok)
+	_, ok = ValidateCode(secret, code, now.Add(3*Period))
+	require.False(t, ok)
+
+	_, ok = ValidateCode(secret, "12345", now)
+	require.False(t, ok)
+	_, ok = ValidateCode("not base32!", code, now)
+	require.False(t, ok)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Simple Bank", "alice", "JBSWY3DPEHPK3PXP")
+	require.True(t, strings.HasPrefix(uri, "otpauth://totp/Simple%20Bank:alice?"))
+	require.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	require.Contains(t, uri, "issuer=Simple+Bank")
+	require.Contains(t, uri, "digits=6")
+}
+
+func TestGenerateCodes(t *testing.T) {
+	code, err := GenerateNumericCode(6)
+	require.NoError(t, err)
+	require.Regexp(t, regexp.MustCompile(`^[0-9]{6}$`), code)
+
+	codes, err := GenerateRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodeCount)
+	for _, code := range codes {
+		require.Regexp(t, regexp.MustCompile(`^[a-z0-9]{5}-[a-z0-9]{5}$`), code)
+	}
+}
@@ -0,0 +1,230 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange"
+)
+
+// The kinds of discrepancy a reconciliation reports
+const (
+	// KindAccountBalance is an account whose balance isn't the sum of its entries
+	KindAccountBalance = "account_balance"
+	// KindTransferEntries is a transfer without exactly one entry of -amount to the from-account
+	// and one of +to_amount to the to-account
+	KindTransferEntries = "transfer_entries"
+	// KindTransferAmounts is a transfer whose two legs don't sum to zero at its exchange rate
+	KindTransferAmounts = "transfer_amounts"
+)
+
+// pageSize is the number of accounts, or transfers, checked at a time.
+const pageSize = 500
+
+// metrics exposes the outcome of the last reconciliation with the other expvars, at /debug/vars.
+var metrics = expvar.NewMap("ledger_reconciliation")
+
+// Result is the outcome of a reconciliation.
+type Result struct {
+	Run           db.ReconciliationRun
+	Discrepancies []db.LedgerDiscrepancy
+}
+
+// Run checks that every account's balance is the sum of its entries, and that every transfer has the
+// two entries it should have, which sum to zero. The discrepancies are recorded with the run, and the
+// counts of the run are published as metrics.
+func Run(ctx context.Context, store db.Store) (Result, error) {
+	var result Result
+
+	run, err := store.CreateReconciliationRun(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to create reconciliation run: %w", err)
+	}
+
+	record := func(arg db.CreateLedgerDiscrepancyParams) error {
+		arg.RunID = run.ID
+		discrepancy, err := store.CreateLedgerDiscrepancy(ctx, arg)
+		if err != nil {
+			return fmt.Errorf("failed to record ledger discrepancy: %w", err)
+		}
+		result.Discrepancies = append(result.Discrepancies, discrepancy)
+		return nil
+	}
+
+	accountsChecked, err := checkAccounts(ctx, store, record)
+	if err != nil {
+		return result, err
+	}
+
+	transfersChecked, err := checkTransfers(ctx, store, record)
+	if err != nil {
+		return result, err
+	}
+
+	result.Run, err = store.FinishReconciliationRun(ctx, db.FinishReconciliationRunParams{
+		ID:               run.ID,
+		AccountsChecked:  accountsChecked,
+		TransfersChecked: transfersChecked,
+		Discrepancies:    int64(len(result.Discrepancies)),
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to finish reconciliation run: %w", err)
+	}
+
+	publish(result.Run)
+	return result, nil
+}
+
+func checkAccounts(ctx context.Context, store db.Store, record func(db.CreateLedgerDiscrepancyParams) error) (int64, error) {
+	var afterID, checked int64
+	for {
+		accounts, err := store.ListAccountBalanceChecks(ctx, db.ListAccountBalanceChecksParams{
+			AfterID: afterID,
+			Limit:   pageSize,
+		})
+		if err != nil {
+			return checked, fmt.Errorf("failed to check account balances: %w", err)
+		}
+
+		for _, account := range accounts {
+			if account.Balance != account.EntriesTotal {
+				err := record(db.CreateLedgerDiscrepancyParams{
+					Kind:      KindAccountBalance,
+					AccountID: sql.NullInt64{Int64: account.ID, Valid: true},
+					Expected:  account.EntriesTotal,
+					Actual:    account.Balance,
+					Details:   "balance isn't the sum of the entries",
+				})
+				if err != nil {
+					return checked, err
+				}
+			}
+		}
+		checked += int64(len(accounts))
+
+		if len(accounts) < pageSize {
+			return checked, nil
+		}
+		afterID = accounts[len(accounts)-1].ID
+	}
+}
+
+func checkTransfers(ctx context.Context, store db.Store, record func(db.CreateLedgerDiscrepancyParams) error) (int64, error) {
+	var afterID, checked int64
+	for {
+		transfers, err := store.ListTransferEntryChecks(ctx, db.ListTransferEntryChecksParams{
+			AfterID: afterID,
+			Limit:   pageSize,
+		})
+		if err != nil {
+			return checked, fmt.Errorf("failed to check transfer entries: %w", err)
+		}
+
+		for _, transfer := range transfers {
+			for _, arg := range checkTransfer(transfer) {
+				arg.TransferID = sql.NullInt64{Int64: transfer.ID, Valid: true}
+				if err := record(arg); err != nil {
+					return checked, err
+				}
+			}
+		}
+		checked += int64(len(transfers))
+
+		if len(transfers) < pageSize {
+			return checked, nil
+		}
+		afterID = transfers[len(transfers)-1].ID
+	}
+}
+
+// checkTransfer returns the discrepancies of the transfer. Its amounts are only checked against its
+// exchange rate once its entries match them.
+func checkTransfer(transfer db.ListTransferEntryChecksRow) []db.CreateLedgerDiscrepancyParams {
+	if transfer.EntryCount != 2 {
+		return []db.CreateLedgerDiscrepancyParams{{
+			Kind:     KindTransferEntries,
+			Expected: 2,
+			Actual:   transfer.EntryCount,
+			Details:  "transfer doesn't have exactly two entries",
+		}}
+	}
+
+	var discrepancies []db.CreateLedgerDiscrepancyParams
+	if transfer.FromTotal != -transfer.Amount {
+		discrepancies = append(discrepancies, db.CreateLedgerDiscrepancyParams{
+			Kind:     KindTransferEntries,
+			Expected: -transfer.Amount,
+			Actual:   transfer.FromTotal,
+			Details:  "from-account entry doesn't match the amount",
+		})
+	}
+	if transfer.ToTotal != transfer.ToAmount {
+		discrepancies = append(discrepancies, db.CreateLedgerDiscrepancyParams{
+			Kind:     KindTransferEntries,
+			Expected: transfer.ToAmount,
+			Actual:   transfer.ToTotal,
+			Details:  "to-account entry doesn't match the to_amount",
+		})
+	}
+	if discrepancies != nil {
+		return discrepancies
+	}
+
+	// the legs sum to zero when the amount taken, converted at the rate recorded with the transfer,
+	// is the amount credited. Between accounts of one currency the rate must be 1, so both are equal.
+	rate, err := exchange.NewRate(transfer.FromCurrency, transfer.ToCurrency, transfer.ExchangeRate)
+	if err != nil {
+		return []db.CreateLedgerDiscrepancyParams{{
+			Kind:     KindTransferAmounts,
+			Expected: transfer.ToAmount,
+			Actual:   transfer.ToAmount,
+			Details:  err.Error(),
+		}}
+	}
+
+	if transfer.FromCurrency == transfer.ToCurrency && !rate.IsIdentity() {
+		return []db.CreateLedgerDiscrepancyParams{{
+			Kind:     KindTransferAmounts,
+			Expected: transfer.Amount,
+			Actual:   transfer.ToAmount,
+			Details:  fmt.Sprintf("exchange rate %s between accounts of the same currency", transfer.ExchangeRate),
+		}}
+	}
+
+	converted, err := rate.Convert(transfer.Amount)
+	if err != nil {
+		return []db.CreateLedgerDiscrepancyParams{{
+			Kind:     KindTransferAmounts,
+			Expected: transfer.ToAmount,
+			Actual:   transfer.ToAmount,
+			Details:  err.Error(),
+		}}
+	}
+	if converted != transfer.ToAmount {
+		return []db.CreateLedgerDiscrepancyParams{{
+			Kind:     KindTransferAmounts,
+			Expected: converted,
+			Actual:   transfer.ToAmount,
+			Details:  fmt.Sprintf("legs don't sum to zero at exchange rate %s", transfer.ExchangeRate),
+		}}
+	}
+
+	return nil
+}
+
+func publish(run db.ReconciliationRun) {
+	set := func(key string, value int64) {
+		v := new(expvar.Int)
+		v.Set(value)
+		metrics.Set(key, v)
+	}
+
+	set("accounts_checked", run.AccountsChecked)
+	set("transfers_checked", run.TransfersChecked)
+	set("discrepancies", run.Discrepancies)
+	set("finished_at", run.FinishedAt.Time.Unix())
+	set("duration_ms", run.FinishedAt.Time.Sub(run.StartedAt).Milliseconds())
+}
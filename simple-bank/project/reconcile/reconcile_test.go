@@ -0,0 +1,169 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"testing"
+	"time"
+
+	mockdb "github.com/ahmad-khatib0/go/simple-bank/project/db/mock"
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTransfer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		transfer db.ListTransferEntryChecksRow
+		kinds    []string
+	}{
+		{
+			name: "OK",
+			transfer: db.ListTransferEntryChecksRow{
+				Amount: 100, ToAmount: 100, ExchangeRate: "1.00000000",
+				FromCurrency: util.USD, ToCurrency: util.USD,
+				EntryCount: 2, FromTotal: -100, ToTotal: 100,
+			},
+		},
+		{
+			name: "CrossCurrency",
+			transfer: db.ListTransferEntryChecksRow{
+				Amount: 100, ToAmount: 93, ExchangeRate: "0.92500000",
+				FromCurrency: util.USD, ToCurrency: util.EUR,
+				EntryCount: 2, FromTotal: -100, ToTotal: 93,
+			},
+		},
+		{
+			name: "MissingEntry",
+			transfer: db.ListTransferEntryChecksRow{
+				Amount: 100, ToAmount: 100, ExchangeRate: "1.00000000",
+				FromCurrency: util.USD, ToCurrency: util.USD,
+				EntryCount: 1, FromTotal: -100,
+			},
+			kinds: []string{KindTransferEntries},
+		},
+		{
+			name: "MismatchedEntries",
+			transfer: db.ListTransferEntryChecksRow{
+				Amount: 100, ToAmount: 100, ExchangeRate: "1.00000000",
+				FromCurrency: util.USD, ToCurrency: util.USD,
+				EntryCount: 2, FromTotal: -90, ToTotal: 110,
+			},
+			kinds: []string{KindTransferEntries, KindTransferEntries},
+		},
+		{
+			name: "LegsDontSumToZero",
+			transfer: db.ListTransferEntryChecksRow{
+				Amount: 100, ToAmount: 95, ExchangeRate: "0.92500000",
+				FromCurrency: util.USD, ToCurrency: util.EUR,
+				EntryCount: 2, FromTotal: -100, ToTotal: 95,
+			},
+			kinds: []string{KindTransferAmounts},
+		},
+		{
+			name: "RateBetweenSameCurrency",
+			transfer: db.ListTransferEntryChecksRow{
+				Amount: 100, ToAmount: 200, ExchangeRate: "2.00000000",
+				FromCurrency: util.USD, ToCurrency: util.USD,
+				EntryCount: 2, FromTotal: -100, ToTotal: 200,
+			},
+			kinds: []string{KindTransferAmounts},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			discrepancies := checkTransfer(tc.transfer)
+			require.Len(t, discrepancies, len(tc.kinds))
+			for i, discrepancy := range discrepancies {
+				require.Equal(t, tc.kinds[i], discrepancy.Kind)
+				require.NotEmpty(t, discrepancy.Details)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	run := db.ReconciliationRun{ID: util.RandomInt(1, 1000), StartedAt: time.Now()}
+
+	store.EXPECT().
+		CreateReconciliationRun(gomock.Any()).
+		Times(1).
+		Return(run, nil)
+
+	store.EXPECT().
+		ListAccountBalanceChecks(gomock.Any(), gomock.Eq(db.ListAccountBalanceChecksParams{AfterID: 0, Limit: pageSize})).
+		Times(1).
+		Return([]db.ListAccountBalanceChecksRow{
+			{ID: 1, Balance: 100, EntriesTotal: 100},
+			{ID: 2, Balance: 50, EntriesTotal: 40},
+		}, nil)
+
+	store.EXPECT().
+		ListTransferEntryChecks(gomock.Any(), gomock.Eq(db.ListTransferEntryChecksParams{AfterID: 0, Limit: pageSize})).
+		Times(1).
+		Return([]db.ListTransferEntryChecksRow{
+			{
+				ID: 7, Amount: 10, ToAmount: 10, ExchangeRate: "1.00000000",
+				FromCurrency: util.USD, ToCurrency: util.USD,
+				EntryCount: 1, FromTotal: -10,
+			},
+		}, nil)
+
+	store.EXPECT().
+		CreateLedgerDiscrepancy(gomock.Any(), gomock.Eq(db.CreateLedgerDiscrepancyParams{
+			RunID:     run.ID,
+			Kind:      KindAccountBalance,
+			AccountID: sql.NullInt64{Int64: 2, Valid: true},
+			Expected:  40,
+			Actual:    50,
+			Details:   "balance isn't the sum of the entries",
+		})).
+		Times(1).
+		Return(db.LedgerDiscrepancy{RunID: run.ID, Kind: KindAccountBalance}, nil)
+
+	store.EXPECT().
+		CreateLedgerDiscrepancy(gomock.Any(), gomock.Eq(db.CreateLedgerDiscrepancyParams{
+			RunID:      run.ID,
+			Kind:       KindTransferEntries,
+			TransferID: sql.NullInt64{Int64: 7, Valid: true},
+			Expected:   2,
+			Actual:     1,
+			Details:    "transfer doesn't have exactly two entries",
+		})).
+		Times(1).
+		Return(db.LedgerDiscrepancy{RunID: run.ID, Kind: KindTransferEntries}, nil)
+
+	finished := run
+	finished.AccountsChecked = 2
+	finished.TransfersChecked = 1
+	finished.Discrepancies = 2
+	finished.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	store.EXPECT().
+		FinishReconciliationRun(gomock.Any(), gomock.Eq(db.FinishReconciliationRunParams{
+			ID:               run.ID,
+			AccountsChecked:  2,
+			TransfersChecked: 1,
+			Discrepancies:    2,
+		})).
+		Times(1).
+		Return(finished, nil)
+
+	result, err := Run(context.Background(), store)
+	require.NoError(t, err)
+	require.Equal(t, finished, result.Run)
+	require.Len(t, result.Discrepancies, 2)
+
+	discrepancies := metrics.Get("discrepancies").(*expvar.Int)
+	require.Equal(t, int64(2), discrepancies.Value())
+}
@@ -0,0 +1,119 @@
+package statement
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+)
+
+// The formats a statement can be exported in
+const (
+	FormatCSV  = "csv"
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// IsSupportedFormat returns true if the statement can be exported in the format
+func IsSupportedFormat(format string) bool {
+	switch format {
+	case FormatCSV, FormatText, FormatJSON:
+		return true
+	}
+	return false
+}
+
+// ContentType returns the MIME type of a statement exported in the format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSON:
+		return "application/json"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// FileName returns the name to save a statement of the account exported in the format under
+func FileName(account db.Account, from time.Time, format string) string {
+	ext := "txt"
+	if format != FormatText {
+		ext = format
+	}
+	return fmt.Sprintf("statement-%d-%s.%s", account.ID, from.UTC().Format("2006-01-02"), ext)
+}
+
+// Write exports the statement of the account in the format
+func Write(w io.Writer, format string, account db.Account, statement db.AccountStatementTxResult) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, statement)
+	case FormatText:
+		return WriteText(w, account, statement)
+	case FormatJSON:
+		return WriteJSON(w, account, statement)
+	}
+	return fmt.Errorf("unsupported statement format: %s", format)
+}
+
+// WriteCSV writes a row per entry, between a row with the opening balance and one with the closing balance
+func WriteCSV(w io.Writer, statement db.AccountStatementTxResult) error {
+	writer := csv.NewWriter(w)
+
+	rows := [][]string{
+		{"type", "entry_id", "created_at", "amount", "balance"},
+		{"opening", "", formatTime(statement.FromTime), "", strconv.FormatInt(statement.OpeningBalance, 10)},
+	}
+	for _, entry := range statement.Entries {
+		rows = append(rows, []string{
+			"entry",
+			strconv.FormatInt(entry.ID, 10),
+			formatTime(entry.CreatedAt),
+			strconv.FormatInt(entry.Amount, 10),
+			strconv.FormatInt(entry.Balance, 10),
+		})
+	}
+	rows = append(rows, []string{"closing", "", formatTime(statement.ToTime), "", strconv.FormatInt(statement.ClosingBalance, 10)})
+
+	// WriteAll flushes the writer and returns its error
+	return writer.WriteAll(rows)
+}
+
+// WriteText writes the statement as a table, to be read by people
+func WriteText(w io.Writer, account db.Account, statement db.AccountStatementTxResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintf(w, "Simple Bank statement\n")
+	fmt.Fprintf(w, "Account: #%d (%s)\n", account.ID, account.Currency)
+	fmt.Fprintf(w, "Owner:   %s\n", account.Owner)
+	fmt.Fprintf(w, "Period:  %s - %s\n\n", formatTime(statement.FromTime), formatTime(statement.ToTime))
+
+	fmt.Fprintf(tw, "DATE\tENTRY\tAMOUNT\tBALANCE\t\n")
+	fmt.Fprintf(tw, "%s\t\tOpening balance\t%d\t\n", formatTime(statement.FromTime), statement.OpeningBalance)
+	for _, entry := range statement.Entries {
+		fmt.Fprintf(tw, "%s\t#%d\t%+d\t%d\t\n", formatTime(entry.CreatedAt), entry.ID, entry.Amount, entry.Balance)
+	}
+	fmt.Fprintf(tw, "%s\t\tClosing balance\t%d\t\n", formatTime(statement.ToTime), statement.ClosingBalance)
+
+	return tw.Flush()
+}
+
+// WriteJSON writes the statement along with the account it belongs to
+func WriteJSON(w io.Writer, account db.Account, statement db.AccountStatementTxResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(struct {
+		Account db.Account `json:"account"`
+		db.AccountStatementTxResult
+	}{account, statement})
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,99 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomStatement(t *testing.T) (db.Account, db.AccountStatementTxResult) {
+	account := db.Account{
+		ID:       util.RandomInt(1, 1000),
+		Owner:    util.RandomOwner(),
+		Currency: util.RandomCurrency(),
+	}
+
+	from := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	statement := db.AccountStatementTxResult{
+		AccountID:      account.ID,
+		FromTime:       from,
+		ToTime:         from.AddDate(0, 1, 0),
+		OpeningBalance: 100,
+		ClosingBalance: 70,
+		Entries: []db.StatementEntry{
+			{Entry: db.Entry{ID: 1, AccountID: account.ID, Amount: -50, CreatedAt: from.Add(time.Hour)}, Balance: 50},
+			{Entry: db.Entry{ID: 2, AccountID: account.ID, Amount: 20, CreatedAt: from.Add(2 * time.Hour)}, Balance: 70},
+		},
+	}
+
+	return account, statement
+}
+
+func TestWriteCSV(t *testing.T) {
+	account, statement := randomStatement(t)
+
+	var buf bytes.Buffer
+	err := Write(&buf, FormatCSV, account, statement)
+	require.NoError(t, err)
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"type", "entry_id", "created_at", "amount", "balance"},
+		{"opening", "", "2026-09-01T00:00:00Z", "", "100"},
+		{"entry", "1", "2026-09-01T01:00:00Z", "-50", "50"},
+		{"entry", "2", "2026-09-01T02:00:00Z", "20", "70"},
+		{"closing", "", "2026-10-01T00:00:00Z", "", "70"},
+	}, rows)
+}
+
+func TestWriteText(t *testing.T) {
+	account, statement := randomStatement(t)
+
+	var buf bytes.Buffer
+	err := Write(&buf, FormatText, account, statement)
+	require.NoError(t, err)
+
+	text := buf.String()
+	require.Contains(t, text, account.Owner)
+	require.Contains(t, text, "Opening balance")
+	require.Contains(t, text, "Closing balance")
+	require.Contains(t, text, "-50")
+	require.Contains(t, text, "+20")
+}
+
+func TestWriteJSON(t *testing.T) {
+	account, statement := randomStatement(t)
+
+	var buf bytes.Buffer
+	err := Write(&buf, FormatJSON, account, statement)
+	require.NoError(t, err)
+
+	var got struct {
+		Account        db.Account          `json:"account"`
+		OpeningBalance int64               `json:"opening_balance"`
+		ClosingBalance int64               `json:"closing_balance"`
+		Entries        []db.StatementEntry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	require.Equal(t, account.ID, got.Account.ID)
+	require.Equal(t, statement.OpeningBalance, got.OpeningBalance)
+	require.Equal(t, statement.ClosingBalance, got.ClosingBalance)
+	require.Len(t, got.Entries, 2)
+	require.Equal(t, int64(50), got.Entries[0].Balance)
+}
+
+func TestWriteUnsupportedFormat(t *testing.T) {
+	account, statement := randomStatement(t)
+
+	var buf bytes.Buffer
+	err := Write(&buf, "pdf", account, statement)
+	require.Error(t, err)
+	require.False(t, IsSupportedFormat("pdf"))
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
 	"github.com/google/uuid"
 )
 
@@ -17,12 +18,13 @@ var (
 type Payload struct {
 	ID        uuid.UUID `json:"id"`
 	Username  string    `json:"username"`
+	Role      string    `json:"role"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
 
-// NewPayload creates a new token payload with a specific username and duration
-func NewPayload(username string, duration time.Duration) (*Payload, error) {
+// NewPayload creates a new token payload with a specific username, role and duration
+func NewPayload(username string, role string, duration time.Duration) (*Payload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
@@ -31,6 +33,7 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 	payload := &Payload{
 		ID:        tokenID,
 		Username:  username,
+		Role:      role,
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(duration),
 	}
@@ -44,3 +47,9 @@ func (payload *Payload) Valid() error {
 	}
 	return nil
 }
+
+// CanAccess checks if the payload's user may view or update the resources of owner.
+// Bankers may access every user's resources, everyone else only their own.
+func (payload *Payload) CanAccess(owner string) bool {
+	return payload.Role == util.BankerRole || payload.Username == owner
+}
@@ -0,0 +1,23 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadCanAccess(t *testing.T) {
+	username := util.RandomOwner()
+
+	depositor, err := NewPayload(username, util.DepositorRole, time.Minute)
+	require.NoError(t, err)
+	require.True(t, depositor.CanAccess(username))
+	require.False(t, depositor.CanAccess(util.RandomOwner()))
+
+	banker, err := NewPayload(username, util.BankerRole, time.Minute)
+	require.NoError(t, err)
+	require.True(t, banker.CanAccess(username))
+	require.True(t, banker.CanAccess(util.RandomOwner()))
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/spf13/viper"
@@ -9,21 +10,29 @@ import (
 // Config stores all configuration of the application.
 // The values are read by viper from a config file or environment variable.
 type Config struct {
-	Environment          string        `mapstructure:"ENVIRONMENT"`
-	DBDriver             string        `mapstructure:"DB_DRIVER"`
-	DBSource             string        `mapstructure:"DB_SOURCE"`
-	MigrationURL         string        `mapstructure:"MIGRATION_URL"`
-	RedisAddress         string        `mapstructure:"REDIS_ADDRESS"`
-	HTTPServerAddress    string        `mapstructure:"HTTP_SERVER_ADDRESS"`
-	GRPCServerAddress    string        `mapstructure:"GRPC_SERVER_ADDRESS"`
-	TokenSymmetricKey    string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
-	AccessTokenDuration  time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
-	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
-	EmailSenderName      string        `mapstructure:"EMAIL_SENDER_NAME"`
-	EmailSenderAddress   string        `mapstructure:"EMAIL_SENDER_ADDRESS"`
-	EmailSenderPassword  string        `mapstructure:"EMAIL_SENDER_PASSWORD"`
+	Environment            string        `mapstructure:"ENVIRONMENT"`
+	DBDriver               string        `mapstructure:"DB_DRIVER"`
+	DBSource               string        `mapstructure:"DB_SOURCE"`
+	MigrationURL           string        `mapstructure:"MIGRATION_URL"`
+	RedisAddress           string        `mapstructure:"REDIS_ADDRESS"`
+	HTTPServerAddress      string        `mapstructure:"HTTP_SERVER_ADDRESS"`
+	GRPCServerAddress      string        `mapstructure:"GRPC_SERVER_ADDRESS"`
+	MetricsServerAddress   string        `mapstructure:"METRICS_SERVER_ADDRESS"`
+	TokenSymmetricKey      string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	AccessTokenDuration    time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration   time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	PreAuthTokenDuration   time.Duration `mapstructure:"PRE_AUTH_TOKEN_DURATION"`
+	EmailSenderName        string        `mapstructure:"EMAIL_SENDER_NAME"`
+	EmailSenderAddress     string        `mapstructure:"EMAIL_SENDER_ADDRESS"`
+	EmailSenderPassword    string        `mapstructure:"EMAIL_SENDER_PASSWORD"`
+	IdempotencyKeyDuration time.Duration `mapstructure:"IDEMPOTENCY_KEY_DURATION"`
+	ExchangeRatesFile      string        `mapstructure:"EXCHANGE_RATES_FILE"`
 }
 
+// defaultIdempotencyKeyDuration is how long a transfer's idempotency key is kept when
+// IDEMPOTENCY_KEY_DURATION isn't set.
+const defaultIdempotencyKeyDuration = 24 * time.Hour
+
 // LoadConfig reads configuration from file or environment variables.
 func LoadConfig(path string) (config Config, err error) {
 	viper.AddConfigPath(path)
@@ -31,6 +40,7 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.SetConfigType("env")
 
 	viper.AutomaticEnv()
+	viper.SetDefault("IDEMPOTENCY_KEY_DURATION", defaultIdempotencyKeyDuration)
 
 	err = viper.ReadInConfig()
 	if err != nil {
@@ -38,5 +48,13 @@ func LoadConfig(path string) (config Config, err error) {
 	}
 
 	err = viper.Unmarshal(&config)
+	if err != nil {
+		return
+	}
+
+	// a key that expires right away doesn't stop a retried transfer from running twice
+	if config.IdempotencyKeyDuration <= 0 {
+		err = fmt.Errorf("IDEMPOTENCY_KEY_DURATION must be positive, got %s", config.IdempotencyKeyDuration)
+	}
 	return
 }
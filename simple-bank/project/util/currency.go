@@ -1,17 +1,18 @@
 package util
 
-// Constants for all supported currencies
+import "regexp"
+
+// Constants for the currencies the currencies table is seeded with
 const (
 	USD = "USD"
 	EUR = "EUR"
 	CAD = "CAD"
 )
 
-// IsSupportedCurrency returns true if the currency is supported
+var isCurrencyCode = regexp.MustCompile(`^[A-Z]{3}$`).MatchString
+
+// IsSupportedCurrency returns true if the currency looks like an ISO 4217 code. Whether the bank
+// supports it is up to the currencies table, which accounts reference.
 func IsSupportedCurrency(currency string) bool {
-	switch currency {
-	case USD, EUR, CAD:
-		return true
-	}
-	return false
+	return isCurrencyCode(currency)
 }
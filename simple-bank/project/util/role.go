@@ -0,0 +1,7 @@
+package util
+
+// Constants for the roles a user can have
+const (
+	DepositorRole = "depositor"
+	BankerRole    = "banker"
+)
@@ -0,0 +1,17 @@
+package util
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// NextRun returns the first time after t the cron schedule fires at, like "0 9 1 * *" for 09:00 UTC on
+// the first day of every month. It also takes descriptors, like "@daily" or "@every 1h".
+func NextRun(schedule string, t time.Time) (time.Time, error) {
+	s, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.Next(t.UTC()), nil
+}
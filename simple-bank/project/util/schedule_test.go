@@ -0,0 +1,23 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2026, time.September, 15, 10, 30, 0, 0, time.UTC)
+
+	next, err := NextRun("0 9 1 * *", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, time.October, 1, 9, 0, 0, 0, time.UTC), next)
+
+	next, err = NextRun("@every 1h", now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(time.Hour), next)
+
+	_, err = NextRun("every monday", now)
+	require.Error(t, err)
+}
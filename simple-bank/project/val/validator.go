@@ -4,11 +4,19 @@ import (
 	"fmt"
 	"net/mail"
 	"regexp"
+	"time"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 var (
 	isValidUsername = regexp.MustCompile(`^[a-z0-9_]+$`).MatchString
 	isValidFullName = regexp.MustCompile(`^[a-zA-Z\s]+$`).MatchString
+	isValidTOTPCode = regexp.MustCompile(`^[0-9]{6}$`).MatchString
+	// recovery codes are matched case-insensitively, as they're typed in from a printout
+	isValidRecoveryCode = regexp.MustCompile(`^[a-zA-Z0-9]{5}-[a-zA-Z0-9]{5}$`).MatchString
 )
 
 func ValidateString(value string, minLength int, maxLength int) error {
@@ -63,3 +71,112 @@ func ValidateEmailId(value int64) error {
 func ValidateSecretCode(value string) error {
 	return ValidateString(value, 32, 128)
 }
+
+func ValidateCurrency(value string) error {
+	if !util.IsSupportedCurrency(value) {
+		return fmt.Errorf("must be a 3-letter uppercase currency code")
+	}
+	return nil
+}
+
+func ValidateID(value int64) error {
+	if value <= 0 {
+		return fmt.Errorf("must be a positive integer")
+	}
+	return nil
+}
+
+func ValidateAmount(value int64) error {
+	if value <= 0 {
+		return fmt.Errorf("must be greater than 0")
+	}
+	return nil
+}
+
+func ValidatePageID(value int32) error {
+	if value < 1 {
+		return fmt.Errorf("must be at least 1")
+	}
+	return nil
+}
+
+func ValidatePageSize(value int32) error {
+	if value < 5 || value > 10 {
+		return fmt.Errorf("must be from 5 to 10")
+	}
+	return nil
+}
+
+func ValidateLimit(value int64) error {
+	if value < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+	return nil
+}
+
+func ValidateIdempotencyKey(value string) error {
+	return ValidateString(value, 1, 255)
+}
+
+func ValidateTimestamp(value *timestamppb.Timestamp) error {
+	if value == nil {
+		return fmt.Errorf("must be provided")
+	}
+	return value.CheckValid()
+}
+
+func ValidateTimeRange(from time.Time, to time.Time, maxDuration time.Duration) error {
+	if !to.After(from) {
+		return fmt.Errorf("must be after from_time")
+	}
+	if to.Sub(from) > maxDuration {
+		return fmt.Errorf("must be at most %s after from_time", maxDuration)
+	}
+	return nil
+}
+
+func ValidateSchedule(value string) error {
+	if err := ValidateString(value, 1, 100); err != nil {
+		return err
+	}
+	if _, err := util.NextRun(value, time.Now()); err != nil {
+		return fmt.Errorf("is not a valid cron expression: %s", err)
+	}
+	return nil
+}
+
+func ValidateSessionID(value string) error {
+	if _, err := uuid.Parse(value); err != nil {
+		return fmt.Errorf("must be a valid UUID")
+	}
+	return nil
+}
+
+func ValidateRefreshToken(value string) error {
+	if value == "" {
+		return fmt.Errorf("must be provided")
+	}
+	return nil
+}
+
+func ValidatePreAuthToken(value string) error {
+	if _, err := uuid.Parse(value); err != nil {
+		return fmt.Errorf("must be a valid pre-auth token")
+	}
+	return nil
+}
+
+func ValidateTOTPCode(value string) error {
+	if !isValidTOTPCode(value) {
+		return fmt.Errorf("must be a 6 digit code")
+	}
+	return nil
+}
+
+// ValidateLoginCode accepts a 6 digit code, of an authenticator app or sent by email, or a recovery code.
+func ValidateLoginCode(value string) error {
+	if !isValidTOTPCode(value) && !isValidRecoveryCode(value) {
+		return fmt.Errorf("must be a 6 digit code or a recovery code")
+	}
+	return nil
+}
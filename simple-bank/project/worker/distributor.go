@@ -12,6 +12,26 @@ type TaskDistributor interface {
 		payload *PayloadSendVerifyEmail,
 		opts ...asynq.Option,
 	) error
+	DistributeTaskSendAccountStatement(
+		ctx context.Context,
+		payload *PayloadSendAccountStatement,
+		opts ...asynq.Option,
+	) error
+	DistributeTaskRunScheduledTransfer(
+		ctx context.Context,
+		payload *PayloadRunScheduledTransfer,
+		opts ...asynq.Option,
+	) error
+	DistributeTaskSendScheduledTransferFailed(
+		ctx context.Context,
+		payload *PayloadSendScheduledTransferFailed,
+		opts ...asynq.Option,
+	) error
+	DistributeTaskSendLoginCode(
+		ctx context.Context,
+		payload *PayloadSendLoginCode,
+		opts ...asynq.Option,
+	) error
 }
 
 type RedisTaskDistributor struct {
@@ -6,36 +6,113 @@ package mockwk
 
 import (
 	context "context"
+	reflect "reflect"
+
+	worker "github.com/ahmad-khatib0/go/simple-bank/project/worker"
 	gomock "github.com/golang/mock/gomock"
 	asynq "github.com/hibiken/asynq"
-	worker "github.com/ahmad-khatib0/go/simple-bank/project/worker"
-	reflect "reflect"
 )
 
-// MockTaskDistributor is a mock of TaskDistributor interface
+// MockTaskDistributor is a mock of TaskDistributor interface.
 type MockTaskDistributor struct {
 	ctrl     *gomock.Controller
 	recorder *MockTaskDistributorMockRecorder
 }
 
-// MockTaskDistributorMockRecorder is the mock recorder for MockTaskDistributor
+// MockTaskDistributorMockRecorder is the mock recorder for MockTaskDistributor.
 type MockTaskDistributorMockRecorder struct {
 	mock *MockTaskDistributor
 }
 
-// NewMockTaskDistributor creates a new mock instance
+// NewMockTaskDistributor creates a new mock instance.
 func NewMockTaskDistributor(ctrl *gomock.Controller) *MockTaskDistributor {
 	mock := &MockTaskDistributor{ctrl: ctrl}
 	mock.recorder = &MockTaskDistributorMockRecorder{mock}
 	return mock
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use
+// EXPECT returns an object that allows the caller to indicate expected use.
 func (m *MockTaskDistributor) EXPECT() *MockTaskDistributorMockRecorder {
 	return m.recorder
 }
 
-// DistributeTaskSendVerifyEmail mocks base method
+// DistributeTaskRunScheduledTransfer mocks base method.
+func (m *MockTaskDistributor) DistributeTaskRunScheduledTransfer(arg0 context.Context, arg1 *worker.PayloadRunScheduledTransfer, arg2 ...asynq.Option) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DistributeTaskRunScheduledTransfer", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DistributeTaskRunScheduledTransfer indicates an expected call of DistributeTaskRunScheduledTransfer.
+func (mr *MockTaskDistributorMockRecorder) DistributeTaskRunScheduledTransfer(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DistributeTaskRunScheduledTransfer", reflect.TypeOf((*MockTaskDistributor)(nil).DistributeTaskRunScheduledTransfer), varargs...)
+}
+
+// DistributeTaskSendAccountStatement mocks base method.
+func (m *MockTaskDistributor) DistributeTaskSendAccountStatement(arg0 context.Context, arg1 *worker.PayloadSendAccountStatement, arg2 ...asynq.Option) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DistributeTaskSendAccountStatement", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DistributeTaskSendAccountStatement indicates an expected call of DistributeTaskSendAccountStatement.
+func (mr *MockTaskDistributorMockRecorder) DistributeTaskSendAccountStatement(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DistributeTaskSendAccountStatement", reflect.TypeOf((*MockTaskDistributor)(nil).DistributeTaskSendAccountStatement), varargs...)
+}
+
+// DistributeTaskSendLoginCode mocks base method.
+func (m *MockTaskDistributor) DistributeTaskSendLoginCode(arg0 context.Context, arg1 *worker.PayloadSendLoginCode, arg2 ...asynq.Option) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DistributeTaskSendLoginCode", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DistributeTaskSendLoginCode indicates an expected call of DistributeTaskSendLoginCode.
+func (mr *MockTaskDistributorMockRecorder) DistributeTaskSendLoginCode(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DistributeTaskSendLoginCode", reflect.TypeOf((*MockTaskDistributor)(nil).DistributeTaskSendLoginCode), varargs...)
+}
+
+// DistributeTaskSendScheduledTransferFailed mocks base method.
+func (m *MockTaskDistributor) DistributeTaskSendScheduledTransferFailed(arg0 context.Context, arg1 *worker.PayloadSendScheduledTransferFailed, arg2 ...asynq.Option) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DistributeTaskSendScheduledTransferFailed", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DistributeTaskSendScheduledTransferFailed indicates an expected call of DistributeTaskSendScheduledTransferFailed.
+func (mr *MockTaskDistributorMockRecorder) DistributeTaskSendScheduledTransferFailed(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DistributeTaskSendScheduledTransferFailed", reflect.TypeOf((*MockTaskDistributor)(nil).DistributeTaskSendScheduledTransferFailed), varargs...)
+}
+
+// DistributeTaskSendVerifyEmail mocks base method.
 func (m *MockTaskDistributor) DistributeTaskSendVerifyEmail(arg0 context.Context, arg1 *worker.PayloadSendVerifyEmail, arg2 ...asynq.Option) error {
 	m.ctrl.T.Helper()
 	varargs := []interface{}{arg0, arg1}
@@ -47,7 +124,7 @@ func (m *MockTaskDistributor) DistributeTaskSendVerifyEmail(arg0 context.Context
 	return ret0
 }
 
-// DistributeTaskSendVerifyEmail indicates an expected call of DistributeTaskSendVerifyEmail
+// DistributeTaskSendVerifyEmail indicates an expected call of DistributeTaskSendVerifyEmail.
 func (mr *MockTaskDistributorMockRecorder) DistributeTaskSendVerifyEmail(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	varargs := append([]interface{}{arg0, arg1}, arg2...)
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange"
 	"github.com/ahmad-khatib0/go/simple-bank/project/mail"
 	"github.com/go-redis/redis/v8"
 	"github.com/hibiken/asynq"
@@ -19,15 +20,31 @@ const (
 type TaskProcessor interface {
 	Start() error
 	ProcessTaskSendVerifyEmail(ctx context.Context, task *asynq.Task) error
+	ProcessTaskSendMonthlyStatements(ctx context.Context, task *asynq.Task) error
+	ProcessTaskSendAccountStatement(ctx context.Context, task *asynq.Task) error
+	ProcessTaskDispatchScheduledTransfers(ctx context.Context, task *asynq.Task) error
+	ProcessTaskRunScheduledTransfer(ctx context.Context, task *asynq.Task) error
+	ProcessTaskSendScheduledTransferFailed(ctx context.Context, task *asynq.Task) error
+	ProcessTaskReconcileLedger(ctx context.Context, task *asynq.Task) error
+	ProcessTaskSendLoginCode(ctx context.Context, task *asynq.Task) error
+	ProcessTaskDeleteExpiredIdempotencyKeys(ctx context.Context, task *asynq.Task) error
 }
 
 type RedisTaskProcessor struct {
 	server *asynq.Server
 	store  db.Store
 	mailer mail.EmailSender
+	rates  exchange.ExchangeRateProvider
+	// distributor queues the tasks that tasks fan out into
+	distributor TaskDistributor
 }
 
-func NewRedisTaskProcessor(redisOpt asynq.RedisClientOpt, store db.Store, mailer mail.EmailSender) TaskProcessor {
+func NewRedisTaskProcessor(
+	redisOpt asynq.RedisClientOpt,
+	store db.Store,
+	mailer mail.EmailSender,
+	rates exchange.ExchangeRateProvider,
+) TaskProcessor {
 	logger := NewLogger()
 	redis.SetLogger(logger)
 
@@ -47,9 +64,11 @@ func NewRedisTaskProcessor(redisOpt asynq.RedisClientOpt, store db.Store, mailer
 	)
 
 	return &RedisTaskProcessor{
-		server: server,
-		store:  store,
-		mailer: mailer,
+		server:      server,
+		store:       store,
+		mailer:      mailer,
+		rates:       rates,
+		distributor: NewRedisTaskDistributor(redisOpt),
 	}
 }
 
@@ -58,6 +77,14 @@ func (processor *RedisTaskProcessor) Start() error {
 
 	// resister teh task
 	mux.HandleFunc(TaskSendVerifyEmail, processor.ProcessTaskSendVerifyEmail)
+	mux.HandleFunc(TaskSendMonthlyStatements, processor.ProcessTaskSendMonthlyStatements)
+	mux.HandleFunc(TaskSendAccountStatement, processor.ProcessTaskSendAccountStatement)
+	mux.HandleFunc(TaskDispatchScheduledTransfers, processor.ProcessTaskDispatchScheduledTransfers)
+	mux.HandleFunc(TaskRunScheduledTransfer, processor.ProcessTaskRunScheduledTransfer)
+	mux.HandleFunc(TaskSendScheduledTransferFailed, processor.ProcessTaskSendScheduledTransferFailed)
+	mux.HandleFunc(TaskReconcileLedger, processor.ProcessTaskReconcileLedger)
+	mux.HandleFunc(TaskSendLoginCode, processor.ProcessTaskSendLoginCode)
+	mux.HandleFunc(TaskDeleteExpiredIdempotencyKeys, processor.ProcessTaskDeleteExpiredIdempotencyKeys)
 
 	return processor.server.Start(mux)
 }
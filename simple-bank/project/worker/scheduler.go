@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+)
+
+// MonthlyStatementsCron is when the monthly statements are sent: at 06:00 UTC on the first day of the month.
+const MonthlyStatementsCron = "0 6 1 * *"
+
+// TaskScheduler queues the periodic tasks, which the task processor then picks up like any other task.
+type TaskScheduler interface {
+	Start() error
+}
+
+type RedisTaskScheduler struct {
+	scheduler *asynq.Scheduler
+}
+
+func NewRedisTaskScheduler(redisOpt asynq.RedisClientOpt) TaskScheduler {
+	scheduler := asynq.NewScheduler(redisOpt, &asynq.SchedulerOpts{
+		Location: time.UTC,
+		Logger:   NewLogger(),
+		EnqueueErrorHandler: func(task *asynq.Task, opts []asynq.Option, err error) {
+			log.Error().Err(err).Str("type", task.Type()).Msg("failed to enqueue periodic task")
+		},
+	})
+
+	return &RedisTaskScheduler{
+		scheduler: scheduler,
+	}
+}
+
+func (taskScheduler *RedisTaskScheduler) Start() error {
+	_, err := taskScheduler.scheduler.Register(
+		MonthlyStatementsCron,
+		NewTaskSendMonthlyStatements(),
+		asynq.Queue(QueueDefault),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register monthly statements task: %w", err)
+	}
+
+	_, err = taskScheduler.scheduler.Register(
+		DispatchScheduledTransfersCron,
+		NewTaskDispatchScheduledTransfers(),
+		asynq.Queue(QueueCritical),
+		// a dispatch still waiting when the next one is due is enough
+		asynq.Unique(time.Minute),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register dispatch scheduled transfers task: %w", err)
+	}
+
+	_, err = taskScheduler.scheduler.Register(
+		ReconcileLedgerCron,
+		NewTaskReconcileLedger(),
+		asynq.Queue(QueueDefault),
+		// the run reads the whole ledger, a single retry is enough
+		asynq.MaxRetry(1),
+		asynq.Unique(time.Hour),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register reconcile ledger task: %w", err)
+	}
+
+	_, err = taskScheduler.scheduler.Register(
+		DeleteExpiredIdempotencyKeysCron,
+		NewTaskDeleteExpiredIdempotencyKeys(),
+		asynq.Queue(QueueDefault),
+		asynq.Unique(time.Hour),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register delete expired idempotency keys task: %w", err)
+	}
+
+	return taskScheduler.scheduler.Start()
+}
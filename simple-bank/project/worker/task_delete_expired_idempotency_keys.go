@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+)
+
+const TaskDeleteExpiredIdempotencyKeys = "task:delete_expired_idempotency_keys"
+
+// DeleteExpiredIdempotencyKeysCron is when the expired idempotency keys are deleted: every hour.
+const DeleteExpiredIdempotencyKeysCron = "30 * * * *"
+
+// deleteIdempotencyKeysBatch is how many keys a statement deletes, so that a large backlog of
+// expired keys doesn't hold its locks in a single long transaction.
+const deleteIdempotencyKeysBatch = 1000
+
+// NewTaskDeleteExpiredIdempotencyKeys returns the task the scheduler queues to delete the expired
+// idempotency keys.
+func NewTaskDeleteExpiredIdempotencyKeys() *asynq.Task {
+	return asynq.NewTask(TaskDeleteExpiredIdempotencyKeys, nil)
+}
+
+// ProcessTaskDeleteExpiredIdempotencyKeys deletes the expired idempotency keys batch by batch,
+// the table would otherwise keep every key a client ever sent.
+func (processor *RedisTaskProcessor) ProcessTaskDeleteExpiredIdempotencyKeys(ctx context.Context, task *asynq.Task) error {
+	var deleted int64
+	for {
+		n, err := processor.store.DeleteExpiredIdempotencyKeys(ctx, deleteIdempotencyKeysBatch)
+		if err != nil {
+			return fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+		}
+		deleted += n
+		if n < deleteIdempotencyKeysBatch {
+			break
+		}
+	}
+
+	log.Info().Str("type", task.Type()).Int64("deleted", deleted).Msg("processed task")
+	return nil
+}
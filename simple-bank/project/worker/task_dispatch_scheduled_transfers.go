@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+)
+
+const TaskDispatchScheduledTransfers = "task:dispatch_scheduled_transfers"
+
+// DispatchScheduledTransfersCron is how often the due scheduled transfers are looked for.
+const DispatchScheduledTransfersCron = "@every 1m"
+
+// scheduledTransfersPageSize is the number of due scheduled transfers queued at a time.
+const scheduledTransfersPageSize = 100
+
+// NewTaskDispatchScheduledTransfers returns the task the scheduler queues to run the due scheduled transfers.
+func NewTaskDispatchScheduledTransfers() *asynq.Task {
+	return asynq.NewTask(TaskDispatchScheduledTransfers, nil)
+}
+
+// ProcessTaskDispatchScheduledTransfers queues a task to run each scheduled transfer that is due. The
+// tasks get an ID per transfer and run, so a run still waiting in the queue isn't queued twice.
+func (processor *RedisTaskProcessor) ProcessTaskDispatchScheduledTransfers(ctx context.Context, task *asynq.Task) error {
+	now := time.Now()
+
+	scheduledTransfers, err := processor.store.ListDueScheduledTransfers(ctx, db.ListDueScheduledTransfersParams{
+		Now:   now,
+		Limit: scheduledTransfersPageSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list due scheduled transfers: %w", err)
+	}
+
+	// the transfers left over are picked up the next time, once the ones queued now moved on
+	for _, scheduledTransfer := range scheduledTransfers {
+		scheduledFor := scheduledTransfer.NextRunAt.Time
+		payload := &PayloadRunScheduledTransfer{
+			ScheduledTransferID: scheduledTransfer.ID,
+			ScheduledFor:        scheduledFor,
+		}
+		opts := []asynq.Option{
+			asynq.MaxRetry(10),
+			asynq.Queue(QueueCritical),
+			asynq.TaskID(fmt.Sprintf("scheduled_transfer:%d:%d", scheduledTransfer.ID, scheduledFor.UnixMicro())),
+		}
+
+		err := processor.distributor.DistributeTaskRunScheduledTransfer(ctx, payload, opts...)
+		if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+			return fmt.Errorf("failed to distribute task to run scheduled transfer: %w", err)
+		}
+	}
+
+	log.Info().Str("type", task.Type()).Int("scheduled_transfers", len(scheduledTransfers)).Msg("processed task")
+	return nil
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ahmad-khatib0/go/simple-bank/project/reconcile"
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+)
+
+const TaskReconcileLedger = "task:reconcile_ledger"
+
+// ReconcileLedgerCron is when the ledger is reconciled: every day at 03:00 UTC.
+const ReconcileLedgerCron = "0 3 * * *"
+
+// NewTaskReconcileLedger returns the task the scheduler queues to reconcile the ledger.
+func NewTaskReconcileLedger() *asynq.Task {
+	return asynq.NewTask(TaskReconcileLedger, nil)
+}
+
+// ProcessTaskReconcileLedger checks the balances and transfers against the entries. Discrepancies
+// don't fail the task: they are recorded, and exposed by the metrics, for someone to look into.
+func (processor *RedisTaskProcessor) ProcessTaskReconcileLedger(ctx context.Context, task *asynq.Task) error {
+	result, err := reconcile.Run(ctx, processor.store)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile ledger: %w", err)
+	}
+
+	for _, discrepancy := range result.Discrepancies {
+		log.Warn().Str("type", task.Type()).Int64("run_id", discrepancy.RunID).
+			Str("kind", discrepancy.Kind).Int64("account_id", discrepancy.AccountID.Int64).
+			Int64("transfer_id", discrepancy.TransferID.Int64).Int64("expected", discrepancy.Expected).
+			Int64("actual", discrepancy.Actual).Msg(discrepancy.Details)
+	}
+
+	log.Info().Str("type", task.Type()).Int64("run_id", result.Run.ID).
+		Int64("accounts_checked", result.Run.AccountsChecked).
+		Int64("transfers_checked", result.Run.TransfersChecked).
+		Int64("discrepancies", result.Run.Discrepancies).Msg("processed task")
+	return nil
+}
@@ -0,0 +1,192 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/exchange"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+)
+
+const TaskRunScheduledTransfer = "task:run_scheduled_transfer"
+
+// scheduledTransferKeyDuration is how long the idempotency key of a run is kept. It outlasts the
+// retries of the task, so a retry never makes the transfer twice.
+const scheduledTransferKeyDuration = 7 * 24 * time.Hour
+
+// PayloadRunScheduledTransfer is the run of the scheduled transfer to make.
+type PayloadRunScheduledTransfer struct {
+	ScheduledTransferID int64     `json:"scheduled_transfer_id"`
+	ScheduledFor        time.Time `json:"scheduled_for"`
+}
+
+func (distributor *RedisTaskDistributor) DistributeTaskRunScheduledTransfer(
+	ctx context.Context,
+	payload *PayloadRunScheduledTransfer,
+	opts ...asynq.Option,
+) error {
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	task := asynq.NewTask(TaskRunScheduledTransfer, jsonPayload, opts...)
+	info, err := distributor.client.EnqueueContext(ctx, task)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	log.Info().Str("type", task.Type()).Bytes("payload", task.Payload()).
+		Str("queue", info.Queue).Int("max_retry", info.MaxRetry).Msg("enqueued task")
+	return nil
+}
+
+// ProcessTaskRunScheduledTransfer makes the transfer, records the outcome of the run and moves the
+// scheduled transfer on to its next run. When the transfer can never be made, the failure is recorded and
+// the owner is emailed about it; other errors are returned for the task to be retried, until its last
+// retry, which records the failure too so the scheduled transfer isn't stuck on the run.
+func (processor *RedisTaskProcessor) ProcessTaskRunScheduledTransfer(ctx context.Context, task *asynq.Task) error {
+	var payload PayloadRunScheduledTransfer
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	scheduledTransfer, err := processor.store.GetScheduledTransfer(ctx, payload.ScheduledTransferID)
+	if err != nil {
+		return fmt.Errorf("failed to get scheduled transfer: %w", err)
+	}
+
+	if scheduledTransfer.Status != db.ScheduledTransferActive ||
+		!scheduledTransfer.NextRunAt.Time.Equal(payload.ScheduledFor) {
+		log.Info().Str("type", task.Type()).Bytes("payload", task.Payload()).
+			Str("status", scheduledTransfer.Status).Msg("scheduled transfer run is done or cancelled")
+		return nil
+	}
+
+	var nextRunAt sql.NullTime
+	if scheduledTransfer.Schedule != "" {
+		// the runs missed while the worker was down are skipped rather than made one after the other
+		after := payload.ScheduledFor
+		if now := time.Now(); now.After(after) {
+			after = now
+		}
+
+		next, err := util.NextRun(scheduledTransfer.Schedule, after)
+		if err != nil {
+			return fmt.Errorf("invalid schedule: %s: %w", err, asynq.SkipRetry)
+		}
+		nextRunAt = sql.NullTime{Time: next, Valid: true}
+	}
+
+	result, transferErr := processor.runScheduledTransfer(ctx, scheduledTransfer, payload.ScheduledFor)
+	if transferErr != nil && !scheduledTransferRejected(transferErr) && !lastRetry(ctx) {
+		return fmt.Errorf("failed to run scheduled transfer: %w", transferErr)
+	}
+
+	arg := db.RecordScheduledTransferRunTxParams{
+		ScheduledTransferID: scheduledTransfer.ID,
+		ScheduledFor:        payload.ScheduledFor,
+		NextRunAt:           nextRunAt,
+	}
+	if transferErr != nil {
+		arg.Error = transferErr.Error()
+	} else {
+		arg.TransferID = sql.NullInt64{Int64: result.Transfer.ID, Valid: true}
+	}
+
+	run, err := processor.store.RecordScheduledTransferRunTx(ctx, arg)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Warn().Str("type", task.Type()).Bytes("payload", task.Payload()).
+				Msg("scheduled transfer was cancelled or recorded while it ran")
+			return nil
+		}
+		return fmt.Errorf("failed to record scheduled transfer run: %w", err)
+	}
+
+	if transferErr != nil {
+		err := processor.distributor.DistributeTaskSendScheduledTransferFailed(
+			ctx,
+			&PayloadSendScheduledTransferFailed{ScheduledTransferRunID: run.ScheduledTransferRun.ID},
+			asynq.MaxRetry(10),
+			asynq.Queue(QueueDefault),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to distribute task to send scheduled transfer failed email: %w", err)
+		}
+	}
+
+	log.Info().Str("type", task.Type()).Bytes("payload", task.Payload()).
+		AnErr("transfer_error", transferErr).Msg("processed task")
+	return nil
+}
+
+// runScheduledTransfer makes the transfer of the run. The run is its idempotency key, so the transfer
+// is made once however many times the task is retried.
+func (processor *RedisTaskProcessor) runScheduledTransfer(
+	ctx context.Context,
+	scheduledTransfer db.ScheduledTransfer,
+	scheduledFor time.Time,
+) (db.TransferTxResult, error) {
+	arg := db.TransferTxParams{
+		FromAccountID:          scheduledTransfer.FromAccountID,
+		ToAccountID:            scheduledTransfer.ToAccountID,
+		Amount:                 scheduledTransfer.Amount,
+		Username:               scheduledTransfer.Owner,
+		IdempotencyScope:       db.IdempotencyScopeScheduledTransfer,
+		IdempotencyKey:         fmt.Sprintf("%d:%d", scheduledTransfer.ID, scheduledFor.UnixMicro()),
+		IdempotencyKeyDuration: scheduledTransferKeyDuration,
+	}
+
+	fromAccount, err := processor.store.GetAccount(ctx, scheduledTransfer.FromAccountID)
+	if err != nil {
+		return db.TransferTxResult{}, fmt.Errorf("failed to get from account: %w", err)
+	}
+
+	toAccount, err := processor.store.GetAccount(ctx, scheduledTransfer.ToAccountID)
+	if err != nil {
+		return db.TransferTxResult{}, fmt.Errorf("failed to get to account: %w", err)
+	}
+
+	if toAccount.Currency != fromAccount.Currency {
+		toAmount, rate, err := exchange.Convert(ctx, processor.rates, fromAccount.Currency, toAccount.Currency, arg.Amount)
+		if err != nil {
+			return db.TransferTxResult{}, err
+		}
+
+		arg.ToAmount = toAmount
+		arg.ExchangeRate = rate.String()
+	}
+
+	return processor.store.TransferTx(ctx, arg)
+}
+
+// scheduledTransferRejected reports whether the run failed for good, rather than for an error a retry may not hit:
+// the bank rejected the transfer, an account of it is gone, or its currencies can't be converted.
+func scheduledTransferRejected(err error) bool {
+	return errors.Is(err, db.ErrInsufficientFunds) ||
+		errors.Is(err, db.ErrTransferLimitExceeded) ||
+		errors.Is(err, db.ErrDailyLimitExceeded) ||
+		errors.Is(err, db.ErrIdempotencyKeyConflict) ||
+		errors.Is(err, sql.ErrNoRows) ||
+		errors.Is(err, exchange.ErrRateNotFound) ||
+		errors.Is(err, exchange.ErrAmountTooSmall) ||
+		errors.Is(err, exchange.ErrAmountTooLarge)
+}
+
+// lastRetry reports whether the task won't be retried if it fails this time.
+func lastRetry(ctx context.Context) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return false
+	}
+	maxRetry, ok := asynq.GetMaxRetry(ctx)
+	return ok && retried >= maxRetry
+}
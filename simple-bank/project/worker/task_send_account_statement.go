@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"time"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/statement"
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+)
+
+const TaskSendAccountStatement = "task:send_account_statement"
+
+// PayloadSendAccountStatement is the account and the period of the statement to email to its owner.
+type PayloadSendAccountStatement struct {
+	AccountID int64     `json:"account_id"`
+	FromTime  time.Time `json:"from_time"`
+	ToTime    time.Time `json:"to_time"`
+}
+
+func (distributor *RedisTaskDistributor) DistributeTaskSendAccountStatement(
+	ctx context.Context,
+	payload *PayloadSendAccountStatement,
+	opts ...asynq.Option,
+) error {
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	task := asynq.NewTask(TaskSendAccountStatement, jsonPayload, opts...)
+	info, err := distributor.client.EnqueueContext(ctx, task)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	log.Info().Str("type", task.Type()).Bytes("payload", task.Payload()).
+		Str("queue", info.Queue).Int("max_retry", info.MaxRetry).Msg("enqueued task")
+	return nil
+}
+
+func (processor *RedisTaskProcessor) ProcessTaskSendAccountStatement(ctx context.Context, task *asynq.Task) error {
+	var payload PayloadSendAccountStatement
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	account, err := processor.store.GetAccount(ctx, payload.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to get account: %w", err)
+	}
+
+	user, err := processor.store.GetUser(ctx, account.Owner)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	result, err := processor.store.AccountStatementTx(ctx, db.AccountStatementTxParams{
+		AccountID: payload.AccountID,
+		FromTime:  payload.FromTime,
+		ToTime:    payload.ToTime,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get account statement: %w", err)
+	}
+
+	var text bytes.Buffer
+	if err := statement.WriteText(&text, account, result); err != nil {
+		return fmt.Errorf("failed to write statement: %w", err)
+	}
+
+	// the email sender attaches files from the disk, so the csv goes through a temporary directory
+	dir, err := os.MkdirTemp("", "statement")
+	if err != nil {
+		return fmt.Errorf("failed to create statement directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	csvFile := filepath.Join(dir, statement.FileName(account, payload.FromTime, statement.FormatCSV))
+	if err := writeStatementFile(csvFile, result); err != nil {
+		return fmt.Errorf("failed to write statement file: %w", err)
+	}
+
+	subject := fmt.Sprintf("Your Simple Bank statement for %s", payload.FromTime.UTC().Format("January 2006"))
+	content := fmt.Sprintf(`Hello %s,<br/>
+	Here is the statement of your %s account #%d. It is also attached as a CSV file.<br/>
+	<pre>%s</pre>
+	`, user.FullName, account.Currency, account.ID, html.EscapeString(text.String()))
+	to := []string{user.Email}
+
+	err = processor.mailer.SendEmail(subject, content, to, nil, nil, []string{csvFile})
+	if err != nil {
+		return fmt.Errorf("failed to send statement email: %w", err)
+	}
+
+	log.Info().Str("type", task.Type()).Bytes("payload", task.Payload()).
+		Str("email", user.Email).Msg("processed task")
+	return nil
+}
+
+func writeStatementFile(name string, result db.AccountStatementTxResult) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err := statement.WriteCSV(file, result); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/ahmad-khatib0/go/simple-bank/project/otp"
+	"github.com/ahmad-khatib0/go/simple-bank/project/util"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+)
+
+const TaskSendLoginCode = "task:send_login_code"
+
+// PayloadSendLoginCode is the login challenge to email a one-time code for, to the user who
+// can't use their authenticator app.
+type PayloadSendLoginCode struct {
+	ChallengeID uuid.UUID `json:"challenge_id"`
+}
+
+func (distributor *RedisTaskDistributor) DistributeTaskSendLoginCode(
+	ctx context.Context,
+	payload *PayloadSendLoginCode,
+	opts ...asynq.Option,
+) error {
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	task := asynq.NewTask(TaskSendLoginCode, jsonPayload, opts...)
+	info, err := distributor.client.EnqueueContext(ctx, task)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	log.Info().Str("type", task.Type()).Bytes("payload", task.Payload()).
+		Str("queue", info.Queue).Int("max_retry", info.MaxRetry).Msg("enqueued task")
+	return nil
+}
+
+// ProcessTaskSendLoginCode replaces the email code of the challenge with a new one, and emails it.
+// The code isn't in the payload so that it's never stored in Redis.
+func (processor *RedisTaskProcessor) ProcessTaskSendLoginCode(ctx context.Context, task *asynq.Task) error {
+	var payload PayloadSendLoginCode
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	code, err := otp.GenerateNumericCode(otp.Digits)
+	if err != nil {
+		return err
+	}
+
+	hashedCode, err := util.HashPassword(code)
+	if err != nil {
+		return fmt.Errorf("failed to hash login code: %w", err)
+	}
+
+	challenge, err := processor.store.SetLoginChallengeEmailCode(ctx, db.SetLoginChallengeEmailCodeParams{
+		ID:              payload.ChallengeID,
+		HashedEmailCode: hashedCode,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("login challenge was used or expired: %w", asynq.SkipRetry)
+		}
+		return fmt.Errorf("failed to set login challenge email code: %w", err)
+	}
+
+	user, err := processor.store.GetUser(ctx, challenge.Username)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	subject := "Your Simple Bank login code"
+	content := fmt.Sprintf(`Hello %s,<br/>
+	Your login code is <b>%s</b>. It expires at %s.<br/>
+	If you didn't try to log in, someone else knows your password, please change it.<br/>
+	`, user.FullName, code, challenge.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"))
+	to := []string{user.Email}
+
+	err = processor.mailer.SendEmail(subject, content, to, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to send login code email: %w", err)
+	}
+
+	log.Info().Str("type", task.Type()).Bytes("payload", task.Payload()).
+		Str("email", user.Email).Msg("processed task")
+	return nil
+}
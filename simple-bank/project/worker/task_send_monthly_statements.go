@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/ahmad-khatib0/go/simple-bank/project/db/sqlc"
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+)
+
+const TaskSendMonthlyStatements = "task:send_monthly_statements"
+
+// statementsPageSize is the number of accounts the monthly statements are queued for at a time.
+const statementsPageSize = 100
+
+// NewTaskSendMonthlyStatements returns the task the scheduler queues every month. It has no payload:
+// the statements are for the month before the one the task runs in.
+func NewTaskSendMonthlyStatements() *asynq.Task {
+	return asynq.NewTask(TaskSendMonthlyStatements, nil)
+}
+
+// ProcessTaskSendMonthlyStatements queues a task to email the statement of every account, so that
+// a failed email is retried on its own. The tasks get an ID per account and month, so running this
+// task again, on a retry, doesn't send a statement twice.
+func (processor *RedisTaskProcessor) ProcessTaskSendMonthlyStatements(ctx context.Context, task *asynq.Task) error {
+	now := time.Now().UTC()
+	toTime := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	fromTime := toTime.AddDate(0, -1, 0)
+
+	var afterID int64
+	var queued int
+	for {
+		accounts, err := processor.store.ListAllAccounts(ctx, db.ListAllAccountsParams{
+			AfterID: afterID,
+			Limit:   statementsPageSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list accounts: %w", err)
+		}
+
+		for _, account := range accounts {
+			payload := &PayloadSendAccountStatement{
+				AccountID: account.ID,
+				FromTime:  fromTime,
+				ToTime:    toTime,
+			}
+			opts := []asynq.Option{
+				asynq.MaxRetry(10),
+				asynq.Queue(QueueDefault),
+				asynq.TaskID(fmt.Sprintf("statement:%d:%s", account.ID, fromTime.Format("2006-01"))),
+				asynq.Retention(7 * 24 * time.Hour),
+			}
+
+			err := processor.distributor.DistributeTaskSendAccountStatement(ctx, payload, opts...)
+			if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+				return fmt.Errorf("failed to distribute task to send account statement: %w", err)
+			}
+			queued++
+		}
+
+		if len(accounts) < statementsPageSize {
+			break
+		}
+		afterID = accounts[len(accounts)-1].ID
+	}
+
+	log.Info().Str("type", task.Type()).Time("from_time", fromTime).
+		Int("accounts", queued).Msg("processed task")
+	return nil
+}
@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/rs/zerolog/log"
+)
+
+const TaskSendScheduledTransferFailed = "task:send_scheduled_transfer_failed"
+
+// PayloadSendScheduledTransferFailed is the failed run of a scheduled transfer to email its owner about.
+type PayloadSendScheduledTransferFailed struct {
+	ScheduledTransferRunID int64 `json:"scheduled_transfer_run_id"`
+}
+
+func (distributor *RedisTaskDistributor) DistributeTaskSendScheduledTransferFailed(
+	ctx context.Context,
+	payload *PayloadSendScheduledTransferFailed,
+	opts ...asynq.Option,
+) error {
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	task := asynq.NewTask(TaskSendScheduledTransferFailed, jsonPayload, opts...)
+	info, err := distributor.client.EnqueueContext(ctx, task)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	log.Info().Str("type", task.Type()).Bytes("payload", task.Payload()).
+		Str("queue", info.Queue).Int("max_retry", info.MaxRetry).Msg("enqueued task")
+	return nil
+}
+
+func (processor *RedisTaskProcessor) ProcessTaskSendScheduledTransferFailed(ctx context.Context, task *asynq.Task) error {
+	var payload PayloadSendScheduledTransferFailed
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	run, err := processor.store.GetScheduledTransferRun(ctx, payload.ScheduledTransferRunID)
+	if err != nil {
+		return fmt.Errorf("failed to get scheduled transfer run: %w", err)
+	}
+
+	scheduledTransfer, err := processor.store.GetScheduledTransfer(ctx, run.ScheduledTransferID)
+	if err != nil {
+		return fmt.Errorf("failed to get scheduled transfer: %w", err)
+	}
+
+	user, err := processor.store.GetUser(ctx, scheduledTransfer.Owner)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	next := "It won't run again."
+	if scheduledTransfer.NextRunAt.Valid {
+		next = fmt.Sprintf("It will run again at %s.", scheduledTransfer.NextRunAt.Time.UTC().Format("2006-01-02 15:04 MST"))
+	}
+
+	subject := "Your scheduled transfer failed"
+	content := fmt.Sprintf(`Hello %s,<br/>
+	Your scheduled transfer #%d of %d from account #%d to account #%d, due at %s, failed: %s.<br/>
+	%s<br/>
+	`, user.FullName, scheduledTransfer.ID, scheduledTransfer.Amount, scheduledTransfer.FromAccountID,
+		scheduledTransfer.ToAccountID, run.ScheduledFor.UTC().Format("2006-01-02 15:04 MST"), run.Error, next)
+	to := []string{user.Email}
+
+	err = processor.mailer.SendEmail(subject, content, to, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to send scheduled transfer failed email: %w", err)
+	}
+
+	log.Info().Str("type", task.Type()).Bytes("payload", task.Payload()).
+		Str("email", user.Email).Msg("processed task")
+	return nil
+}